@@ -3,8 +3,10 @@ module github.com/liliang-cn/ohman
 go 1.24.0
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/openai/openai-go/v3 v3.15.0
 	github.com/spf13/cobra v1.10.2
+	github.com/ulikunitz/xz v0.5.12
 	gopkg.in/yaml.v3 v3.0.1
 )
 
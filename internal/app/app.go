@@ -1,10 +1,15 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strings"
+	"time"
 
+	"github.com/liliang-cn/ohman/internal/cmdline"
 	"github.com/liliang-cn/ohman/internal/config"
 	execpkg "github.com/liliang-cn/ohman/internal/exec"
 	"github.com/liliang-cn/ohman/internal/input"
@@ -14,6 +19,7 @@ import (
 	"github.com/liliang-cn/ohman/internal/output"
 	"github.com/liliang-cn/ohman/internal/session"
 	"github.com/liliang-cn/ohman/internal/shell"
+	"golang.org/x/term"
 )
 
 // App is the main application structure
@@ -32,30 +38,58 @@ func New(cfg *config.Config) *App {
 		sessionMgr = nil
 	}
 
-	return &App{
+	a := &App{
 		cfg:        cfg,
 		renderer:   output.NewRenderer(cfg.Output),
 		sessionMgr: sessionMgr,
 	}
+	man.SetHelpPolicy(man.HelpPolicy{
+		Allow:   cfg.Docs.HelpAllow,
+		Deny:    cfg.Docs.HelpDeny,
+		Confirm: a.confirmHelp,
+	})
+	return a
 }
 
-// Ask asks a question about a command
+// Ask asks a question about a command. command may include subcommands,
+// like "docker run".
 func (a *App) Ask(command string, section int, question string) error {
-	// 1. Get man page
-	manPage, err := man.Get(command, section)
+	// 1. Get man page, preferring the section and subcommand page the
+	// question is about
+	if section == 0 {
+		section = a.chooseSection(command, question)
+	}
+	manPage, err := a.resolvePage(command, section, question)
 	if err != nil {
 		return fmt.Errorf("failed to get man page: %w", err)
 	}
 
-	// 2. Initialize LLM client
+	// 2. Without an LLM, answer from the cheat sheets and man page alone
+	if a.offline() {
+		return a.answerOffline(command, section, manPage, question)
+	}
+
+	// 3. Initialize LLM client
 	client, err := a.getLLMClient()
 	if err != nil {
 		return err
 	}
 
-	// 3. Build prompt and call LLM
-	messages := llm.BuildQuestionPrompt(command, manPage.Content, question)
+	// 4. Build prompt and call LLM
+	label := pageLabel(command, section)
+	if strings.HasPrefix(manPage.Command, command+"-") {
+		label += fmt.Sprintf(" (documented by the %s subcommand page)", manPage.Command)
+	}
+	examples := a.cheatExamples(command, question)
+	installed := a.installedBinary(command, section, manPage)
+	messages := llm.BuildQuestionPrompt(label, installed, a.manContext(manPage, question), examples, question)
 
+	if section > 0 {
+		fmt.Printf("📖 %s\n", label)
+	}
+	if manPage.Source != man.SourceMan {
+		fmt.Printf("📚 Using the %s for %s\n", manPage.SourceName(), command)
+	}
 	fmt.Println("🤔 Thinking...")
 	fmt.Println()
 	response, err := client.Chat(messages)
@@ -63,7 +97,7 @@ func (a *App) Ask(command string, section int, question string) error {
 		return fmt.Errorf("failed to call LLM: %w", err)
 	}
 
-	// 4. Save to session history
+	// 5. Save to session history
 	if a.sessionMgr != nil {
 		_ = a.sessionMgr.Add(session.Entry{
 			Command:  command,
@@ -82,7 +116,7 @@ func (a *App) Ask(command string, section int, question string) error {
 // DiagnoseLastFailed diagnoses the last failed command
 func (a *App) DiagnoseLastFailed() error {
 	// Get last command (from hook file or history)
-	failedCmd, err := shell.GetLastFailed()
+	failedCmd, err := shell.GetLastFailed(a.cfg.Shell)
 	if err != nil {
 		fmt.Println("✅ No recent command found to diagnose.")
 		fmt.Println()
@@ -99,20 +133,20 @@ func (a *App) DiagnoseLastFailed() error {
 		return fmt.Errorf("unable to parse command name")
 	}
 
-	// Get related man content
-	manPage, err := man.Get(cmdName, 0)
+	// Get related documentation, from the subcommand page if there is one
+	manPage, err := man.Lookup(cmdName, 0, a.cfg.Docs.Providers)
+	if sub := subcommandOf(failedCmd.Command, cmdName); sub != "" && man.HasSubcommandPage(cmdName, sub) {
+		if subPage, subErr := man.Get(cmdName+"-"+sub, 0); subErr == nil {
+			manPage, err = subPage, nil
+		}
+	}
 	content := "(no documentation available)"
 	if err != nil {
-		// Try --help flag as fallback
-		fmt.Printf("⚠️  No man page for %s, trying --help...\n", cmdName)
-		helpOutput, helpErr := man.GetHelpOutput(cmdName)
-		if helpErr == nil {
-			content = helpOutput
-			fmt.Printf("✅ Got help output for %s\n", cmdName)
-		} else {
-			fmt.Printf("⚠️  Unable to get documentation for %s, but will still try to diagnose\n", cmdName)
-		}
+		fmt.Printf("⚠️  Unable to get documentation for %s, but will still try to diagnose\n", cmdName)
 	} else {
+		if manPage.Source != man.SourceMan {
+			fmt.Printf("📚 Using the %s for %s\n", manPage.SourceName(), cmdName)
+		}
 		content = manPage.Content
 	}
 
@@ -122,8 +156,23 @@ func (a *App) DiagnoseLastFailed() error {
 		return err
 	}
 
-	// 5. Build diagnose prompt and call LLM
-	messages := llm.BuildDiagnosePrompt(failedCmd.Command, failedCmd.ExitCode, failedCmd.Error, content)
+	// 5. Collect working directory, git and toolchain context
+	env := shell.CollectEnvironment(failedCmd.Dir)
+	for _, tool := range env.Tools {
+		if tool == cmdName {
+			continue
+		}
+		if bin, err := man.DetectBinary(tool); err == nil {
+			env.Versions[tool] = bin.String()
+		}
+	}
+	environment := env.String()
+	if installed := a.installedBinary(cmdName, 0, manPage); installed != "" {
+		environment += "\nInstalled binary: " + installed
+	}
+
+	// 6. Build diagnose prompt and call LLM
+	messages := llm.BuildDiagnosePrompt(failedCmd.Command, failedCmd.ExitCode, failedCmd.Error, content, environment)
 
 	fmt.Println("🔧 Analyzing...")
 	fmt.Println()
@@ -147,26 +196,113 @@ func (a *App) DiagnoseLastFailed() error {
 	return nil
 }
 
+// notFoundTimeout bounds the LLM call of the command-not-found handler,
+// which runs on every typo and must not hold up the prompt
+const notFoundTimeout = 5 * time.Second
+
+// chatWithin calls the LLM and gives up after timeout. The abandoned
+// request is left to end with the process
+func chatWithin(client llm.Client, messages []llm.Message, timeout time.Duration) (*llm.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		response *llm.Response
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := client.Chat(messages)
+		done <- result{response, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no answer within %s", timeout)
+	}
+}
+
+// CommandNotFound suggests the intended command or the package providing it,
+// called from the shell's command-not-found handler. Only the command word
+// and its PATH matches are sent to the LLM
+func (a *App) CommandNotFound(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	// Only the command word is sent on; its arguments may hold anything
+	name := args[0]
+
+	fmt.Fprintf(os.Stderr, "ohman: command not found: %s\n", name)
+
+	// Local fuzzy match against PATH, instant and works offline
+	similar := shell.SimilarCommands(name, 3)
+	if len(similar) > 0 {
+		fmt.Printf("💡 Did you mean: %s\n", strings.Join(similar, ", "))
+	}
+
+	// Without an LLM the local suggestions are all we can offer
+	client, err := a.getLLMClient()
+	if err != nil {
+		return nil
+	}
+
+	fmt.Println()
+	messages := llm.BuildNotFoundPrompt(name, similar, shell.DetectPackageManager())
+	response, err := chatWithin(client, messages, notFoundTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Unable to get suggestions: %v\n", err)
+		return nil
+	}
+
+	// Save to session history
+	if a.sessionMgr != nil {
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  name,
+			Question: name,
+			Answer:   response.Content,
+			Type:     "not-found",
+		})
+	}
+
+	// Streaming output is already printed, just add a newline
+	fmt.Println()
+
+	return nil
+}
+
 // Interactive enters interactive mode
 func (a *App) Interactive(command string, section int) error {
-	// 1. Get man page
-	manPage, err := man.Get(command, section)
+	// 1. Get man page, asking which section when the name is ambiguous
+	if section == 0 {
+		section = a.chooseSection(command, "")
+	}
+	manPage, err := man.Resolve(command, section, a.cfg.Docs.Providers)
 	if err != nil {
 		return fmt.Errorf("failed to get man page: %w", err)
 	}
 
-	// 2. Initialize LLM client
+	// 2. Without an LLM, show the cheat sheets and synopsis instead
+	if a.offline() {
+		return a.answerOffline(command, section, manPage, "")
+	}
+
+	// 3. Initialize LLM client
 	client, err := a.getLLMClient()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("📖 Loaded man page for %s, entering interactive mode\n", command)
+	label := pageLabel(command, section)
+	fmt.Printf("📖 Loaded %s for %s, entering interactive mode\n", manPage.SourceName(), label)
 	fmt.Println("   Type your question, or 'exit' / 'quit' to exit")
 	fmt.Println()
 
+	installed := a.installedBinary(command, section, manPage)
 	reader := input.New("❓ ")
-	history := llm.BuildQuestionPrompt(command, manPage.Content, "")
+	history := llm.BuildQuestionPrompt(label, installed, manPage.Content, "", "")
 
 	for {
 		question, err := reader.ReadLine()
@@ -183,6 +319,9 @@ func (a *App) Interactive(command string, section int) error {
 			break
 		}
 
+		// Ground each question in the parts of the page relevant to it
+		history[0] = llm.BuildQuestionPrompt(label, installed, a.manContext(manPage, question), a.cheatExamples(command, question), "")[0]
+
 		// Add user question to history
 		history = append(history, llm.Message{Role: "user", Content: question})
 
@@ -214,14 +353,136 @@ func (a *App) Interactive(command string, section int) error {
 	return nil
 }
 
-// ShowManPage shows the raw man page
-func (a *App) ShowManPage(command string, section int) error {
-	manPage, err := man.Get(command, section)
+// resolvePage loads the documentation for command. For a single command
+// whose subcommands have their own pages, like git, the man index is used
+// to pick the subcommand page matching the question, when it matches
+// clearly better than the command's own page.
+func (a *App) resolvePage(command string, section int, question string) (*man.ManPage, error) {
+	if !strings.Contains(command, " ") && question != "" {
+		if sub, ok := man.GuessSubcommand(command, question); ok {
+			if page, err := man.Get(command+"-"+sub, section); err == nil {
+				fmt.Printf("📖 Using the %s-%s subcommand page instead of %s; it matches your question better\n", command, sub, command)
+				return page, nil
+			}
+		}
+	}
+	return man.Resolve(command, section, a.cfg.Docs.Providers)
+}
+
+// subcommandOf returns the first argument of the named command in a command
+// line when it looks like a subcommand, e.g. "commit" for
+// "sudo git commit --amend"
+func subcommandOf(line, name string) string {
+	commands, err := cmdline.Parse(line)
+	if err != nil {
+		return ""
+	}
+	for _, cmd := range commands {
+		if cmd.Name != name || len(cmd.Args) == 0 {
+			continue
+		}
+		arg := cmd.Args[0]
+		if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "/.=") {
+			return ""
+		}
+		return arg
+	}
+	return ""
+}
+
+const (
+	// maxManChars is the man page budget of a question prompt
+	maxManChars = 50000
+	// retrievalChars bounds the chunks retrieved from pages over budget
+	retrievalChars = 20000
+)
+
+// manContext returns the man page content to answer a question with. Pages
+// over budget are cut down to the SYNOPSIS and the chunks most relevant to
+// the question, listed in verbose mode.
+func (a *App) manContext(manPage *man.ManPage, question string) string {
+	return a.manContextWithin(manPage, question, maxManChars, retrievalChars)
+}
+
+// manContextWithin is manContext for a page sharing the prompt with others:
+// pages over maxChars are cut down to at most retrieveChars of chunks
+func (a *App) manContextWithin(manPage *man.ManPage, question string, maxChars, retrieveChars int) string {
+	if len(manPage.Content) <= maxChars || question == "" {
+		return manPage.Content
+	}
+
+	chunks := man.Retrieve(manPage, question, retrieveChars)
+	if len(chunks) == 0 {
+		return manPage.Content
+	}
+
+	if a.cfg.Debug.Enabled {
+		fmt.Printf("🔎 Man page has %d characters, using %d relevant chunks:\n", len(manPage.Content), len(chunks))
+		for _, c := range chunks {
+			first, _, _ := strings.Cut(strings.TrimSpace(c.Text), "\n")
+			fmt.Printf("   [%s #%d] score %.2f  %s\n", c.Section, c.Index, c.Score, truncateExcerpt(first))
+		}
+		fmt.Println()
+	}
+
+	return man.FormatChunks(chunks)
+}
+
+// ShowManPage shows the raw man page, or only one of its sections when
+// sectionName is set
+func (a *App) ShowManPage(command string, section int, sectionName string) error {
+	manPage, err := man.Resolve(command, section, a.cfg.Docs.Providers)
 	if err != nil {
 		return fmt.Errorf("failed to get man page: %w", err)
 	}
 
-	fmt.Println(manPage.Content)
+	if sectionName == "" {
+		fmt.Println(manPage.Content)
+		return nil
+	}
+
+	if content, ok := manPage.SectionContent(sectionName); ok {
+		fmt.Println(content)
+		return nil
+	}
+
+	// Many pages document options under DESCRIPTION, list the option index
+	if strings.EqualFold(sectionName, "OPTIONS") && len(manPage.Options) > 0 {
+		for _, opt := range manPage.Options {
+			fmt.Printf("       %s\n", opt.Header)
+			for _, line := range strings.Split(opt.Description, "\n") {
+				if line == "" {
+					fmt.Println()
+					continue
+				}
+				fmt.Printf("              %s\n", line)
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	return fmt.Errorf("section %s not found in man page for %s (available: %s)",
+		sectionName, command, strings.Join(manPage.SectionNames(), ", "))
+}
+
+// IndexManPages builds the full-text index of the installed man pages,
+// rendering pages that aren't cached yet
+func (a *App) IndexManPages() error {
+	fmt.Println("📚 Indexing man pages...")
+	start := time.Now()
+
+	index, err := man.BuildIndex(func(done, total int) {
+		if done%25 == 0 || done == total {
+			fmt.Printf("\r   %d/%d pages", done, total)
+		}
+	})
+	fmt.Println()
+	if err != nil {
+		return fmt.Errorf("failed to build man index: %w", err)
+	}
+
+	fmt.Printf("✅ Indexed %d pages in %s\n", len(index.Pages), time.Since(start).Round(time.Second))
 	return nil
 }
 
@@ -386,8 +647,31 @@ func (a *App) Chat(logContext string) error {
 	return nil
 }
 
-// AnalyzeLogFile analyzes a log file and provides AI-powered insights
-func (a *App) AnalyzeLogFile(filePath string, limit int) error {
+// reportWindow prints the time window a log analysis was limited to, and
+// fails when nothing was left inside it
+func reportWindow(result *log.AnalysisResult) error {
+	if result.Window.IsZero() {
+		return nil
+	}
+	fmt.Printf("🕒 Time window: %s\n", result.Window)
+	if result.Total == 0 {
+		return fmt.Errorf("no log entries in the time window (%s)", result.Window)
+	}
+	return nil
+}
+
+// windowNote describes a time window for session history
+func windowNote(window log.Window) string {
+	if window.IsZero() {
+		return ""
+	}
+	return " (" + window.String() + ")"
+}
+
+// AnalyzeLogFile analyzes a log file and provides AI-powered insights.
+// A positive tail reads only the last lines; rotated includes the file's
+// rotated predecessors
+func (a *App) AnalyzeLogFile(filePath string, limit, tail int, rotated bool, window log.Window) error {
 	// Initialize LLM client
 	client, err := a.getLLMClient()
 	if err != nil {
@@ -399,20 +683,29 @@ func (a *App) AnalyzeLogFile(filePath string, limit int) error {
 
 	// Analyze the log file
 	analyzer := log.New(filePath)
+	analyzer.SetWindow(window)
+	analyzer.SetTail(tail)
+	analyzer.SetRotated(rotated)
 	result, err := analyzer.Analyze(limit)
 	if err != nil {
 		return fmt.Errorf("failed to analyze log file: %w", err)
 	}
+	if len(result.Files) > 1 {
+		fmt.Printf("🗂️  Read %d rotated files, oldest first\n", len(result.Files))
+	}
 
 	// Format the analysis result for AI
 	logContent := result.ToFormat()
 
+	if err := reportWindow(result); err != nil {
+		return err
+	}
 	fmt.Printf("📊 Found %d log entries\n", result.Total)
-	if len(result.Errors) > 0 {
-		fmt.Printf("   Errors: %d\n", len(result.Errors))
+	if result.ErrorCount() > 0 {
+		fmt.Printf("   Errors: %d\n", result.ErrorCount())
 	}
-	if len(result.Warnings) > 0 {
-		fmt.Printf("   Warnings: %d\n", len(result.Warnings))
+	if result.WarningCount() > 0 {
+		fmt.Printf("   Warnings: %d\n", result.WarningCount())
 	}
 	fmt.Println()
 
@@ -430,7 +723,7 @@ func (a *App) AnalyzeLogFile(filePath string, limit int) error {
 	if a.sessionMgr != nil {
 		_ = a.sessionMgr.Add(session.Entry{
 			Command:  "log",
-			Question: fmt.Sprintf("file:%s (limit:%d)", filePath, limit),
+			Question: fmt.Sprintf("file:%s (limit:%d, tail:%d)%s", filePath, limit, tail, windowNote(window)),
 			Answer:   response.Content,
 			Type:     "log",
 		})
@@ -443,7 +736,7 @@ func (a *App) AnalyzeLogFile(filePath string, limit int) error {
 }
 
 // AnalyzeLogContent analyzes log content from a string
-func (a *App) AnalyzeLogContent(content string) error {
+func (a *App) AnalyzeLogContent(content string, window log.Window) error {
 	// Initialize LLM client
 	client, err := a.getLLMClient()
 	if err != nil {
@@ -454,17 +747,20 @@ func (a *App) AnalyzeLogContent(content string) error {
 	fmt.Println()
 
 	// Analyze the log content
-	result := log.AnalyzeString(content)
+	result := log.AnalyzeStringWithin(content, window)
 
 	// Format as analysis result for AI
 	logContent := result.ToFormat()
 
+	if err := reportWindow(result); err != nil {
+		return err
+	}
 	fmt.Printf("📊 Found %d log entries\n", result.Total)
-	if len(result.Errors) > 0 {
-		fmt.Printf("   Errors: %d\n", len(result.Errors))
+	if result.ErrorCount() > 0 {
+		fmt.Printf("   Errors: %d\n", result.ErrorCount())
 	}
-	if len(result.Warnings) > 0 {
-		fmt.Printf("   Warnings: %d\n", len(result.Warnings))
+	if result.WarningCount() > 0 {
+		fmt.Printf("   Warnings: %d\n", result.WarningCount())
 	}
 	fmt.Println()
 
@@ -494,8 +790,74 @@ func (a *App) AnalyzeLogContent(content string) error {
 	return nil
 }
 
+// AnalyzeLogStream analyzes log lines piped on r. They are streamed like a
+// file's, so a large pipe is not held in memory; a positive tail reads
+// only the last lines and limit stops after that many entries
+func (a *App) AnalyzeLogStream(r io.Reader, limit, tail int, window log.Window) error {
+	// Initialize LLM client
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("📋 Analyzing piped log content...")
+	fmt.Println()
+
+	// Analyze the piped lines
+	analyzer := log.New("")
+	analyzer.SetWindow(window)
+	analyzer.SetTail(tail)
+	result, err := analyzer.AnalyzeReader(r, limit)
+	if err != nil {
+		return fmt.Errorf("failed to read from pipe: %w", err)
+	}
+	if result.Total == 0 && window.IsZero() {
+		return fmt.Errorf("no input received from pipe")
+	}
+
+	// Format the analysis result for AI
+	logContent := result.ToFormat()
+
+	if err := reportWindow(result); err != nil {
+		return err
+	}
+	fmt.Printf("📊 Found %d log entries\n", result.Total)
+	if result.ErrorCount() > 0 {
+		fmt.Printf("   Errors: %d\n", result.ErrorCount())
+	}
+	if result.WarningCount() > 0 {
+		fmt.Printf("   Warnings: %d\n", result.WarningCount())
+	}
+	fmt.Println()
+
+	// Build log prompt and call LLM
+	messages := llm.BuildLogPrompt(logContent)
+
+	fmt.Println("🔍 Analyzing...")
+	fmt.Println()
+	response, err := client.Chat(messages)
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+
+	// Save to session history
+	if a.sessionMgr != nil {
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  "log",
+			Question: fmt.Sprintf("stdin (limit:%d, tail:%d)%s", limit, tail, windowNote(window)),
+			Answer:   response.Content,
+			Type:     "log",
+		})
+	}
+
+	// Streaming output is already printed, just add a newline
+	fmt.Println()
+
+	return nil
+}
+
 // AnalyzeJournalctlUnit analyzes logs from journalctl for a specific unit
-func (a *App) AnalyzeJournalctlUnit(unit string, limit int) error {
+func (a *App) AnalyzeJournalctlUnit(unit string, limit int, window log.Window) error {
 	// Initialize LLM client
 	client, err := a.getLLMClient()
 	if err != nil {
@@ -506,23 +868,26 @@ func (a *App) AnalyzeJournalctlUnit(unit string, limit int) error {
 	fmt.Println()
 
 	// Get logs from journalctl
-	journalContent, err := log.GetJournalctlLogs(unit, limit)
+	journalContent, err := log.GetJournalctlLogs(unit, limit, window)
 	if err != nil {
 		return fmt.Errorf("failed to get journalctl logs: %w", err)
 	}
 
 	// Analyze the journal content
-	result := log.AnalyzeString(journalContent)
+	result := log.AnalyzeStringWithin(journalContent, window)
 
 	// Format as analysis result for AI
 	logContent := result.ToFormat()
 
+	if err := reportWindow(result); err != nil {
+		return err
+	}
 	fmt.Printf("📊 Found %d log entries\n", result.Total)
-	if len(result.Errors) > 0 {
-		fmt.Printf("   Errors: %d\n", len(result.Errors))
+	if result.ErrorCount() > 0 {
+		fmt.Printf("   Errors: %d\n", result.ErrorCount())
 	}
-	if len(result.Warnings) > 0 {
-		fmt.Printf("   Warnings: %d\n", len(result.Warnings))
+	if result.WarningCount() > 0 {
+		fmt.Printf("   Warnings: %d\n", result.WarningCount())
 	}
 	fmt.Println()
 
@@ -540,7 +905,7 @@ func (a *App) AnalyzeJournalctlUnit(unit string, limit int) error {
 	if a.sessionMgr != nil {
 		_ = a.sessionMgr.Add(session.Entry{
 			Command:  "journalctl",
-			Question: fmt.Sprintf("unit:%s (limit:%d)", unit, limit),
+			Question: fmt.Sprintf("unit:%s (limit:%d)%s", unit, limit, windowNote(window)),
 			Answer:   response.Content,
 			Type:     "log",
 		})
@@ -552,6 +917,129 @@ func (a *App) AnalyzeJournalctlUnit(unit string, limit int) error {
 	return nil
 }
 
+// Do generates a shell command for a task described in natural language,
+// grounds it in the man pages of the commands it uses and offers to run it
+func (a *App) Do(task string) error {
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	// 1. Generate a candidate command, without streaming the raw answer
+	fmt.Println("🤔 Thinking...")
+	messages := llm.BuildGeneratePrompt(task, runtime.GOOS, shell.DetectShell())
+	response, err := client.ChatStream(messages, nil)
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+
+	command, err := llm.ExtractCommand(response.Content)
+	if err != nil {
+		return fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+
+	// 2. Fetch documentation for every command the line uses
+	var docs []llm.CommandDoc
+	for _, name := range commandNames(command) {
+		if manPage, err := man.Get(name, 0); err == nil {
+			docs = append(docs, llm.CommandDoc{Command: name, Content: manPage.Content})
+			continue
+		}
+		fmt.Printf("⚠️  No man page for %s\n", name)
+	}
+
+	// 3. Verify the flags against the man pages and explain them
+	fmt.Println("📖 Checking against man pages...")
+	fmt.Println()
+	messages = llm.BuildExplainGeneratedPrompt(task, command, docs)
+	response, err = client.Chat(messages)
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+	fmt.Println()
+
+	// The explanation may have corrected the command
+	if verified, err := llm.ExtractCommand(response.Content); err == nil {
+		command = verified
+	}
+
+	// Save to session history
+	if a.sessionMgr != nil {
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  command,
+			Question: task,
+			Answer:   response.Content,
+			Type:     "do",
+		})
+	}
+
+	// 4. Offer to run it through the fix flow, so failures get auto-fixed
+	fmt.Printf("\n→ %s\n", command)
+	if !a.confirmPrompt() {
+		fmt.Println("Cancelled")
+		return nil
+	}
+
+	return a.Fix(command)
+}
+
+// Widget turns the shell prompt buffer into a command and prints only that
+// command on stdout, for the keybinding widget to put back in the buffer.
+// An empty buffer fixes the last failed command instead.
+func (a *App) Widget(buffer string) error {
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	var messages []llm.Message
+	buffer = strings.TrimSpace(buffer)
+	if buffer == "" {
+		failedCmd, err := shell.GetLastFailed(a.cfg.Shell)
+		if err != nil {
+			return fmt.Errorf("nothing to do: prompt is empty and no failed command found")
+		}
+		messages = llm.BuildFixPrompt(failedCmd.Command, []llm.FixAttempt{{
+			Command:  failedCmd.Command,
+			ExitCode: failedCmd.ExitCode,
+			Stderr:   failedCmd.Error,
+		}})
+	} else {
+		messages = llm.BuildBufferPrompt(buffer, runtime.GOOS, shell.DetectShell())
+	}
+
+	// Status goes to stderr, stdout is reserved for the command itself
+	fmt.Fprint(os.Stderr, "🤔 Thinking...\r")
+	response, err := client.ChatStream(messages, nil)
+	fmt.Fprint(os.Stderr, "\033[K")
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+
+	command, err := llm.ExtractCommand(response.Content)
+	if err != nil {
+		return fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+
+	fmt.Println(command)
+	return nil
+}
+
+// commandNames returns the unique command names invoked in a command line,
+// looking through pipes, lists, substitutions and wrappers like sudo. The
+// line may come from the model, so only valid names are returned
+func commandNames(line string) []string {
+	commands, err := cmdline.Parse(line)
+	if err != nil {
+		// Fall back to the first word for lines we can't parse
+		if name := parseCommandName(line); man.ValidName(name) {
+			return []string{name}
+		}
+		return nil
+	}
+	return docNames(commands)
+}
+
 const maxFixAttempts = 3
 
 // Fix runs a command and automatically fixes it if it fails
@@ -634,6 +1122,19 @@ func (a *App) confirmPrompt() bool {
 	return strings.ToLower(ans) == "y"
 }
 
+// confirmHelp asks before running an unknown binary for its --help
+// output. Without a terminal to ask on, it isn't run.
+func (a *App) confirmHelp(command, path string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Printf("⚠️  %s is not installed in a system directory (%s)\n", command, path)
+	fmt.Print("Run it with --help to read its usage? [y/N] ")
+	reader := input.New("")
+	ans, _ := reader.ReadLine()
+	return strings.ToLower(ans) == "y"
+}
+
 func (a *App) saveFixSession(originalCmd string, attempts []llm.FixAttempt, success bool) {
 	if a.sessionMgr == nil {
 		return
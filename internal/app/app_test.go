@@ -3,9 +3,15 @@ package app
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/liliang-cn/ohman/internal/cmdline"
 	"github.com/liliang-cn/ohman/internal/config"
+	"github.com/liliang-cn/ohman/internal/llm"
+	"github.com/liliang-cn/ohman/internal/log"
+	"github.com/liliang-cn/ohman/internal/man"
 )
 
 func TestParseCommandName(t *testing.T) {
@@ -56,6 +62,214 @@ func TestParseCommandName(t *testing.T) {
 	}
 }
 
+func TestCommandNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+	}{
+		{"single", "ls -la", []string{"ls"}},
+		{"pipeline", "find . -name '*.log' | xargs gzip", []string{"find", "xargs", "gzip"}},
+		{"list", "make && ./bin/app || echo failed", []string{"make", "app", "echo"}},
+		{"sudo with env", "sudo LANG=C apt update", []string{"sudo", "apt"}},
+		{"subshell", "echo $(date +%s)", []string{"echo", "date"}},
+		{"duplicates", "grep a f | grep b", []string{"grep"}},
+		{"metacharacters", "'x;touch ohman_do_pwned' -l | wc", []string{"wc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := commandNames(tt.line)
+			if strings.Join(result, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("commandNames(%q) = %v, want %v", tt.line, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildBreakdown(t *testing.T) {
+	commands, err := cmdline.Parse("tar -xvf a.tar 2>/dev/null && ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{
+		"tar": `OPTIONS
+       -x, --extract
+              Extract files from an archive.
+
+       -v, --verbose
+              Verbosely list files processed.
+
+       -f, --file=ARCHIVE
+              Use archive file.
+`,
+	}
+
+	breakdown := buildBreakdown(commands, contents)
+
+	for _, want := range []string{
+		"-x, --extract Extract files from an archive.",
+		"-v, --verbose Verbosely list files processed.",
+		"a.tar  argument",
+		"2>/dev/null  write stderr to /dev/null",
+		"&&  run the next command only if this one succeeds",
+		"ls\n",
+	} {
+		if !strings.Contains(breakdown, want) {
+			t.Errorf("breakdown missing %q:\n%s", want, breakdown)
+		}
+	}
+}
+
+func TestRankedCandidates(t *testing.T) {
+	candidates := []man.Candidate{
+		{Name: "scp", Section: "1"},
+		{Name: "rsync", Section: "1"},
+		{Name: "sftp", Section: "1"},
+	}
+
+	response := "1. `rsync` - syncs directories over ssh\n```bash\nrsync -av dir/ host:dir/\n```\n" +
+		"2. `scp -r` - copies recursively\n3. `unison` - not a candidate\n"
+
+	picks := rankedCandidates(response, candidates)
+	if len(picks) != 2 || picks[0].Name != "rsync" || picks[1].Name != "scp" {
+		t.Errorf("rankedCandidates() = %+v, want rsync, scp", picks)
+	}
+
+	if picks := rankedCandidates("No idea.", candidates); len(picks) != 3 || picks[0].Name != "scp" {
+		t.Errorf("rankedCandidates() should fall back to the local order, got %+v", picks)
+	}
+}
+
+func TestSubcommandOf(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		want string
+	}{
+		{"git commit --amend", "git", "commit"},
+		{"sudo git stash pop", "git", "stash"},
+		{"git --no-pager log", "git", ""},
+		{"tar -xvf a.tar", "tar", ""},
+		{"python3 ./script.py", "python3", ""},
+		{"ls", "ls", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := subcommandOf(tt.line, tt.name); got != tt.want {
+				t.Errorf("subcommandOf(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocNames(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"ls -l | grep foo", []string{"ls", "grep"}},
+		{"'x;touch ohman_pwned' -l", nil},
+		{"'$(id)' && ls", []string{"ls"}},
+		{"./configure --prefix=/usr", []string{"configure"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			commands, err := cmdline.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := docNames(commands)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("docNames(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainDoesNotRunCommandNames(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	application := New(&config.Config{})
+	if err := application.Explain("'x;touch ohman_pwned' -l"); err != nil {
+		t.Logf("Explain returned error (expected without LLM config): %v", err)
+	}
+	if _, err := os.Stat("ohman_pwned"); err == nil {
+		t.Error("Explain() ran a command from the explained line")
+	}
+}
+
+// slowClient is an LLM client that answers after a delay
+type slowClient struct {
+	delay time.Duration
+}
+
+func (c slowClient) Chat(messages []llm.Message) (*llm.Response, error) {
+	time.Sleep(c.delay)
+	return &llm.Response{Content: "ok"}, nil
+}
+
+func (c slowClient) ChatStream(messages []llm.Message, handler llm.StreamHandler) (*llm.Response, error) {
+	return c.Chat(messages)
+}
+
+func TestChatWithin(t *testing.T) {
+	if response, err := chatWithin(slowClient{}, nil, time.Second); err != nil || response.Content != "ok" {
+		t.Errorf("chatWithin() = %v, %v, want the answer", response, err)
+	}
+
+	start := time.Now()
+	if _, err := chatWithin(slowClient{delay: time.Minute}, nil, 50*time.Millisecond); err == nil {
+		t.Error("chatWithin() should give up on a slow endpoint")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("chatWithin() took %s, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestReviewDocsSkipsInvalidNames(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	commands, err := cmdline.Parse("'x;touch ohman_review_pwned' --flag")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	pages := make(map[string]string)
+	if docs := reviewDocs(commands, pages); len(docs) != 0 {
+		t.Errorf("reviewDocs() = %v, want no docs", docs)
+	}
+	if _, looked := pages["x;touch ohman_review_pwned"]; looked {
+		t.Error("reviewDocs() looked up an invalid command name")
+	}
+	if _, err := os.Stat("ohman_review_pwned"); err == nil {
+		t.Error("reviewDocs() ran a command from the script")
+	}
+}
+
+func TestParseSectionChoice(t *testing.T) {
+	sections := []int{1, 3}
+	tests := []struct {
+		response string
+		want     int
+	}{
+		{"3", 3},
+		{"Section 1", 1},
+		{"printf(3)", 3},
+		{"2", 0},
+		{"I'm not sure", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.response, func(t *testing.T) {
+			if got := parseSectionChoice(tt.response, sections); got != tt.want {
+				t.Errorf("parseSectionChoice(%q) = %d, want %d", tt.response, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAnalyzeLogFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	logFile := filepath.Join(tmpDir, "test.log")
@@ -72,7 +286,7 @@ func TestAnalyzeLogFile(t *testing.T) {
 	cfg := &config.Config{}
 	application := New(cfg)
 
-	err := application.AnalyzeLogFile(logFile, 0)
+	err := application.AnalyzeLogFile(logFile, 0, 0, false, log.Window{})
 	if err != nil {
 		t.Logf("AnalyzeLogFile returned error (expected without LLM config): %v", err)
 	}
@@ -85,7 +299,7 @@ func TestAnalyzeLogContent(t *testing.T) {
 	cfg := &config.Config{}
 	application := New(cfg)
 
-	err := application.AnalyzeLogContent(content)
+	err := application.AnalyzeLogContent(content, log.Window{})
 	if err != nil {
 		t.Logf("AnalyzeLogContent returned error (expected without LLM config): %v", err)
 	}
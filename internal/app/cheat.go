@@ -0,0 +1,85 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/cheat"
+	"github.com/liliang-cn/ohman/internal/man"
+)
+
+const (
+	// cheatChars bounds the cheat sheet examples added to prompts
+	cheatChars = 4000
+	// offlineManChars bounds the man page text of offline answers
+	offlineManChars = 6000
+)
+
+// cheatExamples returns the cheat sheet examples for a command, those
+// matching the question first, or "" when no sheet is installed
+func (a *App) cheatExamples(command, question string) string {
+	sheets := cheat.Find(a.cfg.Cheat, command)
+	if len(sheets) == 0 {
+		return ""
+	}
+	if a.cfg.Debug.Enabled {
+		for _, s := range sheets {
+			fmt.Printf("📋 Using %s examples from %s\n", s.Source, s.Path)
+		}
+	}
+	return cheat.Format(sheets, question, cheatChars)
+}
+
+// llmConfigured reports whether an LLM has been set up
+func (a *App) llmConfigured() bool {
+	return a.cfg.LLM.APIKey != "" || a.cfg.LLM.Provider == "ollama"
+}
+
+// offline reports whether questions are answered without the LLM, either
+// because --offline was given or because no LLM is configured
+func (a *App) offline() bool {
+	return a.cfg.LLM.Offline || !a.llmConfigured()
+}
+
+// answerOffline answers a question from the command's cheat sheets and the
+// man page sections most relevant to it, without calling the LLM
+func (a *App) answerOffline(command string, section int, manPage *man.ManPage, question string) error {
+	a.offlineNotice()
+	a.showLocalDocs(command, section, manPage, question)
+	return nil
+}
+
+// offlineNotice explains why the answer comes from local docs, and where
+// examples would come from
+func (a *App) offlineNotice() {
+	if !a.cfg.LLM.Offline {
+		fmt.Println("📴 No LLM configured, answering from local docs (run 'ohman config' to set one up)")
+		fmt.Println()
+	}
+	if a.cfg.Cheat.TldrPath == "" && a.cfg.Cheat.CheatPath == "" {
+		fmt.Println("💡 Set cheat.tldr_path or cheat.cheat_path in the config to see examples here")
+		fmt.Println()
+	}
+}
+
+// showLocalDocs prints a command's cheat sheet examples and the man page
+// sections most relevant to the question
+func (a *App) showLocalDocs(command string, section int, manPage *man.ManPage, question string) {
+	if sheets := cheat.Find(a.cfg.Cheat, command); len(sheets) > 0 {
+		fmt.Println("📋 Examples")
+		fmt.Println()
+		for _, line := range strings.Split(cheat.Format(sheets, question, 0), "\n") {
+			fmt.Println("   " + line)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("📖 %s (%s)\n", pageLabel(command, section), manPage.SourceName())
+	if installed := a.installedBinary(command, section, manPage); installed != "" {
+		// The mismatch note, if any, was already printed as a warning
+		description, _, _ := strings.Cut(installed, "\n")
+		fmt.Printf("🔧 Installed: %s\n", description)
+	}
+	fmt.Println()
+	fmt.Print(man.FormatChunks(man.Retrieve(manPage, question, offlineManChars)))
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/llm"
+	"github.com/liliang-cn/ohman/internal/man"
+	"github.com/liliang-cn/ohman/internal/session"
+)
+
+// defaultCompareQuestion is asked when compare is given no question
+const defaultCompareQuestion = "What are the main differences, and when should I use each?"
+
+// Compare loads the man pages of two commands, splitting the prompt budget
+// between them, and asks the LLM for a side-by-side answer with equivalent
+// invocations for each tool
+func (a *App) Compare(command1, command2, question string) error {
+	if command1 == command2 {
+		return fmt.Errorf("compare needs two different commands")
+	}
+	if question == "" {
+		question = defaultCompareQuestion
+	}
+	commands := []string{command1, command2}
+
+	// 1. Get both man pages; one missing page still leaves something to compare
+	pages := make([]*man.ManPage, len(commands))
+	found := 0
+	for i, command := range commands {
+		page, err := a.resolvePage(command, 0, question)
+		if err != nil {
+			fmt.Printf("⚠️  No documentation for %s\n", command)
+			continue
+		}
+		pages[i] = page
+		found++
+	}
+	if found == 0 {
+		return fmt.Errorf("no documentation found for %s or %s", command1, command2)
+	}
+
+	// 2. Without an LLM, show the relevant parts of both pages
+	if a.offline() {
+		a.offlineNotice()
+		for i, command := range commands {
+			if pages[i] != nil {
+				a.showLocalDocs(command, 0, pages[i], question)
+				fmt.Println()
+			}
+		}
+		return nil
+	}
+
+	// 3. Initialize LLM client
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	// 4. Build prompt with half the page budget each and call LLM
+	var docs []llm.CommandDoc
+	var installed []string
+	for i, command := range commands {
+		if pages[i] == nil {
+			continue
+		}
+		if pages[i].Source != man.SourceMan {
+			fmt.Printf("📚 Using the %s for %s\n", pages[i].SourceName(), command)
+		}
+		content := a.manContextWithin(pages[i], question, maxManChars/len(commands), retrievalChars/len(commands))
+		docs = append(docs, llm.CommandDoc{Command: command, Content: content})
+		if bin := a.installedBinary(command, 0, pages[i]); bin != "" {
+			installed = append(installed, fmt.Sprintf("- %s: %s", command, bin))
+		} else {
+			installed = append(installed, fmt.Sprintf("- %s: not found on PATH", command))
+		}
+	}
+
+	fmt.Printf("⚖️  Comparing %s and %s\n", command1, command2)
+	fmt.Println("🤔 Thinking...")
+	fmt.Println()
+	messages := llm.BuildComparePrompt(command1, command2, strings.Join(installed, "\n"), question, docs)
+	response, err := client.Chat(messages)
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+
+	// 5. Save to session history
+	if a.sessionMgr != nil {
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  command1 + " vs " + command2,
+			Question: question,
+			Answer:   response.Content,
+			Type:     "compare",
+		})
+	}
+
+	// Streaming output is already printed, just add a newline
+	fmt.Println()
+
+	return nil
+}
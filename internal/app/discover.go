@@ -0,0 +1,129 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/input"
+	"github.com/liliang-cn/ohman/internal/llm"
+	"github.com/liliang-cn/ohman/internal/man"
+	"github.com/liliang-cn/ohman/internal/session"
+)
+
+const (
+	// discoverCandidates bounds the candidates sent to the LLM
+	discoverCandidates = 15
+	// discoverPicks bounds the commands offered for interactive mode
+	discoverPicks = 5
+)
+
+// rankedItemRe matches the "1. `command`" lines of a discovery answer
+var rankedItemRe = regexp.MustCompile("(?m)^\\s*\\d+\\.\\s+`([^`]+)`")
+
+// Discover finds installed commands that do what the user described, has
+// the LLM rank and explain them, and offers to open the chosen one in
+// interactive mode
+func (a *App) Discover(description string) error {
+	fmt.Println("🔎 Searching installed commands...")
+	candidates, indexed := man.FindCommands(description, discoverCandidates)
+	if !indexed {
+		fmt.Println("💡 Run 'ohman index' to also search the full text of all man pages")
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no installed commands match: %s", description)
+	}
+
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for _, c := range candidates {
+		sb.WriteString(fmt.Sprintf("- %s(%s): %s\n", c.Name, c.Section, c.Summary))
+	}
+
+	fmt.Println("🤔 Thinking...")
+	fmt.Println()
+	messages := llm.BuildDiscoverPrompt(description, sb.String())
+	response, err := client.Chat(messages)
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+	fmt.Println()
+
+	// Save to session history
+	if a.sessionMgr != nil {
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  "discover",
+			Question: description,
+			Answer:   response.Content,
+			Type:     "discover",
+		})
+	}
+
+	picks := rankedCandidates(response.Content, candidates)
+	if len(picks) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println("📖 Ask about one of them in interactive mode:")
+	for i, c := range picks {
+		fmt.Printf("   %d) %s(%s) - %s\n", i+1, c.Name, c.Section, c.Summary)
+	}
+	fmt.Printf("Choice [1-%d, Enter to skip]: ", len(picks))
+
+	reader := input.New("")
+	answer, _ := reader.ReadLine()
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(picks) {
+		return nil
+	}
+
+	picked := picks[choice-1]
+	fmt.Println()
+	return a.Interactive(picked.Name, sectionNumber(picked.Section))
+}
+
+// rankedCandidates returns the candidates in the order the LLM ranked them,
+// falling back to the local ranking when the answer names none of them
+func rankedCandidates(response string, candidates []man.Candidate) []man.Candidate {
+	byName := make(map[string]man.Candidate)
+	for _, c := range candidates {
+		byName[c.Name] = c
+	}
+
+	var picks []man.Candidate
+	seen := make(map[string]bool)
+	for _, m := range rankedItemRe.FindAllStringSubmatch(response, -1) {
+		// The LLM may quote a full command line, keep the command name
+		fields := strings.Fields(m[1])
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if c, ok := byName[name]; ok && !seen[name] {
+			seen[name] = true
+			picks = append(picks, c)
+		}
+	}
+
+	if len(picks) == 0 {
+		picks = candidates
+	}
+	if len(picks) > discoverPicks {
+		picks = picks[:discoverPicks]
+	}
+	return picks
+}
+
+// sectionNumber returns the numeric part of a section like "1" or "1ssl"
+func sectionNumber(section string) int {
+	if section == "" || section[0] < '1' || section[0] > '9' {
+		return 0
+	}
+	return int(section[0] - '0')
+}
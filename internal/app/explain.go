@@ -0,0 +1,209 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/cmdline"
+	"github.com/liliang-cn/ohman/internal/llm"
+	"github.com/liliang-cn/ohman/internal/man"
+	"github.com/liliang-cn/ohman/internal/session"
+)
+
+// redirectDescriptions explains the common redirection operators
+var redirectDescriptions = map[string]string{
+	">":    "write stdout to",
+	">>":   "append stdout to",
+	"1>":   "write stdout to",
+	"1>>":  "append stdout to",
+	"2>":   "write stderr to",
+	"2>>":  "append stderr to",
+	"2>&1": "send stderr to the same place as stdout",
+	"1>&2": "send stdout to stderr",
+	">&2":  "send stdout to stderr",
+	"&>":   "write stdout and stderr to",
+	"&>>":  "append stdout and stderr to",
+	"<":    "read stdin from",
+	"<<":   "here-document as stdin, ending at",
+	"<<-":  "here-document as stdin (tabs stripped), ending at",
+	"<<<":  "here-string as stdin:",
+}
+
+// operatorDescriptions explains the control operators between commands
+var operatorDescriptions = map[string]string{
+	"|":  "pipe stdout into the next command",
+	"|&": "pipe stdout and stderr into the next command",
+	"&&": "run the next command only if this one succeeds",
+	"||": "run the next command only if this one fails",
+	";":  "then run the next command",
+	"&":  "run in the background",
+}
+
+// docNames returns the unique command names whose documentation can be
+// looked up; names that are not plain command names, like "x;touch f" from
+// a quoted word, are left out
+func docNames(commands []cmdline.Command) []string {
+	var names []string
+	for _, name := range cmdline.CommandNames(commands) {
+		if man.ValidName(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// combinedShortFlagsRe matches bundled short options like -xvf
+var combinedShortFlagsRe = regexp.MustCompile(`^-[A-Za-z0-9]{2,}$`)
+
+// Explain breaks a command line down into its commands, flags, redirections
+// and operators, maps each flag to its man page excerpt and asks the LLM for
+// an overall summary
+func (a *App) Explain(line string) error {
+	commands, err := cmdline.Parse(line)
+	if err != nil {
+		return fmt.Errorf("failed to parse command line: %w", err)
+	}
+	if len(commands) == 0 {
+		return fmt.Errorf("no commands found in: %s", line)
+	}
+
+	// 1. Fetch documentation for every command involved
+	var docs []llm.CommandDoc
+	contents := make(map[string]string)
+	for _, name := range docNames(commands) {
+		manPage, err := man.Get(name, 0)
+		if err != nil {
+			fmt.Printf("⚠️  No man page for %s\n", name)
+			continue
+		}
+		contents[name] = manPage.Content
+		docs = append(docs, llm.CommandDoc{Command: name, Content: manPage.Content})
+	}
+
+	// 2. Local explainshell-style breakdown
+	breakdown := buildBreakdown(commands, contents)
+	fmt.Println("🔎 Breakdown")
+	fmt.Println()
+	fmt.Println(breakdown)
+
+	// 3. Ask the LLM for an explanation and summary
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("🤔 Thinking...")
+	fmt.Println()
+	messages := llm.BuildExplainPrompt(line, breakdown, docs)
+	response, err := client.Chat(messages)
+	if err != nil {
+		return fmt.Errorf("failed to call LLM: %w", err)
+	}
+
+	// Save to session history
+	if a.sessionMgr != nil {
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  strings.Join(cmdline.CommandNames(commands), " "),
+			Question: line,
+			Answer:   response.Content,
+			Type:     "explain",
+		})
+	}
+
+	// Streaming output is already printed, just add a newline
+	fmt.Println()
+
+	return nil
+}
+
+// buildBreakdown renders each command with its flags mapped to man page
+// excerpts, its redirections and the operator linking it to the next one
+func buildBreakdown(commands []cmdline.Command, contents map[string]string) string {
+	var sb strings.Builder
+
+	for _, cmd := range commands {
+		indent := strings.Repeat("  ", cmd.Depth)
+
+		header := cmd.Name
+		if header == "" {
+			header = "(assignment)"
+		}
+		if cmd.WrappedBy != "" {
+			header += fmt.Sprintf("  (run by %s)", cmd.WrappedBy)
+		}
+		if cmd.Depth > 0 {
+			header += "  (in subshell or substitution)"
+		}
+		sb.WriteString(fmt.Sprintf("%s%s\n", indent, header))
+
+		for _, env := range cmd.Env {
+			sb.WriteString(fmt.Sprintf("%s  %s  environment variable for this command\n", indent, env))
+		}
+
+		content := contents[cmd.Name]
+		for _, arg := range cmd.Args {
+			flag := cmdline.Flag(arg)
+			if flag == "" {
+				sb.WriteString(fmt.Sprintf("%s  %s  argument\n", indent, arg))
+				continue
+			}
+
+			excerpt := flagExcerpt(content, flag)
+			if excerpt == "" {
+				excerpt = "(not found in man page)"
+			}
+			sb.WriteString(fmt.Sprintf("%s  %s  %s\n", indent, arg, excerpt))
+		}
+
+		for _, redirect := range cmd.Redirects {
+			desc := redirectDescriptions[redirect.Op]
+			if desc == "" {
+				desc = "redirection"
+			}
+			sb.WriteString(fmt.Sprintf("%s  %s%s  %s %s\n", indent, redirect.Op, redirect.Target, desc, redirect.Target))
+		}
+
+		if desc, ok := operatorDescriptions[cmd.Operator]; ok {
+			sb.WriteString(fmt.Sprintf("%s%s  %s\n", indent, cmd.Operator, desc))
+		}
+	}
+
+	return sb.String()
+}
+
+// flagExcerpt looks up a flag in man page content, splitting bundled short
+// options like -xvf into -x, -v and -f when the bundle isn't documented
+func flagExcerpt(content, flag string) string {
+	if content == "" {
+		return ""
+	}
+
+	if excerpt := man.OptionExcerpt(content, flag); excerpt != "" {
+		return truncateExcerpt(excerpt)
+	}
+
+	if !combinedShortFlagsRe.MatchString(flag) {
+		return ""
+	}
+
+	var parts []string
+	for _, r := range flag[1:] {
+		short := "-" + string(r)
+		if excerpt := man.OptionExcerpt(content, short); excerpt != "" {
+			parts = append(parts, truncateExcerpt(excerpt))
+		} else {
+			parts = append(parts, short+" (not found in man page)")
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// truncateExcerpt keeps excerpts short enough for a one-line breakdown
+func truncateExcerpt(excerpt string) string {
+	const maxLen = 160
+	if len(excerpt) <= maxLen {
+		return excerpt
+	}
+	return excerpt[:maxLen] + "..."
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/man"
+)
+
+// installedBinary describes the binary a command runs, for the prompts.
+// When its man page documents another version, the user is warned and the
+// description says so. Pages outside sections 1 and 8 document library
+// functions or files rather than the binary, and get no description.
+func (a *App) installedBinary(command string, section int, page *man.ManPage) string {
+	words := strings.Fields(command)
+	if len(words) == 0 || (section != 0 && section != 1 && section != 8) {
+		return ""
+	}
+	bin, err := man.DetectBinary(words[0])
+	if err != nil {
+		return ""
+	}
+
+	description := bin.String()
+	if page != nil && page.Source == man.SourceMan {
+		if mismatch := man.VersionMismatch(page, bin); mismatch != "" {
+			fmt.Printf("⚠️  Version mismatch: %s\n", mismatch)
+			description += fmt.Sprintf("\nNote: %s. Prefer what the installed version supports.", mismatch)
+		}
+	}
+	return description
+}
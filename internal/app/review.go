@@ -0,0 +1,158 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/liliang-cn/ohman/internal/cmdline"
+	"github.com/liliang-cn/ohman/internal/llm"
+	"github.com/liliang-cn/ohman/internal/man"
+	"github.com/liliang-cn/ohman/internal/review"
+	"github.com/liliang-cn/ohman/internal/session"
+	"github.com/liliang-cn/ohman/pkg/version"
+)
+
+const (
+	// reviewChunkTokens bounds the script lines sent in one review call
+	reviewChunkTokens = 3000
+	// reviewManHeadChars is how much of each man page's start (NAME,
+	// SYNOPSIS) accompanies the option excerpts
+	reviewManHeadChars = 1500
+)
+
+// Review reviews a shell script for unquoted variables, missing strict mode,
+// non-portable flags and dangerous operations, printing line-anchored
+// findings in text, JSON or SARIF
+func (a *App) Review(path, format string) error {
+	switch format {
+	case review.FormatText, review.FormatJSON, review.FormatSARIF:
+	default:
+		return fmt.Errorf("unsupported format: %s (use text, json or sarif)", format)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read script: %w", err)
+	}
+	content := string(data)
+
+	client, err := a.getLLMClient()
+	if err != nil {
+		return err
+	}
+
+	// Machine-readable output owns stdout, status goes to stderr
+	status := io.Writer(os.Stdout)
+	if format != review.FormatText {
+		status = os.Stderr
+	}
+
+	shellType := review.ScriptShell(content)
+	chunks := review.SplitScript(content, reviewChunkTokens)
+	totalLines := 0
+	if len(chunks) > 0 {
+		totalLines = chunks[len(chunks)-1].EndLine
+	}
+
+	fmt.Fprintf(status, "📋 Reviewing %s (%s, %d lines, %d chunks)\n", path, shellType, totalLines, len(chunks))
+
+	findings := review.CheckStrictMode(content)
+	pages := make(map[string]string)
+
+	for i, chunk := range chunks {
+		fmt.Fprintf(status, "🔍 Lines %d-%d (%d/%d)...\n", chunk.StartLine, chunk.EndLine, i+1, len(chunks))
+
+		docs := reviewDocs(review.ScriptCommands(chunk.Text()), pages)
+		messages := llm.BuildReviewPrompt(path, shellType, chunk.Numbered(), chunk.StartLine, chunk.EndLine, totalLines, docs)
+		response, err := client.ChatStream(messages, nil)
+		if err != nil {
+			return fmt.Errorf("failed to call LLM: %w", err)
+		}
+
+		chunkFindings, err := review.ParseFindings(response.Content, chunk)
+		if err != nil {
+			fmt.Fprintf(status, "⚠️  Skipping lines %d-%d: %v\n", chunk.StartLine, chunk.EndLine, err)
+			continue
+		}
+		findings = append(findings, chunkFindings...)
+	}
+	fmt.Fprintln(status)
+
+	review.Sort(findings)
+	out, err := review.Format(format, path, findings, version.Short())
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+
+	// Save to session history
+	if a.sessionMgr != nil {
+		summary, _ := review.Format(review.FormatText, path, findings, "")
+		_ = a.sessionMgr.Add(session.Entry{
+			Command:  "review",
+			Question: path,
+			Answer:   summary,
+			Type:     "review",
+		})
+	}
+
+	return nil
+}
+
+// reviewDocs gathers documentation for the commands of a chunk: the start of
+// each man page plus the excerpts of the flags actually used. Man pages are
+// cached in pages across chunks; missing pages are cached as "". Names that
+// are not valid command names, like "x;rm -rf ~", are never looked up.
+func reviewDocs(commands []cmdline.Command, pages map[string]string) []llm.CommandDoc {
+	flags := make(map[string][]string)
+	var names []string
+	for _, cmd := range commands {
+		if !man.ValidName(cmd.Name) {
+			continue
+		}
+		if _, seen := flags[cmd.Name]; !seen {
+			names = append(names, cmd.Name)
+			flags[cmd.Name] = nil
+		}
+		for _, arg := range cmd.Args {
+			if flag := cmdline.Flag(arg); flag != "" {
+				flags[cmd.Name] = append(flags[cmd.Name], flag)
+			}
+		}
+	}
+
+	var docs []llm.CommandDoc
+	for _, name := range names {
+		content, cached := pages[name]
+		if !cached {
+			if manPage, err := man.Get(name, 0); err == nil {
+				content = manPage.Content
+			}
+			pages[name] = content
+		}
+		if content == "" {
+			continue
+		}
+
+		doc := content
+		if len(doc) > reviewManHeadChars {
+			doc = doc[:reviewManHeadChars] + "\n...\n"
+		}
+		seen := make(map[string]bool)
+		for _, flag := range flags[name] {
+			if seen[flag] {
+				continue
+			}
+			seen[flag] = true
+			if excerpt := flagExcerpt(content, flag); excerpt != "" {
+				doc += "\n" + excerpt
+			} else {
+				doc += fmt.Sprintf("\n%s: not documented in this man page", flag)
+			}
+		}
+		docs = append(docs, llm.CommandDoc{Command: name, Content: doc})
+	}
+
+	return docs
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/input"
+	"github.com/liliang-cn/ohman/internal/llm"
+	"github.com/liliang-cn/ohman/internal/man"
+)
+
+// sectionNumberRe finds the section number in the LLM's answer
+var sectionNumberRe = regexp.MustCompile(`[1-9]`)
+
+// chooseSection picks the section of a command whose name exists in several
+// man sections, like printf or kill. With a question the LLM picks the
+// section it is about; without one the user is asked. It returns 0, man's
+// default, when the name isn't ambiguous or no choice could be made.
+func (a *App) chooseSection(command, question string) int {
+	if strings.Contains(command, " ") {
+		return 0
+	}
+	sections := man.GetSections(command)
+	if len(sections) < 2 {
+		return 0
+	}
+	summaries := man.GetSectionSummaries(command)
+
+	var sb strings.Builder
+	for _, s := range sections {
+		sb.WriteString(fmt.Sprintf("%d: %s\n", s, summaries[s]))
+	}
+
+	if question == "" {
+		return askSection(command, sections, summaries)
+	}
+
+	if a.offline() {
+		return 0
+	}
+	client, err := a.getLLMClient()
+	if err != nil {
+		return 0
+	}
+	response, err := client.ChatStream(llm.BuildSectionPrompt(command, sb.String(), question), nil)
+	if err != nil {
+		return 0
+	}
+	return parseSectionChoice(response.Content, sections)
+}
+
+// askSection asks the user which section to load
+func askSection(command string, sections []int, summaries map[int]string) int {
+	fmt.Printf("📚 %s has pages in several sections:\n", command)
+	for _, s := range sections {
+		fmt.Printf("   %d) %s(%d) %s\n", s, command, s, summaries[s])
+	}
+	fmt.Printf("Section [Enter for %d]: ", sections[0])
+
+	reader := input.New("")
+	answer, _ := reader.ReadLine()
+	fmt.Println()
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil {
+		return sections[0]
+	}
+	for _, s := range sections {
+		if s == choice {
+			return s
+		}
+	}
+	return sections[0]
+}
+
+// parseSectionChoice returns the first candidate section named in the
+// LLM's answer, or 0 if it names none
+func parseSectionChoice(response string, sections []int) int {
+	for _, m := range sectionNumberRe.FindAllString(response, -1) {
+		n := int(m[0] - '0')
+		for _, s := range sections {
+			if s == n {
+				return s
+			}
+		}
+	}
+	return 0
+}
+
+// pageLabel names a page with its section when known, e.g. "printf(3)"
+func pageLabel(command string, section int) string {
+	if section > 0 {
+		return fmt.Sprintf("%s(%d)", command, section)
+	}
+	return command
+}
@@ -0,0 +1,307 @@
+// Package cheat reads local tldr-pages and cheat/cheatsheets collections,
+// which complement man pages with practical examples.
+package cheat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/config"
+)
+
+// Sheet sources
+const (
+	SourceTldr  = "tldr"
+	SourceCheat = "cheat"
+)
+
+// Example is one task from a cheat sheet and the command that does it
+type Example struct {
+	Description string
+	Command     string
+}
+
+// Sheet is a command's cheat sheet
+type Sheet struct {
+	Command     string
+	Source      string
+	Path        string
+	Description string
+	Examples    []Example
+}
+
+var (
+	// placeholderRe matches tldr's {{path/to/file}} placeholders
+	placeholderRe = regexp.MustCompile(`\{\{(.*?)\}\}`)
+	// mnemonicRe matches tldr's [c]reate option mnemonics
+	mnemonicRe = regexp.MustCompile(`\[([A-Za-z0-9])\]`)
+)
+
+// tldrPlatforms returns the tldr page directories to search, the current
+// platform's first
+func tldrPlatforms() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"osx", "common"}
+	case "linux", "windows", "freebsd", "netbsd", "openbsd", "android", "sunos":
+		return []string{runtime.GOOS, "common"}
+	}
+	return []string{"common"}
+}
+
+// Find returns the cheat sheets for a command found in the configured
+// tldr and cheat directories. A command with subcommands, like
+// "git commit", matches "git-commit" sheets before the base command's.
+func Find(cfg config.CheatConfig, command string) []*Sheet {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return nil
+	}
+	names := []string{strings.Join(words, "-")}
+	if len(words) > 1 {
+		names = append(names, words[0])
+	}
+
+	var sheets []*Sheet
+	if sheet := findTldr(cfg.TldrPath, names); sheet != nil {
+		sheets = append(sheets, sheet)
+	}
+	if sheet := findCheat(cfg.CheatPath, names); sheet != nil {
+		sheets = append(sheets, sheet)
+	}
+	return sheets
+}
+
+// findTldr looks for a page in tldr-pages clones. A path may be the
+// repository root or its pages directory.
+func findTldr(paths string, names []string) *Sheet {
+	for _, root := range filepath.SplitList(expandHome(paths)) {
+		if info, err := os.Stat(filepath.Join(root, "pages")); err == nil && info.IsDir() {
+			root = filepath.Join(root, "pages")
+		}
+		for _, name := range names {
+			for _, platform := range tldrPlatforms() {
+				path := filepath.Join(root, platform, name+".md")
+				data, err := os.ReadFile(path)
+				if err != nil {
+					continue
+				}
+				sheet := ParseTldr(string(data))
+				sheet.Command, sheet.Path = name, path
+				return sheet
+			}
+		}
+	}
+	return nil
+}
+
+// findCheat looks for a sheet in cheat sheet directories
+func findCheat(paths string, names []string) *Sheet {
+	for _, root := range filepath.SplitList(expandHome(paths)) {
+		for _, name := range names {
+			path := filepath.Join(root, name)
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			sheet := ParseCheat(string(data))
+			sheet.Command, sheet.Path = name, path
+			return sheet
+		}
+	}
+	return nil
+}
+
+// expandHome expands a leading ~ in each path of a path list
+func expandHome(paths string) string {
+	if !strings.Contains(paths, "~") {
+		return paths
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return paths
+	}
+	list := filepath.SplitList(paths)
+	for i, p := range list {
+		if p == "~" || strings.HasPrefix(p, "~/") {
+			list[i] = filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return strings.Join(list, string(os.PathListSeparator))
+}
+
+// ParseTldr parses a tldr page:
+//
+//	# tar
+//	> Archiving utility.
+//	- Create an archive from files:
+//	`tar cf {{target.tar}} {{file1}}`
+func ParseTldr(content string) *Sheet {
+	sheet := &Sheet{Source: SourceTldr}
+	var description []string
+	var pending string
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, ">"):
+			text := strings.TrimSpace(strings.TrimPrefix(line, ">"))
+			if !strings.HasPrefix(text, "More information:") {
+				description = append(description, text)
+			}
+		case strings.HasPrefix(line, "- "):
+			pending = mnemonicRe.ReplaceAllString(strings.TrimSuffix(strings.TrimPrefix(line, "- "), ":"), "$1")
+		case strings.HasPrefix(line, "`") && strings.HasSuffix(line, "`") && len(line) > 1:
+			command := placeholderRe.ReplaceAllString(strings.Trim(line, "`"), "$1")
+			sheet.Examples = append(sheet.Examples, Example{Description: pending, Command: command})
+			pending = ""
+		}
+	}
+
+	sheet.Description = strings.Join(description, " ")
+	return sheet
+}
+
+// ParseCheat parses a cheat sheet, where comment lines describe the
+// command lines that follow them:
+//
+//	# To extract an uncompressed archive:
+//	tar -xvf /path/to/foo.tar
+func ParseCheat(content string) *Sheet {
+	sheet := &Sheet{Source: SourceCheat}
+	content = stripFrontMatter(content)
+
+	var comments, commands []string
+	flush := func() {
+		if len(commands) > 0 {
+			sheet.Examples = append(sheet.Examples, Example{
+				Description: strings.TrimSuffix(strings.Join(comments, " "), ":"),
+				Command:     strings.Join(commands, "\n"),
+			})
+			comments = nil
+		}
+		commands = nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			if len(commands) > 0 {
+				flush()
+			}
+			comments = append(comments, strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+		default:
+			commands = append(commands, strings.TrimRight(line, " \t"))
+		}
+	}
+	flush()
+
+	return sheet
+}
+
+// stripFrontMatter removes the YAML front matter cheat sheets may start with
+func stripFrontMatter(content string) string {
+	if !strings.HasPrefix(content, "---\n") {
+		return content
+	}
+	if end := strings.Index(content[4:], "\n---"); end != -1 {
+		rest := content[4+end+4:]
+		return strings.TrimPrefix(rest, "\n")
+	}
+	return content
+}
+
+// Ranked returns the sheet's examples, those sharing the most words with
+// the question first. Without a question, or when nothing matches, the
+// sheet's own order is kept.
+func (s *Sheet) Ranked(question string) []Example {
+	examples := append([]Example(nil), s.Examples...)
+	words := questionWords(question)
+	if len(words) == 0 {
+		return examples
+	}
+
+	scores := make([]int, len(examples))
+	for i, ex := range examples {
+		text := strings.ToLower(ex.Description + " " + ex.Command)
+		for _, w := range words {
+			if strings.Contains(text, w) {
+				scores[i]++
+			}
+		}
+	}
+
+	order := make([]int, len(examples))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	ranked := make([]Example, len(examples))
+	for i, idx := range order {
+		ranked[i] = examples[idx]
+	}
+	return ranked
+}
+
+// questionWords returns the lower-cased words of a question worth matching
+func questionWords(question string) []string {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(question)) {
+		w = strings.Trim(w, `.,;:!?"'()`)
+		if len(w) >= 3 && !commonWords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// commonWords carry no meaning for matching examples
+var commonWords = map[string]bool{
+	"how": true, "the": true, "and": true, "can": true, "what": true,
+	"for": true, "with": true, "does": true, "this": true, "that": true,
+	"from": true, "into": true, "use": true, "using": true, "want": true,
+}
+
+// Format formats sheets as plain text for prompts and offline answers,
+// the examples matching the question first, within maxChars
+func Format(sheets []*Sheet, question string, maxChars int) string {
+	var sb strings.Builder
+	for _, sheet := range sheets {
+		header := fmt.Sprintf("%s: %s", sheet.Source, sheet.Command)
+		if sheet.Description != "" {
+			header += " - " + sheet.Description
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(header + "\n")
+
+		for _, ex := range sheet.Ranked(question) {
+			var entry strings.Builder
+			if ex.Description != "" {
+				entry.WriteString("- " + ex.Description + ":\n")
+			}
+			for _, line := range strings.Split(ex.Command, "\n") {
+				entry.WriteString("  " + line + "\n")
+			}
+			if maxChars > 0 && sb.Len()+entry.Len() > maxChars {
+				break
+			}
+			sb.WriteString(entry.String())
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,146 @@
+package cheat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liliang-cn/ohman/internal/config"
+)
+
+const tarTldr = `# tar
+
+> Archiving utility.
+> Often combined with a compression method, such as gzip or bzip2.
+> More information: <https://www.gnu.org/software/tar>.
+
+- [c]reate an archive and write it to a [f]ile:
+
+` + "`tar cf {{path/to/target.tar}} {{path/to/file1 path/to/file2 ...}}`" + `
+
+- E[x]tract a (compressed) archive [f]ile into the current directory [v]erbosely:
+
+` + "`tar xvf {{path/to/source.tar[.gz|.bz2|.xz]}}`" + `
+`
+
+const tarCheat = `---
+syntax: bash
+tags: [ compression ]
+---
+# To extract an uncompressed archive:
+tar -xvf /path/to/foo.tar
+
+# To create a gzipped archive
+# from a directory:
+tar -czvf /path/to/foo.tgz /path/to/foo/
+`
+
+func TestParseTldr(t *testing.T) {
+	sheet := ParseTldr(tarTldr)
+
+	if sheet.Description != "Archiving utility. Often combined with a compression method, such as gzip or bzip2." {
+		t.Errorf("Description = %q", sheet.Description)
+	}
+	want := []Example{
+		{"create an archive and write it to a file", "tar cf path/to/target.tar path/to/file1 path/to/file2 ..."},
+		{"Extract a (compressed) archive file into the current directory verbosely", "tar xvf path/to/source.tar[.gz|.bz2|.xz]"},
+	}
+	if len(sheet.Examples) != len(want) {
+		t.Fatalf("got %d examples, want %d", len(sheet.Examples), len(want))
+	}
+	for i, ex := range sheet.Examples {
+		if ex != want[i] {
+			t.Errorf("example %d = %+v, want %+v", i, ex, want[i])
+		}
+	}
+}
+
+func TestParseCheat(t *testing.T) {
+	sheet := ParseCheat(tarCheat)
+
+	want := []Example{
+		{"To extract an uncompressed archive", "tar -xvf /path/to/foo.tar"},
+		{"To create a gzipped archive from a directory", "tar -czvf /path/to/foo.tgz /path/to/foo/"},
+	}
+	if len(sheet.Examples) != len(want) {
+		t.Fatalf("got %d examples, want %d: %+v", len(sheet.Examples), len(want), sheet.Examples)
+	}
+	for i, ex := range sheet.Examples {
+		if ex != want[i] {
+			t.Errorf("example %d = %+v, want %+v", i, ex, want[i])
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tldr := t.TempDir()
+	cheats := t.TempDir()
+	platform := tldrPlatforms()[0]
+	writeFile(t, filepath.Join(tldr, "pages", "common", "tar.md"), tarTldr)
+	writeFile(t, filepath.Join(tldr, "pages", "common", "git.md"), "# git\n\n> Version control.\n")
+	writeFile(t, filepath.Join(tldr, "pages", "common", "git-commit.md"), "# git commit\n\n> Commit files.\n")
+	writeFile(t, filepath.Join(tldr, "pages", platform, "free.md"), "# free\n\n> Show memory.\n")
+	writeFile(t, filepath.Join(cheats, "tar"), tarCheat)
+
+	cfg := config.CheatConfig{TldrPath: tldr, CheatPath: cheats}
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"tar", []string{"tldr:tar", "cheat:tar"}},
+		{"git commit", []string{"tldr:git-commit"}},
+		{"git stash", []string{"tldr:git"}},
+		{"free", []string{"tldr:free"}},
+		{"nonexistentcmd", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			var got []string
+			for _, s := range Find(cfg, tt.command) {
+				got = append(got, s.Source+":"+s.Command)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Find(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+
+	// The pages directory itself may be configured
+	if sheets := Find(config.CheatConfig{TldrPath: filepath.Join(tldr, "pages")}, "tar"); len(sheets) != 1 {
+		t.Errorf("expected the pages directory to be searched, got %d sheets", len(sheets))
+	}
+}
+
+func TestRankedAndFormat(t *testing.T) {
+	sheet := ParseCheat(tarCheat)
+	sheet.Command = "tar"
+
+	ranked := sheet.Ranked("how to make a gzipped archive of a directory")
+	if !strings.Contains(ranked[0].Command, "-czvf") {
+		t.Errorf("expected the gzip example first, got %+v", ranked[0])
+	}
+	if unranked := sheet.Ranked(""); unranked[0] != sheet.Examples[0] {
+		t.Error("without a question the sheet order should be kept")
+	}
+
+	text := Format([]*Sheet{sheet}, "gzipped directory", 0)
+	if !strings.HasPrefix(text, "cheat: tar\n- To create a gzipped archive from a directory:\n  tar -czvf") {
+		t.Errorf("unexpected format:\n%s", text)
+	}
+
+	if short := Format([]*Sheet{sheet}, "", 60); strings.Contains(short, "-czvf") {
+		t.Errorf("expected examples past the budget to be dropped:\n%s", short)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,13 +3,16 @@ package cli
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/liliang-cn/ohman/internal/app"
 	"github.com/liliang-cn/ohman/internal/config"
 	"github.com/liliang-cn/ohman/internal/log"
+	"github.com/liliang-cn/ohman/internal/man"
 	"github.com/liliang-cn/ohman/internal/session"
+	"github.com/liliang-cn/ohman/internal/shell"
 	"github.com/liliang-cn/ohman/pkg/version"
 	"github.com/spf13/cobra"
 )
@@ -21,6 +24,8 @@ var (
 	rawMode     bool
 	interactive bool
 	verbose     bool
+	sectionName string
+	offline     bool
 )
 
 // rootCmd is the root command
@@ -35,6 +40,8 @@ automatic fix suggestions when commands fail.
 Examples:
   ohman grep "How to search recursively?"    Ask about grep usage
   ohman tar "What does xvf mean?"            Ask about tar parameters
+  ohman "docker run" "How to mount a volume?" Ask about a subcommand
+  ohman --offline tar "extract a .tgz"       Answer from local docs only
   ohman git                                  Enter interactive mode
   ohman                                      Diagnose last failed command`,
 	Version:               version.String(),
@@ -58,6 +65,8 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&rawMode, "raw", "r", false, "show raw man content only")
 	rootCmd.PersistentFlags().BoolVarP(&interactive, "interactive", "i", false, "force interactive mode")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.Flags().StringVar(&sectionName, "section-name", "", "with --raw, show only this man page section (e.g. OPTIONS, SYNOPSIS)")
+	rootCmd.Flags().BoolVar(&offline, "offline", false, "answer from cheat sheets and man page sections without calling the LLM")
 
 	// Subcommands
 	rootCmd.AddCommand(configCmd)
@@ -66,6 +75,15 @@ func init() {
 	rootCmd.AddCommand(logCmd)
 	rootCmd.AddCommand(chatCmd)
 	rootCmd.AddCommand(fixCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(notFoundCmd)
+	rootCmd.AddCommand(doCmd)
+	rootCmd.AddCommand(widgetCmd)
+	rootCmd.AddCommand(explainCmd)
+	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(indexCmd)
+	rootCmd.AddCommand(discoverCmd)
+	rootCmd.AddCommand(compareCmd)
 }
 
 func initConfig() {
@@ -84,6 +102,12 @@ func runRoot(cmd *cobra.Command, args []string) error {
 	if model != "" {
 		cfg.LLM.Model = model
 	}
+	if verbose {
+		cfg.Debug.Enabled = true
+	}
+	if offline {
+		cfg.LLM.Offline = true
+	}
 
 	application := app.New(cfg)
 
@@ -101,6 +125,13 @@ func runRoot(cmd *cobra.Command, args []string) error {
 	}
 
 	command := args[0]
+
+	// "ohman git commit ..." asks about git-commit(1) when that page exists
+	if len(args) > 1 && !strings.Contains(command, " ") && man.HasSubcommandPage(command, args[1]) {
+		command += " " + args[1]
+		args = args[1:]
+	}
+
 	question := ""
 	if len(args) > 1 {
 		question = strings.Join(args[1:], " ")
@@ -108,7 +139,7 @@ func runRoot(cmd *cobra.Command, args []string) error {
 
 	// Case 3: Show raw man content
 	if rawMode {
-		return application.ShowManPage(command, section)
+		return application.ShowManPage(command, section, sectionName)
 	}
 
 	// Case 4: Interactive mode
@@ -192,8 +223,14 @@ var clearCmd = &cobra.Command{
 }
 
 var (
-	logLimit int
-	logUnit  string
+	logLimit   int
+	logTail    int
+	logRotated bool
+	logUnit    string
+	logSince   string
+	logUntil   string
+	logAround  string
+	logSpan    time.Duration
 )
 
 // logCmd is the log analysis command
@@ -209,7 +246,13 @@ var logCmd = &cobra.Command{
 
 func init() {
 	logCmd.Flags().IntVarP(&logLimit, "limit", "n", 0, "limit number of log entries to analyze (0 = all)")
+	logCmd.Flags().IntVarP(&logTail, "tail", "t", 0, "only analyze the last N lines, read from the end of the file")
+	logCmd.Flags().BoolVar(&logRotated, "rotated", false, "include rotated files (app.log.1, app.log.2.gz, ...), oldest first")
 	logCmd.Flags().StringVarP(&logUnit, "unit", "u", "", "analyze systemd journalctl logs for specific unit (e.g., nginx.service)")
+	logCmd.Flags().StringVar(&logSince, "since", "", "only analyze entries at or after this time (e.g., 2h, 14:05, 2025-02-01 14:05)")
+	logCmd.Flags().StringVar(&logUntil, "until", "", "only analyze entries at or before this time")
+	logCmd.Flags().StringVar(&logAround, "around", "", "only analyze entries within --window of this time")
+	logCmd.Flags().DurationVar(&logSpan, "window", 5*time.Minute, "time on each side of --around")
 }
 
 // chatCmd is the chat command for interactive conversations
@@ -234,23 +277,26 @@ func runLog(cmd *cobra.Command, args []string) error {
 		cfg.LLM.Model = model
 	}
 
+	window, err := log.ParseWindow(logSince, logUntil, logAround, logSpan)
+	if err != nil {
+		return err
+	}
+
 	application := app.New(cfg)
 
 	// Case 1: Check for piped input
 	if log.IsPipedInput() {
-		content, err := log.ReadFromStdin()
-		if err != nil {
-			return fmt.Errorf("failed to read from pipe: %w", err)
-		}
-		if content == "" {
-			return fmt.Errorf("no input received from pipe")
-		}
-		return application.AnalyzeLogContent(content)
+		return application.AnalyzeLogStream(os.Stdin, logLimit, logTail, window)
 	}
 
 	// Case 2: Analyze systemd journalctl unit
 	if logUnit != "" {
-		return application.AnalyzeJournalctlUnit(logUnit, logLimit)
+		// journalctl -n already takes the most recent entries
+		limit := logLimit
+		if logTail > 0 {
+			limit = logTail
+		}
+		return application.AnalyzeJournalctlUnit(logUnit, limit, window)
 	}
 
 	// Case 3: No args, provide usage info
@@ -263,6 +309,15 @@ func runLog(cmd *cobra.Command, args []string) error {
 		fmt.Println("Analyze with limit:")
 		fmt.Println("  ohman log -n 100 /var/log/app.log")
 		fmt.Println()
+		fmt.Println("Analyze the end of a large, compressed or rotated log:")
+		fmt.Println("  ohman log --tail 5000 /var/log/app.log")
+		fmt.Println("  ohman log /var/log/app.log.2.gz")
+		fmt.Println("  ohman log --rotated /var/log/app.log")
+		fmt.Println()
+		fmt.Println("Analyze an incident window:")
+		fmt.Println("  ohman log --since 14:00 --until 14:30 /var/log/app.log")
+		fmt.Println("  ohman log --around \"2025-02-01 14:05\" --window 10m /var/log/app.log")
+		fmt.Println()
 		fmt.Println("Analyze systemd service:")
 		fmt.Println("  ohman log -u nginx.service")
 		fmt.Println("  ohman log --unit docker -n 50")
@@ -291,16 +346,16 @@ func runLog(cmd *cobra.Command, args []string) error {
 	// Check if input looks like a file path (contains / or starts with . or ~)
 	if strings.Contains(input, "\n") || strings.Contains(input, "[ERROR]") || strings.Contains(input, "[FATAL]") {
 		// Looks like pasted log content
-		return application.AnalyzeLogContent(input)
+		return application.AnalyzeLogContent(input, window)
 	}
 
 	// Check if file exists
-	if _, err := os.Stat(input); err == nil {
-		return application.AnalyzeLogFile(input, logLimit)
+	if _, err := os.Stat(input); err == nil || logRotated {
+		return application.AnalyzeLogFile(input, logLimit, logTail, logRotated, window)
 	}
 
 	// Treat as log content string
-	return application.AnalyzeLogContent(input)
+	return application.AnalyzeLogContent(input, window)
 }
 
 func runHistory(cmd *cobra.Command, args []string) error {
@@ -349,6 +404,29 @@ func runHistory(cmd *cobra.Command, args []string) error {
 				}
 			case "diagnose":
 				fmt.Printf("      Type: Failed Command Diagnosis\n")
+			case "not-found":
+				fmt.Printf("      Type: Command Not Found\n")
+			case "discover":
+				fmt.Printf("      Type: Command Discovery\n")
+				fmt.Printf("      Task: %s\n", truncateString(entry.Question, 60))
+			case "review":
+				fmt.Printf("      Type: Script Review\n")
+				fmt.Printf("      Script: %s\n", entry.Question)
+			case "explain":
+				fmt.Printf("      Type: Command Line Explanation\n")
+				if entry.Question != "" {
+					fmt.Printf("      Line: %s\n", truncateString(entry.Question, 60))
+				}
+			case "compare":
+				fmt.Printf("      Type: Command Comparison\n")
+				if entry.Question != "" {
+					fmt.Printf("      Question: %s\n", truncateString(entry.Question, 60))
+				}
+			case "do":
+				fmt.Printf("      Type: Command Generation\n")
+				if entry.Question != "" {
+					fmt.Printf("      Task: %s\n", truncateString(entry.Question, 60))
+				}
 			case "interactive":
 				if entry.Question != "" {
 					fmt.Printf("      Question: %s\n", truncateString(entry.Question, 60))
@@ -456,11 +534,11 @@ func runChat(cmd *cobra.Command, args []string) error {
 
 			logContext = result.ToFormat()
 			fmt.Printf("📊 Found %d log entries\n", result.Total)
-			if len(result.Errors) > 0 {
-				fmt.Printf("   Errors: %d\n", len(result.Errors))
+			if result.ErrorCount() > 0 {
+				fmt.Printf("   Errors: %d\n", result.ErrorCount())
 			}
-			if len(result.Warnings) > 0 {
-				fmt.Printf("   Warnings: %d\n", len(result.Warnings))
+			if result.WarningCount() > 0 {
+				fmt.Printf("   Warnings: %d\n", result.WarningCount())
 			}
 			fmt.Println()
 			fmt.Println("Log analysis complete. You can now ask questions about these logs.")
@@ -515,3 +593,375 @@ func runFix(cmd *cobra.Command, args []string) error {
 
 	return application.Fix(command)
 }
+
+var (
+	hookCommandNotFound bool
+	hookWidget          bool
+)
+
+// hookCmd installs shell integrations
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Install shell integrations",
+	Long: `Install the failed command recording hook into your shell config.
+
+Optional integrations:
+  --command-not-found   Suggest the intended command or the package to install
+                        when you type a command that doesn't exist (bash, zsh, fish)
+  --widget              Bind Ctrl-X Ctrl-O to replace the prompt buffer with a
+                        generated or fixed command for review (bash, zsh, fish)
+
+Examples:
+  ohman hook                        Install the failed command hook
+  ohman hook --command-not-found    Also install the command-not-found handler
+  ohman hook --widget               Also install the prompt widget`,
+	Args: cobra.NoArgs,
+	RunE: runHook,
+}
+
+func init() {
+	hookCmd.Flags().BoolVar(&hookCommandNotFound, "command-not-found", false, "install the command-not-found handler")
+	hookCmd.Flags().BoolVar(&hookWidget, "widget", false, "install the Ctrl-X Ctrl-O prompt widget")
+}
+
+func runHook(cmd *cobra.Command, args []string) error {
+	shellType := shell.DetectShell()
+	needsReload := false
+
+	installed, reload, err := shell.EnsureHookInstalled()
+	if err != nil {
+		return fmt.Errorf("failed to install hook: %w", err)
+	}
+	if installed && reload {
+		fmt.Printf("✅ Failed command hook installed for %s\n", shellType)
+		needsReload = true
+	} else {
+		fmt.Printf("✅ Failed command hook already installed for %s\n", shellType)
+	}
+
+	if hookCommandNotFound {
+		installed, reload, err := shell.InstallCommandNotFoundHandler()
+		if err != nil {
+			return fmt.Errorf("failed to install command-not-found handler: %w", err)
+		}
+		if installed && reload {
+			fmt.Printf("✅ Command-not-found handler installed for %s\n", shellType)
+			needsReload = true
+		} else {
+			fmt.Printf("✅ Command-not-found handler already installed for %s\n", shellType)
+		}
+	}
+
+	if hookWidget {
+		installed, reload, err := shell.InstallWidget()
+		if err != nil {
+			return fmt.Errorf("failed to install prompt widget: %w", err)
+		}
+		if installed && reload {
+			fmt.Printf("✅ Prompt widget installed for %s (Ctrl-X Ctrl-O)\n", shellType)
+			needsReload = true
+		} else {
+			fmt.Printf("✅ Prompt widget already installed for %s\n", shellType)
+		}
+	}
+
+	if needsReload {
+		fmt.Println()
+		fmt.Println("💡 Reload your shell config or open a new terminal for changes to take effect")
+	}
+
+	return nil
+}
+
+// notFoundCmd is invoked by the shell's command-not-found handler
+var notFoundCmd = &cobra.Command{
+	Use:                "not-found <command> [args...]",
+	Short:              "Suggest a fix for a command that was not found",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	RunE:               runNotFound,
+}
+
+func runNotFound(cmd *cobra.Command, args []string) error {
+	// Flag parsing is disabled so the user's arguments pass through untouched
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	application := app.New(cfg)
+	return application.CommandNotFound(args)
+}
+
+// doCmd generates a command from a task description
+var doCmd = &cobra.Command{
+	Use:     "do <task>",
+	Aliases: []string{"how"},
+	Short:   "Generate a shell command for a task",
+	Long: `Describe a task in natural language and get a shell command for it.
+
+The generated command is checked against the man pages of the commands it
+uses, every flag is explained, and you can choose to run it. If it fails,
+ohman offers fixes just like 'ohman fix'.
+
+Examples:
+  ohman do "compress every .log older than 7 days in this tree"
+  ohman how "list the 10 largest files under /var"`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE:                  runDo,
+}
+
+func runDo(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Override model config
+	if model != "" {
+		cfg.LLM.Model = model
+	}
+
+	application := app.New(cfg)
+
+	return application.Do(strings.Join(args, " "))
+}
+
+// widgetCmd is invoked by the prompt keybinding widget
+var widgetCmd = &cobra.Command{
+	Use:                "widget <buffer>",
+	Short:              "Turn the prompt buffer into a command",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	RunE:               runWidget,
+}
+
+func runWidget(cmd *cobra.Command, args []string) error {
+	// Flag parsing is disabled so the buffer passes through untouched
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	application := app.New(cfg)
+	return application.Widget(strings.Join(args, " "))
+}
+
+// explainCmd explains a full command line token by token
+var explainCmd = &cobra.Command{
+	Use:   "explain <command line>",
+	Short: "Explain a command line token by token",
+	Long: `Break a command line down into its commands, flags, redirections and
+operators, map every flag to the relevant man page excerpt, and get an
+overall summary.
+
+Quote the command line so your shell doesn't interpret pipes and redirects.
+
+Examples:
+  ohman explain "tar -xzvf archive.tar.gz -C /tmp"
+  ohman explain 'find . -name "*.log" -mtime +7 -print0 | xargs -0 gzip'`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE:                  runExplain,
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Override model config
+	if model != "" {
+		cfg.LLM.Model = model
+	}
+
+	application := app.New(cfg)
+
+	return application.Explain(strings.Join(args, " "))
+}
+
+var reviewFormat string
+
+// reviewCmd reviews a shell script
+var reviewCmd = &cobra.Command{
+	Use:   "review <script>",
+	Short: "Review a shell script for common pitfalls",
+	Long: `Review a shell script for unquoted variables, missing strict mode,
+non-portable flags and dangerous operations. Large scripts are split into
+chunks, and the man pages of the commands used are checked for every flag.
+
+Findings are anchored to script lines and printed as text, JSON or SARIF.
+
+Examples:
+  ohman review deploy.sh
+  ohman review --format json deploy.sh
+  ohman review --format sarif deploy.sh > ohman.sarif`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReview,
+}
+
+func init() {
+	reviewCmd.Flags().StringVarP(&reviewFormat, "format", "f", "text", "output format: text, json or sarif")
+}
+
+func runReview(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Override model config
+	if model != "" {
+		cfg.LLM.Model = model
+	}
+
+	application := app.New(cfg)
+
+	return application.Review(args[0], reviewFormat)
+}
+
+var (
+	indexBackground bool
+	indexClear      bool
+)
+
+// indexCmd builds the full-text index of installed man pages
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build the local man page cache and search index",
+	Long: `Render every installed man page into the local cache and build a
+full-text index over them, so lookups are instant and commands can be
+searched by description.
+
+Pages are cached by path and modification time, so re-running the index
+only renders pages that were installed or updated since the last run.
+
+Examples:
+  ohman index
+  ohman index --background
+  ohman index --clear       # remove the cached pages and the index`,
+	Args: cobra.NoArgs,
+	RunE: runIndex,
+}
+
+func init() {
+	indexCmd.Flags().BoolVar(&indexBackground, "background", false, "build the index in a background process")
+	indexCmd.Flags().BoolVar(&indexClear, "clear", false, "remove the cached pages and the index instead of building")
+}
+
+func runIndex(cmd *cobra.Command, args []string) error {
+	if indexClear {
+		if err := man.ClearCache(); err != nil {
+			return fmt.Errorf("failed to clear man cache: %w", err)
+		}
+		fmt.Println("✅ Man page cache and index cleared")
+		return nil
+	}
+
+	if indexBackground {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate ohman executable: %w", err)
+		}
+
+		// Once released, the child keeps running after this process exits
+		child := exec.Command(exe, "index")
+		if err := child.Start(); err != nil {
+			return fmt.Errorf("failed to start indexer: %w", err)
+		}
+		fmt.Printf("📚 Building man index in the background (pid %d)\n", child.Process.Pid)
+		return child.Process.Release()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	application := app.New(cfg)
+
+	return application.IndexManPages()
+}
+
+// discoverCmd finds which installed command does something. It isn't named
+// "find", which would shadow questions about find(1) like
+// ohman find "How to delete old files?"
+var discoverCmd = &cobra.Command{
+	Use:   "discover <description>",
+	Short: "Find which installed command does something",
+	Long: `Describe what you want to do, and ohman searches apropos and the local
+man index for installed commands that do it, ranks and explains them, and
+lets you jump into interactive mode for the one you pick.
+
+Build the index with 'ohman index' to search the full text of all pages.
+
+Examples:
+  ohman discover "copy a directory to another machine over ssh"
+  ohman discover "show which process is using a port"`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE:                  runDiscover,
+}
+
+func runDiscover(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Override model config
+	if model != "" {
+		cfg.LLM.Model = model
+	}
+
+	application := app.New(cfg)
+
+	return application.Discover(strings.Join(args, " "))
+}
+
+// compareCmd compares two commands side by side
+var compareCmd = &cobra.Command{
+	Use:   "compare <command1> <command2> [question]",
+	Short: "Compare two commands side by side",
+	Long: `Load the man pages of two commands and get a side-by-side answer to
+your question, with the equivalent invocation for each tool.
+
+Without a question, ohman summarizes the main differences.
+
+Examples:
+  ohman compare rsync scp "copy a directory to a remote host, resuming if interrupted"
+  ohman compare find fd "find all .log files older than 7 days"
+  ohman compare curl wget`,
+	Args:                  cobra.MinimumNArgs(2),
+	DisableFlagsInUseLine: true,
+	RunE:                  runCompare,
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Override model config
+	if model != "" {
+		cfg.LLM.Model = model
+	}
+
+	application := app.New(cfg)
+
+	return application.Compare(args[0], args[1], strings.Join(args[2:], " "))
+}
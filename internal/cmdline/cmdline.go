@@ -0,0 +1,553 @@
+package cmdline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TokenKind represents the kind of a shell token
+type TokenKind int
+
+const (
+	TokenWord     TokenKind = iota // A word, possibly quoted
+	TokenOperator                  // | || |& & && ; ( )
+	TokenRedirect                  // > >> < 2> &> 2>&1 << <<<
+	TokenComment                   // # to end of line
+)
+
+// Token is a lexical token of a shell command line
+type Token struct {
+	Kind  TokenKind
+	Text  string // Raw text as written
+	Value string // Word with quotes and escapes removed
+
+	// Bodies of $(...), `...`, <(...) and >(...) found inside the word
+	Substitutions []string
+}
+
+// Redirect is an I/O redirection attached to a command
+type Redirect struct {
+	Op     string // e.g. ">", "2>>", "2>&1", "<<<"
+	Target string // File, fd or here-string; empty when Op includes it
+}
+
+// Command is a simple command found in a command line
+type Command struct {
+	Name      string
+	Args      []string // Arguments with quotes removed
+	Env       []string // Leading NAME=value assignments
+	Redirects []Redirect
+	Depth     int    // Nesting level: subshells and substitutions are deeper
+	WrappedBy string // Wrapper command that runs this one, e.g. "sudo"
+	Operator  string // Operator following the command: "|", "&&", ";", ...
+}
+
+// assignmentRe matches a shell variable assignment word
+var assignmentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\[[^]]*\])?\+?=`)
+
+// redirectRe matches a redirection operator at the start of the input
+var redirectRe = regexp.MustCompile(`^([0-9]*(>>|>&|>\||<<<|<<-|<<|<&|<>|>|<)|&>>|&>)`)
+
+// dupRedirectRe matches redirections that duplicate or close a descriptor
+var dupRedirectRe = regexp.MustCompile(`[<>]&([0-9]+|-)$`)
+
+// operators are the control operators, longest first
+var operators = []string{"&&", "||", "|&", ";;", "|", "&", ";", "(", ")"}
+
+// wrapperFlagsWithArg lists, per wrapper command, the options that consume the
+// following word, so it isn't mistaken for the wrapped command
+var wrapperFlagsWithArg = map[string]map[string]bool{
+	"sudo":    {"-u": true, "-g": true, "-h": true, "-p": true, "-C": true, "-D": true, "-r": true, "-t": true, "-U": true},
+	"env":     {"-u": true, "-C": true, "-S": true},
+	"xargs":   {"-I": true, "-n": true, "-P": true, "-L": true, "-d": true, "-E": true, "-s": true, "-a": true},
+	"time":    {"-f": true, "-o": true},
+	"nice":    {"-n": true},
+	"nohup":   {},
+	"exec":    {"-a": true},
+	"command": {},
+	"timeout": {"-s": true, "-k": true},
+	"watch":   {"-n": true},
+}
+
+// wrapperPositionals is how many positional arguments a wrapper takes
+// before the wrapped command, e.g. the duration in "timeout 5 curl ..."
+var wrapperPositionals = map[string]int{
+	"timeout": 1,
+}
+
+// prefixKeywords are reserved words that precede a command
+var prefixKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "while": true,
+	"until": true, "do": true, "!": true, "{": true, "}": true,
+	"done": true, "fi": true, "esac": true,
+}
+
+// compoundKeywords start constructs whose words are not a command
+var compoundKeywords = map[string]bool{
+	"for": true, "case": true, "select": true, "function": true, "[[": true,
+}
+
+// Tokenize splits a shell command line into tokens
+func Tokenize(line string) ([]Token, error) {
+	rs := []rune(line)
+	var tokens []Token
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+
+		switch {
+		case r == ' ' || r == '\t':
+			i++
+			continue
+
+		case r == '\n':
+			tokens = append(tokens, Token{Kind: TokenOperator, Text: ";"})
+			i++
+			continue
+
+		case r == '#':
+			end := i
+			for end < len(rs) && rs[end] != '\n' {
+				end++
+			}
+			tokens = append(tokens, Token{Kind: TokenComment, Text: string(rs[i:end])})
+			i = end
+			continue
+		}
+
+		// Process substitution is a word, check it before redirects
+		if (r == '<' || r == '>') && i+1 < len(rs) && rs[i+1] == '(' {
+			word, next, err := readWord(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, word)
+			i = next
+			continue
+		}
+
+		if m := redirectRe.FindString(string(rs[i:min(len(rs), i+4)])); m != "" {
+			end := i + len([]rune(m))
+			// Duplications like 2>&1 or <&- carry their target
+			if strings.HasSuffix(m, "&") && !strings.HasPrefix(m, "&") {
+				for end < len(rs) && (rs[end] >= '0' && rs[end] <= '9' || rs[end] == '-') {
+					end++
+				}
+			}
+			tokens = append(tokens, Token{Kind: TokenRedirect, Text: string(rs[i:end])})
+			i = end
+			continue
+		}
+
+		if op := matchOperator(rs[i:]); op != "" {
+			tokens = append(tokens, Token{Kind: TokenOperator, Text: op})
+			i += len(op)
+			continue
+		}
+
+		word, next, err := readWord(rs, i)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, word)
+		i = next
+	}
+
+	return tokens, nil
+}
+
+// matchOperator returns the control operator at the start of rs, or ""
+func matchOperator(rs []rune) string {
+	for _, op := range operators {
+		if strings.HasPrefix(string(rs[:min(len(rs), 2)]), op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// isWordEnd reports whether r ends an unquoted word
+func isWordEnd(r rune) bool {
+	return strings.ContainsRune(" \t\n|&;()<>", r)
+}
+
+// readWord reads a word starting at rs[start], returning it and the index after it
+func readWord(rs []rune, start int) (Token, int, error) {
+	var value strings.Builder
+	var subs []string
+	i := start
+
+	for i < len(rs) {
+		r := rs[i]
+
+		switch {
+		case (r == '<' || r == '>') && i+1 < len(rs) && rs[i+1] == '(' && i == start:
+			end, err := matchClose(rs, i+2, '(', ')')
+			if err != nil {
+				return Token{}, 0, err
+			}
+			subs = append(subs, string(rs[i+2:end]))
+			value.WriteString(string(rs[i : end+1]))
+			i = end + 1
+
+		case isWordEnd(r):
+			return Token{Kind: TokenWord, Text: string(rs[start:i]), Value: value.String(), Substitutions: subs}, i, nil
+
+		case r == '\\':
+			if i+1 < len(rs) {
+				if rs[i+1] != '\n' {
+					value.WriteRune(rs[i+1])
+				}
+				i += 2
+			} else {
+				i++
+			}
+
+		case r == '\'':
+			end := indexRune(rs, i+1, '\'')
+			if end == -1 {
+				return Token{}, 0, fmt.Errorf("unterminated single quote")
+			}
+			value.WriteString(string(rs[i+1 : end]))
+			i = end + 1
+
+		case r == '"':
+			end, inner, err := readDoubleQuoted(rs, i+1)
+			if err != nil {
+				return Token{}, 0, err
+			}
+			value.WriteString(inner.value)
+			subs = append(subs, inner.subs...)
+			i = end + 1
+
+		case r == '$' || r == '`':
+			end, body, err := readExpansion(rs, i)
+			if err != nil {
+				return Token{}, 0, err
+			}
+			if body != nil {
+				subs = append(subs, *body)
+			}
+			value.WriteString(string(rs[i:end]))
+			i = end
+
+		default:
+			value.WriteRune(r)
+			i++
+		}
+	}
+
+	return Token{Kind: TokenWord, Text: string(rs[start:i]), Value: value.String(), Substitutions: subs}, i, nil
+}
+
+// quoted holds the decoded content of a double-quoted string
+type quoted struct {
+	value string
+	subs  []string
+}
+
+// readDoubleQuoted reads a double-quoted string whose content starts at
+// rs[start], returning the index of the closing quote
+func readDoubleQuoted(rs []rune, start int) (int, quoted, error) {
+	var q quoted
+	var value strings.Builder
+
+	for i := start; i < len(rs); {
+		switch rs[i] {
+		case '"':
+			q.value = value.String()
+			return i, q, nil
+		case '\\':
+			if i+1 < len(rs) && strings.ContainsRune("$`\"\\\n", rs[i+1]) {
+				if rs[i+1] != '\n' {
+					value.WriteRune(rs[i+1])
+				}
+				i += 2
+				continue
+			}
+			value.WriteRune('\\')
+			i++
+		case '$', '`':
+			end, body, err := readExpansion(rs, i)
+			if err != nil {
+				return 0, q, err
+			}
+			if body != nil {
+				q.subs = append(q.subs, *body)
+			}
+			value.WriteString(string(rs[i:end]))
+			i = end
+		default:
+			value.WriteRune(rs[i])
+			i++
+		}
+	}
+
+	return 0, q, fmt.Errorf("unterminated double quote")
+}
+
+// readExpansion reads a $..., $(...), $((...)), ${...} or `...` expansion at
+// rs[start]. It returns the index after it and, for command substitutions,
+// the body of the substituted command.
+func readExpansion(rs []rune, start int) (int, *string, error) {
+	if rs[start] == '`' {
+		end := indexRune(rs, start+1, '`')
+		if end == -1 {
+			return 0, nil, fmt.Errorf("unterminated backquote")
+		}
+		body := string(rs[start+1 : end])
+		return end + 1, &body, nil
+	}
+
+	if start+1 >= len(rs) {
+		return start + 1, nil, nil
+	}
+
+	switch rs[start+1] {
+	case '(':
+		// Arithmetic expansion $((...)) runs no command
+		if start+2 < len(rs) && rs[start+2] == '(' {
+			end, err := matchClose(rs, start+3, '(', ')')
+			if err != nil {
+				return 0, nil, err
+			}
+			if end+1 < len(rs) && rs[end+1] == ')' {
+				return end + 2, nil, nil
+			}
+		}
+		end, err := matchClose(rs, start+2, '(', ')')
+		if err != nil {
+			return 0, nil, err
+		}
+		body := string(rs[start+2 : end])
+		return end + 1, &body, nil
+
+	case '{':
+		end, err := matchClose(rs, start+2, '{', '}')
+		if err != nil {
+			return 0, nil, err
+		}
+		return end + 1, nil, nil
+	}
+
+	return start + 1, nil, nil
+}
+
+// matchClose finds the closer balancing an opener whose content starts at
+// rs[start], skipping quoted text
+func matchClose(rs []rune, start int, open, close rune) (int, error) {
+	depth := 1
+	for i := start; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := indexRune(rs, i+1, '\'')
+			if end == -1 {
+				return 0, fmt.Errorf("unterminated single quote")
+			}
+			i = end
+		case '"':
+			end, _, err := readDoubleQuoted(rs, i+1)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("missing closing %q", close)
+}
+
+// indexRune returns the index of r in rs at or after start, or -1
+func indexRune(rs []rune, start int, r rune) int {
+	for i := start; i < len(rs); i++ {
+		if rs[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Parse parses a command line into the simple commands it runs, including
+// commands inside subshells, command substitutions and wrappers like sudo
+func Parse(line string) ([]Command, error) {
+	return parseAt(line, 0)
+}
+
+// parseAt parses a command line whose commands sit at the given depth
+func parseAt(line string, depth int) ([]Command, error) {
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []Command
+	var nested []Command
+	var words []Token
+	var redirects []Redirect
+	level := depth
+
+	flush := func(op string) {
+		commands = append(commands, buildCommands(words, redirects, level, op)...)
+		commands = append(commands, nested...)
+		words, redirects, nested = nil, nil, nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch tok.Kind {
+		case TokenComment:
+			continue
+
+		case TokenRedirect:
+			redirect := Redirect{Op: tok.Text}
+			// Duplications and closes like 2>&1 or <&- carry their own target
+			if !dupRedirectRe.MatchString(tok.Text) && i+1 < len(tokens) && tokens[i+1].Kind == TokenWord {
+				i++
+				redirect.Target = tokens[i].Value
+				if err := appendNested(&nested, tokens[i], level); err != nil {
+					return nil, err
+				}
+			}
+			redirects = append(redirects, redirect)
+
+		case TokenOperator:
+			switch tok.Text {
+			case "(":
+				flush("")
+				level++
+			case ")":
+				flush("")
+				if level > depth {
+					level--
+				}
+			default:
+				flush(tok.Text)
+			}
+
+		case TokenWord:
+			words = append(words, tok)
+			if err := appendNested(&nested, tok, level); err != nil {
+				return nil, err
+			}
+		}
+	}
+	flush("")
+
+	return commands, nil
+}
+
+// appendNested parses the command substitutions of a word one level deeper
+func appendNested(nested *[]Command, tok Token, level int) error {
+	for _, body := range tok.Substitutions {
+		inner, err := parseAt(body, level+1)
+		if err != nil {
+			return err
+		}
+		*nested = append(*nested, inner...)
+	}
+	return nil
+}
+
+// buildCommands turns the words of one simple command into Commands,
+// splitting off the command a wrapper like sudo runs
+func buildCommands(words []Token, redirects []Redirect, depth int, op string) []Command {
+	cmd := Command{Depth: depth, Redirects: redirects, Operator: op}
+
+	i := 0
+	for ; i < len(words); i++ {
+		word := words[i].Value
+		if compoundKeywords[word] {
+			return nil
+		}
+		if prefixKeywords[word] {
+			continue
+		}
+		if assignmentRe.MatchString(words[i].Text) {
+			cmd.Env = append(cmd.Env, word)
+			continue
+		}
+		break
+	}
+
+	if i >= len(words) {
+		if len(cmd.Env) == 0 && len(redirects) == 0 {
+			return nil
+		}
+		return []Command{cmd}
+	}
+
+	cmd.Name = baseName(words[i].Value)
+	i++
+
+	flagsWithArg, isWrapper := wrapperFlagsWithArg[cmd.Name]
+	positionals := wrapperPositionals[cmd.Name]
+	for ; i < len(words); i++ {
+		word := words[i].Value
+		isOperand := isWrapper && !strings.HasPrefix(word, "-") && !(cmd.Name == "env" && assignmentRe.MatchString(word))
+		if isOperand && positionals > 0 {
+			positionals--
+			cmd.Args = append(cmd.Args, word)
+			continue
+		}
+		if isOperand {
+			// The remaining words are the wrapped command
+			wrapped := buildCommands(words[i:], nil, depth, op)
+			if len(wrapped) > 0 {
+				wrapped[0].WrappedBy = cmd.Name
+			}
+			cmd.Operator = ""
+			return append([]Command{cmd}, wrapped...)
+		}
+		cmd.Args = append(cmd.Args, word)
+		if isWrapper && flagsWithArg[word] && i+1 < len(words) {
+			i++
+			cmd.Args = append(cmd.Args, words[i].Value)
+		}
+	}
+
+	return []Command{cmd}
+}
+
+// baseName strips the directory from a command path like /usr/bin/ls
+func baseName(name string) string {
+	if idx := strings.LastIndex(name, "/"); idx != -1 && idx < len(name)-1 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// CommandNames returns the unique command names in commands, in order
+func CommandNames(commands []Command) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, cmd := range commands {
+		if cmd.Name == "" || seen[cmd.Name] {
+			continue
+		}
+		seen[cmd.Name] = true
+		names = append(names, cmd.Name)
+	}
+	return names
+}
+
+// Flag splits an argument into the option name to look up in documentation,
+// e.g. "--color=auto" -> "--color". It returns "" for non-option arguments.
+func Flag(arg string) string {
+	if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+		return ""
+	}
+	if idx := strings.Index(arg, "="); idx != -1 {
+		return arg[:idx]
+	}
+	return arg
+}
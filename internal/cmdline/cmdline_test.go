@@ -0,0 +1,174 @@
+package cmdline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		texts []string
+		kinds []TokenKind
+	}{
+		{
+			name:  "pipe and redirect",
+			line:  "grep -r foo . 2>/dev/null | wc -l",
+			texts: []string{"grep", "-r", "foo", ".", "2>", "/dev/null", "|", "wc", "-l"},
+			kinds: []TokenKind{TokenWord, TokenWord, TokenWord, TokenWord, TokenRedirect, TokenWord, TokenOperator, TokenWord, TokenWord},
+		},
+		{
+			name:  "quotes stay in one word",
+			line:  `echo "a | b" 'c; d'`,
+			texts: []string{"echo", `"a | b"`, `'c; d'`},
+			kinds: []TokenKind{TokenWord, TokenWord, TokenWord},
+		},
+		{
+			name:  "fd duplication",
+			line:  "make >build.log 2>&1 && echo ok",
+			texts: []string{"make", ">", "build.log", "2>&1", "&&", "echo", "ok"},
+			kinds: []TokenKind{TokenWord, TokenRedirect, TokenWord, TokenRedirect, TokenOperator, TokenWord, TokenWord},
+		},
+		{
+			name:  "comment",
+			line:  "ls # list files",
+			texts: []string{"ls", "# list files"},
+			kinds: []TokenKind{TokenWord, TokenComment},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := Tokenize(tt.line)
+			if err != nil {
+				t.Fatalf("Tokenize() error = %v", err)
+			}
+			if len(tokens) != len(tt.texts) {
+				t.Fatalf("Tokenize() got %d tokens %v, want %d", len(tokens), tokens, len(tt.texts))
+			}
+			for i, tok := range tokens {
+				if tok.Text != tt.texts[i] || tok.Kind != tt.kinds[i] {
+					t.Errorf("token %d = {%d %q}, want {%d %q}", i, tok.Kind, tok.Text, tt.kinds[i], tt.texts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTokenizeValue(t *testing.T) {
+	tokens, err := Tokenize(`echo "it's \"quoted\"" 'single' esc\ aped`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"echo", `it's "quoted"`, "single", "esc aped"}
+	for i, tok := range tokens {
+		if tok.Value != want[i] {
+			t.Errorf("token %d value = %q, want %q", i, tok.Value, want[i])
+		}
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	for _, line := range []string{`echo "abc`, `echo 'abc`, "echo $(date", "echo `date"} {
+		if _, err := Tokenize(line); err == nil {
+			t.Errorf("Tokenize(%q) expected error", line)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	line := `LANG=C sudo -u www find /var/log -name "*.log" -mtime +7 -print0 | xargs -0 gzip > out.txt 2>&1; echo "done at $(date +%H:%M)"`
+
+	commands, err := Parse(line)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	names := CommandNames(commands)
+	want := []string{"sudo", "find", "xargs", "gzip", "echo", "date"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("CommandNames() = %v, want %v", names, want)
+	}
+
+	sudo := commands[0]
+	if len(sudo.Env) != 1 || sudo.Env[0] != "LANG=C" {
+		t.Errorf("sudo env = %v, want [LANG=C]", sudo.Env)
+	}
+	if strings.Join(sudo.Args, " ") != "-u www" {
+		t.Errorf("sudo args = %v, want [-u www]", sudo.Args)
+	}
+
+	find := commands[1]
+	if find.WrappedBy != "sudo" || find.Operator != "|" {
+		t.Errorf("find = %+v, want wrapped by sudo and piped", find)
+	}
+	if find.Args[2] != "*.log" {
+		t.Errorf("find quoted arg = %q, want *.log", find.Args[2])
+	}
+
+	// Redirections belong to the wrapper, which is what the shell runs
+	xargs := commands[2]
+	if len(xargs.Redirects) != 2 {
+		t.Fatalf("xargs = %+v, want 2 redirects", xargs)
+	}
+	if xargs.Redirects[0].Op != ">" || xargs.Redirects[0].Target != "out.txt" {
+		t.Errorf("first redirect = %+v", xargs.Redirects[0])
+	}
+	if xargs.Redirects[1].Op != "2>&1" || xargs.Redirects[1].Target != "" {
+		t.Errorf("second redirect = %+v", xargs.Redirects[1])
+	}
+	if gzip := commands[3]; gzip.WrappedBy != "xargs" || gzip.Operator != ";" {
+		t.Errorf("gzip = %+v, want wrapped by xargs", gzip)
+	}
+
+	date := commands[5]
+	if date.Depth != 1 {
+		t.Errorf("date depth = %d, want 1", date.Depth)
+	}
+}
+
+func TestParseKeywordsAndSubshells(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"if statement", "if grep -q x f; then echo yes; fi", []string{"grep", "echo"}},
+		{"for loop", "for f in *.go; do gofmt -l $f; done", []string{"gofmt"}},
+		{"subshell", "(cd /tmp && ls) | sort", []string{"cd", "ls", "sort"}},
+		{"process substitution", "diff <(sort a) <(sort b)", []string{"diff", "sort"}},
+		{"timeout positional", "timeout 5 curl -s example.com", []string{"timeout", "curl"}},
+		{"arithmetic", "echo $((1 + 2))", []string{"echo"}},
+		{"full path", "/usr/bin/env python3 script.py", []string{"env", "python3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := CommandNames(commands); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("CommandNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlag(t *testing.T) {
+	tests := map[string]string{
+		"--color=auto": "--color",
+		"-l":           "-l",
+		"-":            "",
+		"--":           "",
+		"file.txt":     "",
+	}
+
+	for arg, want := range tests {
+		if got := Flag(arg); got != want {
+			t.Errorf("Flag(%q) = %q, want %q", arg, got, want)
+		}
+	}
+}
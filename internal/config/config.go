@@ -15,6 +15,8 @@ type Config struct {
 	LLM    LLMConfig    `yaml:"llm"`
 	Shell  ShellConfig  `yaml:"shell"`
 	Output OutputConfig `yaml:"output"`
+	Cheat  CheatConfig  `yaml:"cheat"`
+	Docs   DocsConfig   `yaml:"docs"`
 	Debug  DebugConfig  `yaml:"debug"`
 }
 
@@ -27,6 +29,9 @@ type LLMConfig struct {
 	MaxTokens   int     `yaml:"max_tokens"`
 	Temperature float64 `yaml:"temperature"`
 	Timeout     int     `yaml:"timeout"`
+	// Offline answers questions from cheat sheets and man pages alone,
+	// without calling the LLM
+	Offline bool `yaml:"offline"`
 }
 
 // ShellConfig represents shell configuration
@@ -42,6 +47,27 @@ type OutputConfig struct {
 	Language string `yaml:"language"`
 }
 
+// CheatConfig represents cheat sheet configuration. Each path may list
+// several directories separated by ':'.
+type CheatConfig struct {
+	// TldrPath is a tldr-pages clone, or its pages directory
+	TldrPath string `yaml:"tldr_path"`
+	// CheatPath holds cheat/cheatsheets style sheets, one file per command
+	CheatPath string `yaml:"cheat_path"`
+}
+
+// DocsConfig represents documentation source configuration
+type DocsConfig struct {
+	// Providers lists the documentation sources to try, in order, out of
+	// builtin, man, info and help (--help output). Empty means the default.
+	Providers []string `yaml:"providers"`
+	// HelpAllow lists commands whose --help may run even when installed
+	// outside the system directories, e.g. in ~/bin
+	HelpAllow []string `yaml:"help_allow"`
+	// HelpDeny lists commands whose --help is never run
+	HelpDeny []string `yaml:"help_deny"`
+}
+
 // DebugConfig represents debug configuration
 type DebugConfig struct {
 	Enabled    bool `yaml:"enabled"`
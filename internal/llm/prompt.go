@@ -22,6 +22,21 @@ Current command: %s
 %s
 === END OF MAN PAGE ===`
 
+// questionInstalledBlock tells the LLM which binary the user runs
+const questionInstalledBlock = `
+
+Installed binary: %s
+Answer for this version. If an option you suggest differs between the GNU, BSD and BusyBox versions, or needs a newer version, say so.`
+
+// questionExamplesBlock adds cheat sheet examples to the question prompt
+const questionExamplesBlock = `
+
+The following practical examples come from community cheat sheets (tldr, cheat). Prefer them for common tasks, but check their options against the man page.
+
+=== CHEAT SHEET EXAMPLES ===
+%s
+=== END OF EXAMPLES ===`
+
 const systemPromptDiagnose = `You are a command-line expert. Analyze the failed command and provide a fix.
 
 Be concise. Use this format:
@@ -40,6 +55,10 @@ Failed command: %s
 Exit code: %d
 Error: %s
 
+=== ENVIRONMENT ===
+%s
+===
+
 === MAN PAGE ===
 %s
 ===`
@@ -113,15 +132,178 @@ Actionable recommendations to prevent future issues.
 %s
 === END OF LOG ANALYSIS ===`
 
-// BuildQuestionPrompt builds a question prompt
-func BuildQuestionPrompt(command, manContent, question string) []Message {
+const systemPromptNotFound = `You are a command-line expert. The user typed a command that does not exist on their system.
+
+Your answer is shown inline in the terminal, so be very brief (at most 3 lines):
+- If it is most likely a typo of an installed command, give the corrected command name
+- If it is a real tool that isn't installed, give the install command for the user's package manager
+- Wrap commands in single backticks, no code blocks, no headings
+
+Command: %s
+Package manager: %s
+Similar installed commands: %s`
+
+const systemPromptGenerate = `You are a shell command generator. Turn the user's task description into a single shell command line.
+
+CRITICAL OUTPUT FORMAT:
+- Return ONLY the command line
+- Wrap exactly in: __CMD__your command here__CMD__
+- NO explanations, NO markdown
+
+Prefer standard, widely available tools. Avoid destructive operations unless the task explicitly asks for them.
+
+Operating system: %s
+Shell: %s`
+
+const systemPromptExplainGenerated = `You are a Linux/Unix command-line expert. A command was generated for the user's task. The man pages of the commands it uses are included below.
+
+Check every option against the man pages. If an option doesn't exist or is used incorrectly on this system, fix the command. Then explain it.
+
+Use this format:
+
+## Command
+` + "```bash" + `
+final command here
+` + "```" + `
+
+## Explanation
+- ` + "`option or part`" + `: what it does (one line each, cover every flag)
+
+## Notes
+Only if there are caveats (destructive effects, portability). Omit otherwise.
+
+Task: %s
+Proposed command: %s
+
+%s`
+
+const systemPromptBuffer = `You are a shell assistant bound to a key in the user's prompt. You receive the current command line buffer, which is either:
+- a description of a task in natural language: return a command that performs it
+- a shell command, possibly incomplete or wrong: return the corrected or completed command
+
+CRITICAL OUTPUT FORMAT:
+- Return ONLY the command line
+- Wrap exactly in: __CMD__your command here__CMD__
+- NO explanations, NO markdown
+
+Operating system: %s
+Shell: %s`
+
+const systemPromptExplain = `You are a Linux/Unix command-line expert. Explain a command line to the user the way explainshell does.
+
+A local breakdown maps each command, flag, redirection and operator to its man page excerpt. Use it and the man pages below; do not invent options that aren't documented.
+
+Use this format:
+
+## Breakdown
+For each command in order, one bullet per flag or argument:
+- ` + "`token`" + `: what it does here
+
+## Summary
+What the whole command line does, in 2-4 sentences, including any risks.
+
+Command line: %s
+
+=== LOCAL BREAKDOWN ===
+%s
+=== END OF BREAKDOWN ===
+
+%s`
+
+const systemPromptCompare = `You are a Linux/Unix command-line expert. The user is choosing between %s and %s and asks how they compare. Answer from the man pages below; do not invent options that aren't documented, and say so when a tool can't do something.
+
+Use this format:
+
+## Summary
+The main difference in 1-3 sentences.
+
+## Side by Side
+A markdown table with one row per aspect relevant to the question (e.g. transport, resuming, speed, defaults) and one column per tool.
+
+## Equivalent Invocations
+For the task in the question, the command for each tool, with a comment naming the tool:
+` + "```bash" + `
+# %s
+command here
+# %s
+command here
+` + "```" + `
+Explain each flag used in one line.
+
+## When to Use Which
+One or two bullets per tool.
+
+=== INSTALLED ===
+%s
+===
+
+%s`
+
+const systemPromptReview = `You are a senior shell script reviewer. Review part of a %s script for:
+- unquoted variable expansions that may be word-split or globbed (rule "unquoted-variable")
+- flags or constructs that are not portable between GNU and BSD/macOS tools or between shells (rule "non-portable")
+- dangerous operations such as rm -rf on unchecked variables, curl | sh, chmod 777 or eval of input (rule "dangerous-operation")
+- logic errors and incorrect command usage (rule "bug")
+- other robustness improvements (rule "best-practice")
+
+Do not report missing "set -euo pipefail", it is checked separately.
+Check flags against the man page excerpts below; do not invent options.
+
+Lines are prefixed with their line number in the original file. Respond with ONLY a JSON array, one object per issue:
+[{"line": 12, "end_line": 14, "severity": "error|warning|info", "rule": "<rule>", "message": "<what is wrong>", "suggestion": "<fixed code>"}]
+
+Respond with [] if there are no issues.
+
+=== SCRIPT %s (lines %d-%d of %d) ===
+%s
+=== END OF SCRIPT ===
+
+%s`
+
+const systemPromptDiscover = `You are a Linux/Unix command-line expert. The user describes something they want to do but doesn't know which command does it.
+
+Below are candidate commands installed on their machine, found by searching the man pages. Pick the best 1-5 candidates for the task, best first. Only pick commands from the list.
+
+Use this format for each pick:
+
+1. ` + "`command`" + ` - why it fits, in one sentence
+` + "```bash" + `
+example command for the task
+` + "```" + `
+
+If none of the candidates fit, say so and suggest what to install instead.
+
+=== CANDIDATES ===
+%s
+=== END OF CANDIDATES ===`
+
+const systemPromptSection = `The man page name "%s" exists in several manual sections:
+
+%s
+
+Manual sections: 1 user commands, 2 system calls, 3 library functions, 4 special files, 5 file formats and configuration files, 6 games, 7 overviews and conventions, 8 administration commands.
+
+Which section is the user's question about? Reply with the section number only.`
+
+// BuildQuestionPrompt builds a question prompt. installed describes the
+// binary the user runs and examples holds cheat sheet examples for the
+// command; both may be empty.
+func BuildQuestionPrompt(command, installed, manContent, examples, question string) []Message {
 	// Limit man content length to avoid exceeding token limits
 	manContent = truncateContent(manContent, 50000)
 
+	system := fmt.Sprintf(systemPromptQuestion, command, manContent)
+	if installed != "" {
+		system += fmt.Sprintf(questionInstalledBlock, truncateContent(installed, 1000))
+	}
+	if examples != "" {
+		system += fmt.Sprintf(questionExamplesBlock, truncateContent(examples, 8000))
+	}
+
 	messages := []Message{
 		{
 			Role:    "system",
-			Content: fmt.Sprintf(systemPromptQuestion, command, manContent),
+			Content: system,
 		},
 	}
 
@@ -135,15 +317,21 @@ func BuildQuestionPrompt(command, manContent, question string) []Message {
 	return messages
 }
 
-// BuildDiagnosePrompt builds a diagnose prompt
-func BuildDiagnosePrompt(command string, exitCode int, errorMsg, manContent string) []Message {
+// BuildDiagnosePrompt builds a diagnose prompt.
+// environment describes where the command ran (cwd, git, project, versions).
+func BuildDiagnosePrompt(command string, exitCode int, errorMsg, manContent, environment string) []Message {
 	// Limit man content length
 	manContent = truncateContent(manContent, 50000)
 
+	// The environment block is already bounded by the shell package
+	if environment == "" {
+		environment = "(not available)"
+	}
+
 	return []Message{
 		{
 			Role:    "system",
-			Content: fmt.Sprintf(systemPromptDiagnose, command, exitCode, errorMsg, manContent),
+			Content: fmt.Sprintf(systemPromptDiagnose, command, exitCode, errorMsg, environment, manContent),
 		},
 		{
 			Role:    "user",
@@ -192,6 +380,174 @@ func BuildLogPrompt(logContent string) []Message {
 	}
 }
 
+// BuildNotFoundPrompt builds a prompt suggesting the intended command or the
+// package that provides a command that was not found. Only the command word
+// is included, not its arguments
+func BuildNotFoundPrompt(command string, similar []string, packageManager string) []Message {
+	similarList := "(none)"
+	if len(similar) > 0 {
+		similarList = strings.Join(similar, ", ")
+	}
+	if packageManager == "" {
+		packageManager = "(unknown)"
+	}
+
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptNotFound, command, packageManager, similarList),
+		},
+		{
+			Role:    "user",
+			Content: "Did I mistype a command, or which package do I need to install?",
+		},
+	}
+}
+
+// BuildGeneratePrompt builds a prompt turning a task description into a command
+func BuildGeneratePrompt(task, osName, shellType string) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptGenerate, osName, shellType),
+		},
+		{
+			Role:    "user",
+			Content: task,
+		},
+	}
+}
+
+// BuildBufferPrompt builds a prompt turning the shell's prompt buffer into a
+// ready-to-run command, whether it holds a task description or a command
+func BuildBufferPrompt(buffer, osName, shellType string) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptBuffer, osName, shellType),
+		},
+		{
+			Role:    "user",
+			Content: buffer,
+		},
+	}
+}
+
+// CommandDoc is the documentation of one command used in a command line
+type CommandDoc struct {
+	Command string
+	Content string
+}
+
+// BuildExplainGeneratedPrompt builds a prompt that checks a generated command
+// against the man pages of the commands it uses and explains each flag
+func BuildExplainGeneratedPrompt(task, command string, docs []CommandDoc) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptExplainGenerated, task, command, formatCommandDocs(docs)),
+		},
+		{
+			Role:    "user",
+			Content: "Verify the command against the man pages and explain it.",
+		},
+	}
+}
+
+// BuildExplainPrompt builds a prompt explaining every token of a command line
+func BuildExplainPrompt(commandLine, breakdown string, docs []CommandDoc) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptExplain, commandLine, breakdown, formatCommandDocs(docs)),
+		},
+		{
+			Role:    "user",
+			Content: "Explain this command line.",
+		},
+	}
+}
+
+// BuildComparePrompt builds a prompt comparing two commands side by side.
+// installed describes the installed binaries and may be empty.
+func BuildComparePrompt(command1, command2, installed, question string, docs []CommandDoc) []Message {
+	if installed == "" {
+		installed = "(not available)"
+	}
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptCompare, command1, command2, command1, command2, installed, formatCommandDocs(docs)),
+		},
+		{
+			Role:    "user",
+			Content: question,
+		},
+	}
+}
+
+// BuildReviewPrompt builds a prompt reviewing one chunk of a shell script,
+// given its numbered lines and the man page excerpts of the commands it uses
+func BuildReviewPrompt(path, shellType, numbered string, startLine, endLine, totalLines int, docs []CommandDoc) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptReview, shellType, path, startLine, endLine, totalLines, numbered, formatCommandDocs(docs)),
+		},
+		{
+			Role:    "user",
+			Content: "Review these lines and return the JSON findings.",
+		},
+	}
+}
+
+// BuildDiscoverPrompt builds a prompt ranking installed commands for a task,
+// given one "name(section): summary" line per candidate
+func BuildDiscoverPrompt(description, candidates string) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptDiscover, candidates),
+		},
+		{
+			Role:    "user",
+			Content: description,
+		},
+	}
+}
+
+// BuildSectionPrompt builds a prompt choosing the man section a question is
+// about, given one "section: summary" line per candidate section
+func BuildSectionPrompt(command, sections, question string) []Message {
+	return []Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(systemPromptSection, command, sections),
+		},
+		{
+			Role:    "user",
+			Content: question,
+		},
+	}
+}
+
+// formatCommandDocs formats man pages for several commands, sharing the
+// content budget between them so none is dropped entirely
+func formatCommandDocs(docs []CommandDoc) string {
+	if len(docs) == 0 {
+		return "(no man pages available)\n"
+	}
+
+	var sb strings.Builder
+	budget := 50000 / len(docs)
+	for _, doc := range docs {
+		sb.WriteString(fmt.Sprintf("=== MAN PAGE: %s ===\n", doc.Command))
+		sb.WriteString(truncateContent(doc.Content, budget))
+		sb.WriteString("\n=== END OF MAN PAGE ===\n\n")
+	}
+	return sb.String()
+}
+
 // FixAttempt represents a single fix attempt for context
 type FixAttempt struct {
 	Command  string
@@ -247,19 +603,26 @@ func ExtractCommand(response string) (string, error) {
 		}
 	}
 
-	// Fallback: extract from markdown code block
-	lines := strings.Split(response, "\n")
+	// Fallback: extract the whole first markdown code block, so commands
+	// spanning several lines are not cut short
+	var block []string
 	inCodeBlock := false
-	for _, line := range lines {
+	for _, line := range strings.Split(response, "\n") {
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "```bash" || trimmed == "```sh" || trimmed == "```" {
-			inCodeBlock = !inCodeBlock
+			if inCodeBlock {
+				break
+			}
+			inCodeBlock = true
 			continue
 		}
-		if inCodeBlock && trimmed != "" {
-			return trimmed, nil
+		if inCodeBlock {
+			block = append(block, strings.TrimRight(line, " \t\r"))
 		}
 	}
+	if cmd := strings.TrimSpace(strings.Join(block, "\n")); cmd != "" {
+		return cmd, nil
+	}
 
 	// Last resort: if response is short and looks like a command
 	if len(response) < 200 && !strings.ContainsAny(response, "\n") {
@@ -10,7 +10,7 @@ func TestBuildQuestionPrompt(t *testing.T) {
 	manContent := "GREP(1) - print lines matching a pattern"
 	question := "How to search recursively?"
 
-	messages := BuildQuestionPrompt(command, manContent, question)
+	messages := BuildQuestionPrompt(command, "", manContent, "", question)
 
 	if len(messages) != 2 {
 		t.Errorf("expected 2 messages, got %d", len(messages))
@@ -38,20 +38,50 @@ func TestBuildQuestionPrompt(t *testing.T) {
 }
 
 func TestBuildQuestionPromptNoQuestion(t *testing.T) {
-	messages := BuildQuestionPrompt("ls", "LS(1)", "")
+	messages := BuildQuestionPrompt("ls", "", "LS(1)", "", "")
 
 	if len(messages) != 1 {
 		t.Errorf("expected 1 message without question, got %d", len(messages))
 	}
 }
 
+func TestBuildQuestionPromptExamples(t *testing.T) {
+	examples := "tldr: tar - Archiving utility.\n- Extract an archive:\n  tar xf source.tar"
+
+	with := BuildQuestionPrompt("tar", "", "TAR(1)", examples, "How do I extract?")
+	without := BuildQuestionPrompt("tar", "", "TAR(1)", "", "How do I extract?")
+
+	if !strings.Contains(with[0].Content, "CHEAT SHEET EXAMPLES") || !strings.Contains(with[0].Content, examples) {
+		t.Error("system prompt should contain the cheat sheet examples")
+	}
+	if strings.Contains(without[0].Content, "CHEAT SHEET EXAMPLES") {
+		t.Error("system prompt should not have an examples block without examples")
+	}
+}
+
+func TestBuildQuestionPromptInstalled(t *testing.T) {
+	installed := "sed (GNU sed) 4.9 at /usr/bin/sed, package sed 4.9-1"
+
+	with := BuildQuestionPrompt("sed", installed, "SED(1)", "", "How do I edit in place?")
+	without := BuildQuestionPrompt("sed", "", "SED(1)", "", "How do I edit in place?")
+
+	if !strings.Contains(with[0].Content, "Installed binary: "+installed) {
+		t.Error("system prompt should describe the installed binary")
+	}
+	if strings.Contains(without[0].Content, "Installed binary") {
+		t.Error("system prompt should not mention an installed binary when unknown")
+	}
+}
+
 func TestBuildDiagnosePrompt(t *testing.T) {
 	command := "chmod 777 /etc/passwd"
 	exitCode := 1
 	errorMsg := "Operation not permitted"
 	manContent := "CHMOD(1) - change file mode bits"
 
-	messages := BuildDiagnosePrompt(command, exitCode, errorMsg, manContent)
+	environment := "Working directory: /etc\nGit branch: main (clean)"
+
+	messages := BuildDiagnosePrompt(command, exitCode, errorMsg, manContent, environment)
 
 	if len(messages) != 2 {
 		t.Errorf("expected 2 messages, got %d", len(messages))
@@ -64,6 +94,153 @@ func TestBuildDiagnosePrompt(t *testing.T) {
 	if !strings.Contains(systemContent, errorMsg) {
 		t.Error("should contain error message")
 	}
+	if !strings.Contains(systemContent, environment) {
+		t.Error("should contain environment block")
+	}
+}
+
+func TestBuildDiagnosePromptNoEnvironment(t *testing.T) {
+	messages := BuildDiagnosePrompt("make", 2, "", "MAKE(1)", "")
+
+	if !strings.Contains(messages[0].Content, "(not available)") {
+		t.Error("missing environment should be marked as not available")
+	}
+}
+
+func TestBuildNotFoundPrompt(t *testing.T) {
+	messages := BuildNotFoundPrompt("gti", []string{"git", "gtk"}, "apt")
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+
+	systemContent := messages[0].Content
+	for _, want := range []string{"Command: gti", "apt", "git, gtk"} {
+		if !strings.Contains(systemContent, want) {
+			t.Errorf("system prompt should contain %q", want)
+		}
+	}
+
+	messages = BuildNotFoundPrompt("foo", nil, "")
+	if !strings.Contains(messages[0].Content, "(none)") || !strings.Contains(messages[0].Content, "(unknown)") {
+		t.Error("empty candidates and package manager should be marked")
+	}
+}
+
+func TestBuildGeneratePrompt(t *testing.T) {
+	task := "compress every .log older than 7 days"
+	messages := BuildGeneratePrompt(task, "linux", "bash")
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0].Content, "__CMD__") {
+		t.Error("system prompt should request __CMD__ format")
+	}
+	if !strings.Contains(messages[0].Content, "linux") || !strings.Contains(messages[0].Content, "bash") {
+		t.Error("system prompt should contain OS and shell")
+	}
+	if messages[1].Content != task {
+		t.Errorf("user message should be the task, got %s", messages[1].Content)
+	}
+}
+
+func TestBuildBufferPrompt(t *testing.T) {
+	messages := BuildBufferPrompt("tar czf backup /home", "darwin", "zsh")
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0].Content, "darwin") || !strings.Contains(messages[0].Content, "zsh") {
+		t.Error("system prompt should contain OS and shell")
+	}
+	if messages[1].Content != "tar czf backup /home" {
+		t.Errorf("user message should be the buffer, got %s", messages[1].Content)
+	}
+}
+
+func TestBuildExplainGeneratedPrompt(t *testing.T) {
+	docs := []CommandDoc{
+		{Command: "find", Content: "FIND(1) search for files"},
+		{Command: "gzip", Content: "GZIP(1) compress files"},
+	}
+	messages := BuildExplainGeneratedPrompt("compress logs", "find . -name '*.log' -mtime +7 -exec gzip {} +", docs)
+
+	systemContent := messages[0].Content
+	for _, want := range []string{"compress logs", "-mtime +7", "MAN PAGE: find", "GZIP(1) compress files"} {
+		if !strings.Contains(systemContent, want) {
+			t.Errorf("system prompt should contain %q", want)
+		}
+	}
+
+	messages = BuildExplainGeneratedPrompt("task", "cmd", nil)
+	if !strings.Contains(messages[0].Content, "(no man pages available)") {
+		t.Error("missing docs should be marked")
+	}
+}
+
+func TestBuildExplainPrompt(t *testing.T) {
+	breakdown := "tar\n  -xvf  -x extract | -v verbose | -f archive\n"
+	docs := []CommandDoc{{Command: "tar", Content: "TAR(1) an archiving utility"}}
+
+	messages := BuildExplainPrompt("tar -xvf a.tar", breakdown, docs)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	for _, want := range []string{"tar -xvf a.tar", breakdown, "TAR(1) an archiving utility"} {
+		if !strings.Contains(messages[0].Content, want) {
+			t.Errorf("system prompt should contain %q", want)
+		}
+	}
+}
+
+func TestBuildReviewPrompt(t *testing.T) {
+	numbered := "   10| rm -rf $DIR/*\n"
+	docs := []CommandDoc{{Command: "rm", Content: "-r, -R, --recursive"}}
+
+	messages := BuildReviewPrompt("deploy.sh", "bash", numbered, 10, 10, 40, docs)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	for _, want := range []string{"bash script", "deploy.sh (lines 10-10 of 40)", numbered, "--recursive", "JSON array"} {
+		if !strings.Contains(messages[0].Content, want) {
+			t.Errorf("system prompt should contain %q", want)
+		}
+	}
+}
+
+func TestBuildDiscoverPrompt(t *testing.T) {
+	candidates := "- rsync(1): a fast, versatile, remote file-copying tool\n- scp(1): OpenSSH secure file copy\n"
+
+	messages := BuildDiscoverPrompt("copy a directory to another machine", candidates)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0].Content, candidates) {
+		t.Error("system prompt should contain the candidates")
+	}
+	if messages[1].Content != "copy a directory to another machine" {
+		t.Errorf("user message = %q", messages[1].Content)
+	}
+}
+
+func TestBuildSectionPrompt(t *testing.T) {
+	sections := "1: format and print data\n3: formatted output conversion\n"
+
+	messages := BuildSectionPrompt("printf", sections, "How do I print a C float with 2 decimals?")
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0].Content, `"printf"`) || !strings.Contains(messages[0].Content, sections) {
+		t.Error("system prompt should contain the command and its sections")
+	}
+	if messages[1].Content != "How do I print a C float with 2 decimals?" {
+		t.Errorf("user message = %q", messages[1].Content)
+	}
 }
 
 func TestBuildLogPrompt(t *testing.T) {
@@ -90,6 +267,32 @@ func TestBuildLogPrompt(t *testing.T) {
 	}
 }
 
+func TestExtractCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"tags", "__CMD__git pull --rebase__CMD__", "git pull --rebase"},
+		{"code block", "## Command\n```bash\nls -la\n```\n\n## Explanation", "ls -la"},
+		{"multi-line block", "```bash\nfind . -name '*.log' \\\n  -mtime +7 \\\n  -delete\n```\n", "find . -name '*.log' \\\n  -mtime +7 \\\n  -delete"},
+		{"first block only", "```sh\nmake\n```\nor\n```sh\nmake all\n```", "make"},
+		{"plain", "ls -la", "ls -la"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractCommand(tt.response)
+			if err != nil {
+				t.Fatalf("ExtractCommand() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestTruncateContent(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -126,3 +329,24 @@ func TestTruncateContent(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildComparePrompt(t *testing.T) {
+	docs := []CommandDoc{
+		{Command: "rsync", Content: "RSYNC(1)\n  --partial  keep partially transferred files"},
+		{Command: "scp", Content: "SCP(1)\n  -r  recursively copy entire directories"},
+	}
+	messages := BuildComparePrompt("rsync", "scp", "- rsync: rsync 3.2.7 at /usr/bin/rsync", "copy a directory", docs)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	system := messages[0].Content
+	for _, want := range []string{"between rsync and scp", "# rsync", "# scp", "MAN PAGE: rsync", "MAN PAGE: scp", "--partial", "rsync 3.2.7"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt should contain %q", want)
+		}
+	}
+	if messages[1].Content != "copy a directory" {
+		t.Errorf("user message = %q, want the question", messages[1].Content)
+	}
+}
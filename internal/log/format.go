@@ -0,0 +1,453 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is the line format of a log
+type Format string
+
+const (
+	FormatText       Format = "text"
+	FormatJSON       Format = "json"
+	FormatLogfmt     Format = "logfmt"
+	FormatSyslog     Format = "syslog"     // RFC 3164, as in /var/log/syslog
+	FormatSyslog5424 Format = "syslog5424" // RFC 5424
+	FormatAccess     Format = "access"     // nginx/apache common and combined
+	FormatKlog       Format = "klog"       // Kubernetes components
+)
+
+// detectSampleLines is how many lines format detection looks at
+const detectSampleLines = 50
+
+// lineParser parses a line in one format, reporting whether it matched
+type lineParser func(line string) (LogEntry, bool)
+
+// formatParsers are tried by DetectFormat, most specific first
+var formatParsers = []struct {
+	format Format
+	parse  lineParser
+}{
+	{FormatJSON, parseJSONLine},
+	{FormatSyslog5424, parseSyslog5424Line},
+	{FormatSyslog, parseSyslogLine},
+	{FormatAccess, parseAccessLine},
+	{FormatKlog, parseKlogLine},
+	{FormatLogfmt, parseLogfmtLine},
+}
+
+// levelWords are the level names found in free text as whole words, in
+// any case; shortLevelWords only count in upper case, so "err" in prose
+// or "error_count" don't count
+var (
+	levelWords      = map[string]bool{"fatal": true, "panic": true, "critical": true, "emergency": true, "error": true, "warning": true, "warn": true, "debug": true, "trace": true, "info": true, "notice": true}
+	shortLevelWords = map[string]bool{"CRIT": true, "EMERG": true, "ALERT": true, "ERR": true, "WRN": true, "ERRO": true, "DBG": true, "INF": true}
+)
+
+// findLevelWord returns the first whole word of line that names a level.
+// It scans words by hand rather than with a regexp, as it runs on every
+// line of large logs
+func findLevelWord(line string) string {
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && isWordByte(line[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		word := line[start:i]
+		start = -1
+		if len(word) < 3 || len(word) > 9 {
+			continue
+		}
+		if shortLevelWords[word] || levelWords[strings.ToLower(word)] {
+			return word
+		}
+	}
+	return ""
+}
+
+// isWordByte reports whether b is a word character, as \w is in regexps
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+var (
+	syslogRe     = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^\s:\[]+)(?:\[(\d+)\])?: ?(.*)$`)
+	syslog5424Re = regexp.MustCompile(`^<(\d{1,3})>1 (\S+) (\S+) (\S+) (\S+) (\S+) (-|(?:\[(?:[^\]"]|"(?:[^"\\]|\\.)*")*\])+) ?(.*)$`)
+	sdElementRe  = regexp.MustCompile(`\[([^\s\]]+)((?:\s+[^\s=\]]+="(?:[^"\\]|\\.)*")*)\]`)
+	sdParamRe    = regexp.MustCompile(`([^\s=\]]+)="((?:[^"\\]|\\.)*)"`)
+	accessRe     = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "([A-Z]+) (\S+)(?: ([^"]*))?" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+	klogRe       = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d{6})\s+(\d+) ([^\s\]]+:\d+)\] (.*)$`)
+	logfmtPairRe = regexp.MustCompile(`([A-Za-z_][\w.\-/]*)=("(?:[^"\\]|\\.)*"|\S*)`)
+)
+
+// Field names recognized in JSON and logfmt lines
+var (
+	levelKeys   = []string{"level", "lvl", "severity", "loglevel", "log.level", "@l", "levelname"}
+	messageKeys = []string{"msg", "message", "@m", "@mt", "event", "log"}
+	timeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "@t", "t", "datetime", "asctime"}
+	sourceKeys  = []string{"logger", "caller", "source", "name", "component", "service", "module"}
+)
+
+// DetectFormat returns the format most of the sampled lines are in, or
+// FormatText when no structured format fits at least half of them
+func DetectFormat(lines []string) Format {
+	var sample []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			sample = append(sample, line)
+		}
+		if len(sample) >= detectSampleLines {
+			break
+		}
+	}
+	if len(sample) == 0 {
+		return FormatText
+	}
+
+	best, bestCount := FormatText, 0
+	for _, p := range formatParsers {
+		count := 0
+		for _, line := range sample {
+			if _, ok := p.parse(line); ok {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = p.format, count
+		}
+	}
+	if bestCount*2 < len(sample) {
+		return FormatText
+	}
+	return best
+}
+
+// ParseLine parses a line in the given format. Lines that don't match it,
+// like a plain-text line in a JSON log, are parsed as free text.
+func ParseLine(line string, format Format) LogEntry {
+	return parseLineIn(line, format, orderUnknown)
+}
+
+// parseLineIn parses a line like ParseLine, reading numeric dates in the
+// order detected for its file
+func parseLineIn(line string, format Format, order dateOrder) LogEntry {
+	for _, p := range formatParsers {
+		if p.format != format {
+			continue
+		}
+		if entry, ok := p.parse(line); ok {
+			entry.Raw = line
+			entry.Time, _ = parseTimeValueIn(entry.Timestamp, order)
+			return entry
+		}
+	}
+	return parseLine(line, order)
+}
+
+// levelFromName maps a level name or abbreviation to a LogLevel
+func levelFromName(name string) (LogLevel, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "fatal", "panic", "critical", "crit", "emergency", "emerg", "alert", "dpanic", "f":
+		return LevelFatal, true
+	case "error", "err", "erro", "e", "severe":
+		return LevelError, true
+	case "warning", "warn", "wrn", "w":
+		return LevelWarn, true
+	case "debug", "dbg", "trace", "verbose", "d", "fine", "finer", "finest":
+		return LevelDebug, true
+	case "info", "inf", "information", "notice", "i", "informational":
+		return LevelInfo, true
+	}
+	return "", false
+}
+
+// levelFromNumber maps bunyan/pino numeric levels to a LogLevel
+func levelFromNumber(n float64) LogLevel {
+	switch {
+	case n >= 60:
+		return LevelFatal
+	case n >= 50:
+		return LevelError
+	case n >= 40:
+		return LevelWarn
+	case n >= 30:
+		return LevelInfo
+	}
+	return LevelDebug
+}
+
+// levelFromSyslog maps a syslog priority to a LogLevel by its severity
+func levelFromSyslog(pri int) LogLevel {
+	switch pri % 8 {
+	case 0, 1, 2:
+		return LevelFatal
+	case 3:
+		return LevelError
+	case 4:
+		return LevelWarn
+	case 7:
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+// parseJSONLine parses a JSON object per line, as written by zap, logrus,
+// slog, bunyan, pino and most structured loggers
+func parseJSONLine(line string) (LogEntry, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return LogEntry{}, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return LogEntry{}, false
+	}
+
+	attrs := make(map[string]string)
+	flattenJSON("", fields, attrs)
+
+	entry := LogEntry{Level: LevelInfo}
+	if key, value, ok := takeField(attrs, levelKeys); ok {
+		if level, known := levelFromName(value); known {
+			entry.Level = level
+		} else if n, err := strconv.ParseFloat(value, 64); err == nil {
+			entry.Level = levelFromNumber(n)
+		} else {
+			attrs[key] = value
+		}
+	}
+	_, entry.Message, _ = takeField(attrs, messageKeys)
+	_, entry.Timestamp, _ = takeField(attrs, timeKeys)
+	_, entry.Source, _ = takeField(attrs, sourceKeys)
+	if len(attrs) > 0 {
+		entry.Attrs = attrs
+	}
+	return entry, true
+}
+
+// flattenJSON flattens nested objects into dotted keys with string values
+func flattenJSON(prefix string, fields map[string]any, attrs map[string]string) {
+	for key, value := range fields {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flattenJSON(key, v, attrs)
+		case string:
+			attrs[key] = v
+		case nil:
+			attrs[key] = "null"
+		case float64:
+			attrs[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			data, _ := json.Marshal(v)
+			attrs[key] = string(data)
+		}
+	}
+}
+
+// takeField removes and returns the first of keys present in attrs
+func takeField(attrs map[string]string, keys []string) (string, string, bool) {
+	for _, key := range keys {
+		if value, ok := attrs[key]; ok {
+			delete(attrs, key)
+			return key, value, true
+		}
+	}
+	return "", "", false
+}
+
+// parseLogfmt parses key=value pairs, with double-quoted values
+func parseLogfmt(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range logfmtPairRe.FindAllStringSubmatch(s, -1) {
+		value := m[2]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		attrs[m[1]] = value
+	}
+	return attrs
+}
+
+// parseLogfmtLine parses a logfmt line like
+// time=2025-02-01T10:00:00Z level=error msg="db down" retries=3
+func parseLogfmtLine(line string) (LogEntry, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !logfmtPairRe.MatchString(trimmed) || logfmtPairRe.FindStringIndex(trimmed)[0] != 0 {
+		return LogEntry{}, false
+	}
+	attrs := parseLogfmt(trimmed)
+	if len(attrs) < 2 {
+		return LogEntry{}, false
+	}
+
+	entry := LogEntry{Level: LevelInfo}
+	if key, value, ok := takeField(attrs, levelKeys); ok {
+		if level, known := levelFromName(value); known {
+			entry.Level = level
+		} else {
+			attrs[key] = value
+		}
+	}
+	_, entry.Message, _ = takeField(attrs, messageKeys)
+	_, entry.Timestamp, _ = takeField(attrs, timeKeys)
+	_, entry.Source, _ = takeField(attrs, sourceKeys)
+	if len(attrs) > 0 {
+		entry.Attrs = attrs
+	}
+	return entry, true
+}
+
+// parseSyslogLine parses an RFC 3164 line like
+// Feb  1 10:00:00 web1 sshd[1234]: Failed password for root
+func parseSyslogLine(line string) (LogEntry, bool) {
+	m := syslogRe.FindStringSubmatch(line)
+	if m == nil {
+		return LogEntry{}, false
+	}
+	entry := LogEntry{
+		Timestamp: m[2],
+		Source:    m[4],
+		Message:   m[6],
+		Attrs:     map[string]string{"host": m[3]},
+	}
+	if m[5] != "" {
+		entry.Attrs["pid"] = m[5]
+	}
+	if m[1] != "" {
+		pri, _ := strconv.Atoi(m[1])
+		entry.Level = levelFromSyslog(pri)
+	} else {
+		entry.Level = parseLevel(m[6])
+	}
+	return entry, true
+}
+
+// parseSyslog5424Line parses an RFC 5424 line like
+// <165>1 2025-02-01T10:00:00Z web1 app 1234 ID47 [exampleSDID@32473 iut="3"] msg
+func parseSyslog5424Line(line string) (LogEntry, bool) {
+	m := syslog5424Re.FindStringSubmatch(line)
+	if m == nil {
+		return LogEntry{}, false
+	}
+	pri, _ := strconv.Atoi(m[1])
+	entry := LogEntry{
+		Timestamp: nilValue(m[2]),
+		Level:     levelFromSyslog(pri),
+		Source:    nilValue(m[4]),
+		Message:   strings.TrimPrefix(m[8], "\ufeff"),
+		Attrs:     make(map[string]string),
+	}
+	for key, value := range map[string]string{"host": m[3], "pid": m[5], "msgid": m[6]} {
+		if v := nilValue(value); v != "" {
+			entry.Attrs[key] = v
+		}
+	}
+	for _, element := range sdElementRe.FindAllStringSubmatch(m[7], -1) {
+		for _, param := range sdParamRe.FindAllStringSubmatch(element[2], -1) {
+			entry.Attrs[element[1]+"."+param[1]] = strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\]`, `]`).Replace(param[2])
+		}
+	}
+	return entry, true
+}
+
+// nilValue turns RFC 5424's "-" for a missing field into ""
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// parseAccessLine parses nginx/apache common and combined log lines like
+// 10.0.0.1 - - [01/Feb/2025:10:00:00 +0000] "GET /api HTTP/1.1" 502 157 "-" "curl/8.0"
+func parseAccessLine(line string) (LogEntry, bool) {
+	m := accessRe.FindStringSubmatch(line)
+	if m == nil {
+		return LogEntry{}, false
+	}
+	status, _ := strconv.Atoi(m[7])
+	entry := LogEntry{
+		Timestamp: m[3],
+		Level:     LevelInfo,
+		Message:   fmt.Sprintf("%s %s %s", m[4], m[5], m[7]),
+		Attrs: map[string]string{
+			"remote_addr": m[1],
+			"method":      m[4],
+			"path":        m[5],
+			"status":      m[7],
+		},
+	}
+	switch {
+	case status >= 500:
+		entry.Level = LevelError
+	case status >= 400:
+		entry.Level = LevelWarn
+	}
+	optional := map[string]string{"user": m[2], "protocol": m[6], "bytes": m[8], "referer": m[9], "user_agent": m[10]}
+	for key, value := range optional {
+		if value != "" && value != "-" {
+			entry.Attrs[key] = value
+		}
+	}
+	return entry, true
+}
+
+// parseKlogLine parses a klog line like
+// E0201 10:00:00.123456    1234 reflector.go:138] "Failed to watch" err="timeout"
+func parseKlogLine(line string) (LogEntry, bool) {
+	m := klogRe.FindStringSubmatch(line)
+	if m == nil {
+		return LogEntry{}, false
+	}
+	level, _ := levelFromName(m[1])
+	entry := LogEntry{
+		Timestamp: m[2],
+		Level:     level,
+		Source:    m[4],
+		Message:   m[5],
+		Attrs:     map[string]string{"thread": m[3]},
+	}
+	// Structured klog: a quoted message followed by key=value pairs
+	if strings.HasPrefix(m[5], `"`) {
+		if quoted, err := strconv.QuotedPrefix(m[5]); err == nil {
+			entry.Message, _ = strconv.Unquote(quoted)
+			for key, value := range parseLogfmt(m[5][len(quoted):]) {
+				entry.Attrs[key] = value
+			}
+		}
+	}
+	return entry, true
+}
+
+// formatAttrs renders attributes as sorted key=value pairs
+func formatAttrs(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		value := attrs[key]
+		if value == "" || strings.ContainsAny(value, " \t\"=") {
+			value = strconv.Quote(value)
+		}
+		parts[i] = key + "=" + value
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,12 +1,13 @@
 package log
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // LogLevel represents log level
@@ -23,9 +24,20 @@ const (
 // LogEntry represents a parsed log entry
 type LogEntry struct {
 	Timestamp string
-	Level     LogLevel
-	Message   string
-	Raw       string
+	// Time is Timestamp parsed; zero when the entry has none
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	Raw     string
+	// Source is the program, logger or file:line that wrote the entry
+	Source string
+	// Attrs holds the entry's other fields, like a JSON line's keys
+	Attrs map[string]string
+	// Lines counts the continuation lines, like a stack trace, that were
+	// grouped into the entry after its first line
+	Lines int
+	// tail holds the last lines of a trace too long to keep in Raw
+	tail []string
 }
 
 // LogType represents the type of log file
@@ -43,6 +55,9 @@ const (
 type Analyzer struct {
 	filePath string
 	logType  LogType
+	window   Window
+	tail     int
+	rotated  bool
 }
 
 // New creates a new log analyzer
@@ -53,8 +68,26 @@ func New(filePath string) *Analyzer {
 	}
 }
 
+// SetWindow limits the analysis to entries inside w
+func (a *Analyzer) SetWindow(w Window) {
+	a.window = w
+}
+
+// SetTail limits the analysis to the last n lines, read from the end of
+// the file without going through the rest
+func (a *Analyzer) SetTail(n int) {
+	a.tail = n
+}
+
+// SetRotated includes the rotated files of the log, like app.log.1 and
+// app.log.2.gz, oldest first
+func (a *Analyzer) SetRotated(rotated bool) {
+	a.rotated = rotated
+}
+
 // GetJournalctlLogs retrieves logs from journalctl for a specific service unit
-func GetJournalctlLogs(unit string, limit int) (string, error) {
+// and time window
+func GetJournalctlLogs(unit string, limit int, window Window) (string, error) {
 	// Check if journalctl exists
 	if _, err := exec.LookPath("journalctl"); err != nil {
 		return "", fmt.Errorf("journalctl not found: %w", err)
@@ -68,6 +101,15 @@ func GetJournalctlLogs(unit string, limit int) (string, error) {
 		args = append(args, "-n", fmt.Sprintf("%d", limit))
 	}
 
+	// Let journalctl apply the window, it indexes by time
+	const journalTime = "2006-01-02 15:04:05"
+	if !window.Since.IsZero() {
+		args = append(args, "--since", window.Since.Format(journalTime))
+	}
+	if !window.Until.IsZero() {
+		args = append(args, "--until", window.Until.Format(journalTime))
+	}
+
 	// Execute journalctl
 	cmd := exec.Command("journalctl", args...)
 	var stdout, stderr bytes.Buffer
@@ -141,72 +183,132 @@ func AnalyzeFile(filePath string, limit int) (*AnalysisResult, error) {
 	return analyzer.Analyze(limit)
 }
 
-// Analyze analyzes the log file and returns key findings
+// Analyze analyzes the log file and returns key findings. The file is
+// streamed, so its size is not bounded by memory; limit stops after that
+// many entries
 func (a *Analyzer) Analyze(limit int) (*AnalysisResult, error) {
 	// Detect log type
 	a.detectType()
 
+	files := []string{a.filePath}
+	if a.rotated {
+		var err error
+		if files, err = RotatedFiles(a.filePath); err != nil {
+			return nil, err
+		}
+	}
+
 	// Read and parse log entries
-	entries, err := a.readEntries(limit)
-	if err != nil {
+	c := newCollector(a.window, limit)
+	stream := newEntryStream(c.add)
+	if err := a.readEntries(files, stream); err != nil {
 		return nil, err
 	}
+	stream.close()
 
-	// Analyze entries
-	result := &AnalysisResult{
-		LogType:  a.logType,
-		Total:    len(entries),
-		ByLevel:  make(map[LogLevel]int),
-		Samples:  getSampleEntries(entries, 20),
-		Errors:   filterByLevel(entries, []LogLevel{LevelError, LevelFatal}),
-		Warnings: filterByLevel(entries, []LogLevel{LevelWarn}),
-	}
+	result := c.finish(a.logType, stream.format)
+	result.Files = files
+	return result, nil
+}
 
-	for _, entry := range entries {
-		result.ByLevel[entry.Level]++
+// AnalyzeReader analyzes the log lines read from r, like a pipe, the same
+// way Analyze streams a file: with the window and tail set on the analyzer
+// and stopping after limit entries
+func (a *Analyzer) AnalyzeReader(r io.Reader, limit int) (*AnalysisResult, error) {
+	a.detectType()
+
+	c := newCollector(a.window, limit)
+	stream := newEntryStream(c.add)
+	if a.tail > 0 {
+		lines, err := tailReader(r, a.tail)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if !stream.add(line) {
+				break
+			}
+		}
+	} else if err := readLines(r, stream.add); err != nil {
+		return nil, err
 	}
+	stream.close()
 
-	return result, nil
+	return c.finish(a.logType, stream.format), nil
 }
 
 // AnalyzeString analyzes log content from string
 func AnalyzeString(content string) *AnalysisResult {
-	lines := strings.Split(content, "\n")
-	entries := make([]LogEntry, 0)
+	return AnalyzeStringWithin(content, Window{})
+}
 
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
+// AnalyzeStringWithin analyzes the entries of content that fall inside window
+func AnalyzeStringWithin(content string, window Window) *AnalysisResult {
+	c := newCollector(window, 0)
+	stream := newEntryStream(c.add)
+	_ = readLines(strings.NewReader(content), stream.add)
+	stream.close()
+	return c.finish(TypeApplication, stream.format)
+}
 
-		entry := parseLine(line)
-		entries = append(entries, entry)
+// typeOfFormat refines a log type guessed from the file name with what
+// the lines turned out to be
+func typeOfFormat(logType LogType, format Format) LogType {
+	if logType != TypeApplication {
+		return logType
 	}
-
-	result := &AnalysisResult{
-		LogType:  TypeApplication,
-		Total:    len(entries),
-		ByLevel:  make(map[LogLevel]int),
-		Samples:  getSampleEntries(entries, 20),
-		Errors:   filterByLevel(entries, []LogLevel{LevelError, LevelFatal}),
-		Warnings: filterByLevel(entries, []LogLevel{LevelWarn}),
+	switch format {
+	case FormatAccess:
+		return TypeAccess
+	case FormatSyslog, FormatSyslog5424:
+		return TypeSystem
 	}
+	return logType
+}
 
-	for _, entry := range entries {
-		result.ByLevel[entry.Level]++
+// parseEntries detects the format of lines and parses the non-empty ones,
+// grouping stack traces and other continuation lines into the entry that
+// precedes them
+func parseEntries(lines []string) (Format, []LogEntry) {
+	var entries []LogEntry
+	stream := newEntryStream(func(entry LogEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	for _, line := range lines {
+		stream.add(line)
 	}
+	stream.close()
 
-	return result
+	return stream.format, entries
 }
 
 // AnalysisResult represents the analysis result
 type AnalysisResult struct {
-	LogType  LogType
-	Total    int
-	ByLevel  map[LogLevel]int
-	Samples  []LogEntry
-	Errors   []LogEntry
-	Warnings []LogEntry
+	LogType LogType
+	Format  Format
+	Total   int
+	ByLevel map[LogLevel]int
+	// Templates clusters the messages, most frequent first
+	Templates []*Template
+	// Window is the time window the entries were limited to, if any
+	Window Window
+	// First and Last are the earliest and latest entry timestamps
+	First time.Time
+	Last  time.Time
+	// Files are the files read, oldest first when rotated logs were
+	// included
+	Files []string
+}
+
+// ErrorCount returns the number of error and fatal entries
+func (r *AnalysisResult) ErrorCount() int {
+	return r.ByLevel[LevelError] + r.ByLevel[LevelFatal]
+}
+
+// WarningCount returns the number of warning entries
+func (r *AnalysisResult) WarningCount() int {
+	return r.ByLevel[LevelWarn]
 }
 
 // ToFormat converts the result to a formatted string for AI analysis
@@ -215,6 +317,18 @@ func (r *AnalysisResult) ToFormat() string {
 
 	sb.WriteString(fmt.Sprintf("## Log Analysis Summary\n\n"))
 	sb.WriteString(fmt.Sprintf("Log Type: %s\n", r.LogType))
+	if r.Format != "" {
+		sb.WriteString(fmt.Sprintf("Log Format: %s\n", r.Format))
+	}
+	if len(r.Files) > 1 {
+		sb.WriteString(fmt.Sprintf("Files: %s\n", strings.Join(r.Files, ", ")))
+	}
+	if !r.Window.IsZero() {
+		sb.WriteString(fmt.Sprintf("Time Window: %s\n", r.Window))
+	}
+	if !r.First.IsZero() {
+		sb.WriteString(fmt.Sprintf("Time Range: %s to %s\n", r.First.Format(time.RFC3339), r.Last.Format(time.RFC3339)))
+	}
 	sb.WriteString(fmt.Sprintf("Total Entries: %d\n\n", r.Total))
 
 	sb.WriteString("## Statistics by Level\n")
@@ -225,62 +339,84 @@ func (r *AnalysisResult) ToFormat() string {
 	}
 	sb.WriteString("\n")
 
-	if len(r.Errors) > 0 {
-		sb.WriteString(fmt.Sprintf("## Error Entries (%d)\n", len(r.Errors)))
-		errorSamples := getSampleEntries(r.Errors, 10)
-		for _, entry := range errorSamples {
-			sb.WriteString(fmt.Sprintf("%s\n\n", entry.Raw))
+	// One example per template, rarest first, so that a one-off failure
+	// is not crowded out by a noisy one
+	errorTemplates := r.templatesOf(LevelError, LevelFatal)
+	if r.ErrorCount() > 0 {
+		sb.WriteString(fmt.Sprintf("## Error Entries (%d in %d templates)\n", r.ErrorCount(), len(errorTemplates)))
+		for _, t := range rarestFirst(errorTemplates, 10) {
+			sb.WriteString(fmt.Sprintf("%s\n\n", t.Example.Display()))
 		}
 		sb.WriteString("\n")
 	}
 
-	if len(r.Warnings) > 0 {
-		sb.WriteString(fmt.Sprintf("## Warning Entries (%d)\n", len(r.Warnings)))
-		warnSamples := getSampleEntries(r.Warnings, 5)
-		for _, entry := range warnSamples {
-			sb.WriteString(fmt.Sprintf("%s\n\n", entry.Raw))
+	warnTemplates := r.templatesOf(LevelWarn)
+	if r.WarningCount() > 0 {
+		sb.WriteString(fmt.Sprintf("## Warning Entries (%d in %d templates)\n", r.WarningCount(), len(warnTemplates)))
+		for _, t := range rarestFirst(warnTemplates, 5) {
+			sb.WriteString(fmt.Sprintf("%s\n\n", t.Example.Display()))
 		}
 		sb.WriteString("\n")
 	}
 
-	sb.WriteString("## Sample Log Entries\n")
-	for _, entry := range r.Samples {
-		sb.WriteString(fmt.Sprintf("%s\n\n", entry.Raw))
+	sb.WriteString(fmt.Sprintf("## Message Templates (%d)\n", len(r.Templates)))
+	sb.WriteString("Messages clustered by shape; <*> marks variable parts.\n")
+	shown := append(pickTemplates(errorTemplates, 15), pickTemplates(warnTemplates, 10)...)
+	shown = append(shown, pickTemplates(r.templatesOf(LevelInfo, LevelDebug), 10)...)
+	for _, t := range shown {
+		sb.WriteString(t.describe())
 	}
 
 	return sb.String()
 }
 
-// readEntries reads log entries from file
-func (a *Analyzer) readEntries(limit int) ([]LogEntry, error) {
-	file, err := os.Open(a.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+// readEntries streams the lines of files into stream, oldest file first,
+// or only their last lines with a tail
+func (a *Analyzer) readEntries(files []string, stream *entryStream) error {
+	if a.tail > 0 {
+		lines, err := tailFiles(files, a.tail)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if !stream.add(line) {
+				break
+			}
+		}
+		return nil
 	}
-	defer file.Close()
-
-	var entries []LogEntry
-	scanner := bufio.NewScanner(file)
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
+	for _, path := range files {
+		r, err := openLog(path)
+		if err != nil {
+			return err
 		}
-
-		entry := parseLine(line)
-		entries = append(entries, entry)
-
-		if limit > 0 && len(entries) >= limit {
+		err = readLines(r, stream.add)
+		if cerr := r.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if stream.done {
 			break
 		}
 	}
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read log file: %w", err)
+// tailFiles returns the last n lines across files, going back into older
+// files when the newest has fewer
+func tailFiles(files []string, n int) ([]string, error) {
+	var lines []string
+	for i := len(files) - 1; i >= 0 && len(lines) < n; i-- {
+		older, err := tailLines(files[i], n-len(lines))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(older, lines...)
 	}
-
-	return entries, nil
+	return lines, nil
 }
 
 // detectType detects the type of log file
@@ -297,62 +433,59 @@ func (a *Analyzer) detectType() {
 }
 
 // parseLine parses a log line
-func parseLine(line string) LogEntry {
+func parseLine(line string, order dateOrder) LogEntry {
 	level := parseLevel(line)
+	timestamp, t, _ := findTimestampIn(line, order)
 
 	return LogEntry{
-		Timestamp: parseTimestamp(line),
+		Timestamp: timestamp,
+		Time:      t,
 		Level:     level,
 		Message:   parseMessage(line),
 		Raw:       line,
 	}
 }
 
-// parseLevel parses log level from line
-func parseLevel(line string) LogLevel {
-	lower := strings.ToLower(line)
-
-	for _, level := range []LogLevel{LevelFatal, LevelError, LevelWarn, LevelDebug, LevelInfo} {
-		if strings.Contains(lower, strings.ToLower(string(level))) {
-			return level
-		}
+// Display renders the entry for the prompt: text lines as they are,
+// structured ones as level, time, source and message followed by their
+// attributes, which reads better than raw JSON. A grouped stack trace
+// follows on the next lines, shortened when it is long
+func (e LogEntry) Display() string {
+	first, _, _ := strings.Cut(e.Raw, "\n")
+	if e.Source != "" || len(e.Attrs) > 0 {
+		first = e.header()
 	}
-
-	return LevelInfo
+	return strings.Join(append([]string{first}, e.continuation()...), "\n")
 }
 
-// parseTimestamp extracts timestamp from log line
-func parseTimestamp(line string) string {
-	// Common timestamp patterns
-	patterns := []string{
-		`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`,
-		`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`,
-		`\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}`,
-		`\w{3} \d{1,2} \d{2}:\d{2}:\d{2}`,
+// header renders a structured entry on one line
+func (e LogEntry) header() string {
+	parts := []string{string(e.Level)}
+	if e.Timestamp != "" {
+		parts = append(parts, e.Timestamp)
 	}
-
-	for _, pattern := range patterns {
-		if strings.Contains(line, pattern[:6]) {
-			return extractTimestampByPattern(line, pattern[:6])
-		}
+	if e.Source != "" {
+		parts = append(parts, "["+e.Source+"]")
 	}
-
-	return ""
-}
-
-func extractTimestampByPattern(line, pattern string) string {
-	idx := strings.Index(line, pattern)
-	if idx == -1 {
-		return ""
+	if e.Message != "" {
+		parts = append(parts, e.Message)
 	}
+	if len(e.Attrs) > 0 {
+		parts = append(parts, formatAttrs(e.Attrs))
+	}
+	return strings.Join(parts, " ")
+}
 
-	// Extract up to 30 chars after pattern match
-	end := idx + 30
-	if end > len(line) {
-		end = len(line)
+// parseLevel parses log level from line, taking the first level name
+// that appears as a whole word
+func parseLevel(line string) LogLevel {
+	if m := findLevelWord(line); m != "" {
+		if level, ok := levelFromName(m); ok {
+			return level
+		}
 	}
 
-	return strings.TrimSpace(line[idx:end])
+	return LevelInfo
 }
 
 // parseMessage extracts message from log line
@@ -389,42 +522,6 @@ func parseMessage(line string) string {
 	return line
 }
 
-// filterByLevel filters entries by log level
-func filterByLevel(entries []LogEntry, levels []LogLevel) []LogEntry {
-	var filtered []LogEntry
-
-	for _, entry := range entries {
-		for _, level := range levels {
-			if entry.Level == level {
-				filtered = append(filtered, entry)
-				break
-			}
-		}
-	}
-
-	return filtered
-}
-
-// ReadFromStdin reads log content from stdin (for pipe support)
-func ReadFromStdin() (string, error) {
-	stat, err := os.Stdin.Stat()
-	if err != nil {
-		return "", err
-	}
-
-	// Check if stdin is a pipe or redirected file
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		// It's a pipe or redirected file
-		var buffer bytes.Buffer
-		if _, err := buffer.ReadFrom(os.Stdin); err != nil {
-			return "", fmt.Errorf("failed to read from stdin: %w", err)
-		}
-		return buffer.String(), nil
-	}
-
-	return "", nil
-}
-
 // IsPipedInput checks if there is piped input
 func IsPipedInput() bool {
 	stat, err := os.Stdin.Stat()
@@ -435,24 +532,3 @@ func IsPipedInput() bool {
 	// If not a character device, it's likely a pipe or redirect
 	return (stat.Mode() & os.ModeCharDevice) == 0
 }
-
-// getSampleEntries returns sample entries
-func getSampleEntries(entries []LogEntry, max int) []LogEntry {
-	if len(entries) <= max {
-		return entries
-	}
-
-	// Return samples from beginning, middle, and end
-	samples := make([]LogEntry, 0, max)
-
-	begin := max / 3
-	middle := len(entries) / 2
-
-	samples = append(samples, entries[:begin]...)
-	if middle < len(entries)-begin {
-		samples = append(samples, entries[middle:middle+begin]...)
-	}
-	samples = append(samples, entries[len(entries)-begin:]...)
-
-	return samples
-}
@@ -21,6 +21,10 @@ func TestParseLevel(t *testing.T) {
 		{"debug log", "2025-02-01 DEBUG: Debug message", LevelDebug},
 		{"fatal log", "2025-02-01 FATAL: Fatal error", LevelFatal},
 		{"unknown log", "Just a log message", LevelInfo},
+		{"level inside a word", `{"error_count": 0, "msg": "all good"}`, LevelInfo},
+		{"first level wins", "INFO retrying after error", LevelInfo},
+		{"warning spelled out", "Warning: disk almost full", LevelWarn},
+		{"short form", "[ERR] cannot bind port", LevelError},
 	}
 
 	for _, tt := range tests {
@@ -60,12 +64,12 @@ func TestAnalyzeString(t *testing.T) {
 		t.Errorf("Expected 1 warning, got %d", result.ByLevel[LevelWarn])
 	}
 
-	if len(result.Errors) != 2 { // ERROR + FATAL
-		t.Errorf("Expected 2 error entries, got %d", len(result.Errors))
+	if result.ErrorCount() != 2 { // ERROR + FATAL
+		t.Errorf("Expected 2 error entries, got %d", result.ErrorCount())
 	}
 
-	if len(result.Warnings) != 1 {
-		t.Errorf("Expected 1 warning entry, got %d", len(result.Warnings))
+	if result.WarningCount() != 1 {
+		t.Errorf("Expected 1 warning entry, got %d", result.WarningCount())
 	}
 }
 
@@ -95,12 +99,12 @@ func TestAnalyzeFile(t *testing.T) {
 		t.Errorf("Expected 5 entries, got %d", result.Total)
 	}
 
-	if len(result.Errors) != 2 {
-		t.Errorf("Expected 2 errors, got %d", len(result.Errors))
+	if result.ErrorCount() != 2 {
+		t.Errorf("Expected 2 errors, got %d", result.ErrorCount())
 	}
 
-	if len(result.Warnings) != 1 {
-		t.Errorf("Expected 1 warning, got %d", len(result.Warnings))
+	if result.WarningCount() != 1 {
+		t.Errorf("Expected 1 warning, got %d", result.WarningCount())
 	}
 }
 
@@ -247,7 +251,7 @@ func TestGetJournalctlLogs(t *testing.T) {
 	}
 
 	// Test with a common systemd unit (systemd itself should always exist)
-	_, err := GetJournalctlLogs("systemd-journald.service", 5)
+	_, err := GetJournalctlLogs("systemd-journald.service", 5, Window{})
 	if err != nil {
 		t.Logf("GetJournalctlLogs returned error (unit may not exist): %v", err)
 	}
@@ -275,11 +279,203 @@ func TestAnalyzeStringWithPipeLikeInput(t *testing.T) {
 		t.Errorf("Expected 3 entries, got %d", result.Total)
 	}
 
-	if len(result.Errors) != 1 {
-		t.Errorf("Expected 1 error, got %d", len(result.Errors))
+	if result.ErrorCount() != 1 {
+		t.Errorf("Expected 1 error, got %d", result.ErrorCount())
 	}
 
-	if len(result.Warnings) != 1 {
-		t.Errorf("Expected 1 warning, got %d", len(result.Warnings))
+	if result.WarningCount() != 1 {
+		t.Errorf("Expected 1 warning, got %d", result.WarningCount())
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  Format
+	}{
+		{
+			name:  "json",
+			lines: []string{`{"level":"info","msg":"started"}`, `{"level":"error","msg":"db down"}`},
+			want:  FormatJSON,
+		},
+		{
+			name:  "logfmt",
+			lines: []string{`time=2025-02-01T10:00:00Z level=info msg="started"`, `time=2025-02-01T10:00:01Z level=error msg="db down"`},
+			want:  FormatLogfmt,
+		},
+		{
+			name:  "syslog",
+			lines: []string{"Feb  1 10:00:00 web1 sshd[1234]: Accepted publickey for deploy", "Feb  1 10:00:01 web1 CRON[99]: (root) CMD (run-parts)"},
+			want:  FormatSyslog,
+		},
+		{
+			name:  "syslog 5424",
+			lines: []string{`<165>1 2025-02-01T10:00:00.003Z web1 app 1234 ID47 [exampleSDID@32473 iut="3"] started`},
+			want:  FormatSyslog5424,
+		},
+		{
+			name:  "access",
+			lines: []string{`10.0.0.1 - - [01/Feb/2025:10:00:00 +0000] "GET / HTTP/1.1" 200 612 "-" "curl/8.0"`},
+			want:  FormatAccess,
+		},
+		{
+			name:  "klog",
+			lines: []string{`I0201 10:00:00.123456       1 main.go:42] Starting controller`},
+			want:  FormatKlog,
+		},
+		{
+			name:  "text",
+			lines: []string{"2025-02-01 10:00:00 INFO Application started", "2025-02-01 10:00:01 ERROR Database connection failed"},
+			want:  FormatText,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.lines); got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		format  Format
+		level   LogLevel
+		message string
+		source  string
+		attrs   map[string]string
+	}{
+		{
+			name:    "json with nested fields",
+			line:    `{"time":"2025-02-01T10:00:00Z","level":"error","msg":"query failed","logger":"db","error_count":0,"req":{"id":"abc"}}`,
+			format:  FormatJSON,
+			level:   LevelError,
+			message: "query failed",
+			source:  "db",
+			attrs:   map[string]string{"error_count": "0", "req.id": "abc"},
+		},
+		{
+			name:    "json numeric level",
+			line:    `{"level":50,"time":1738404000000,"msg":"request failed"}`,
+			format:  FormatJSON,
+			level:   LevelError,
+			message: "request failed",
+		},
+		{
+			name:    "logfmt",
+			line:    `ts=2025-02-01T10:00:00Z level=warn msg="slow query" duration=3.2s table=users`,
+			format:  FormatLogfmt,
+			level:   LevelWarn,
+			message: "slow query",
+			attrs:   map[string]string{"duration": "3.2s", "table": "users"},
+		},
+		{
+			name:    "syslog with priority",
+			line:    "<11>Feb  1 10:00:00 web1 app[42]: disk quota exceeded",
+			format:  FormatSyslog,
+			level:   LevelError,
+			message: "disk quota exceeded",
+			source:  "app",
+			attrs:   map[string]string{"host": "web1", "pid": "42"},
+		},
+		{
+			name:    "syslog level from message",
+			line:    "Feb  1 10:00:00 web1 kernel: Out of memory: Killed process 1234 (java) error",
+			format:  FormatSyslog,
+			level:   LevelError,
+			message: "Out of memory: Killed process 1234 (java) error",
+			source:  "kernel",
+		},
+		{
+			name:    "syslog 5424 structured data",
+			line:    `<165>1 2025-02-01T10:00:00.003Z web1 app 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"] started`,
+			format:  FormatSyslog5424,
+			level:   LevelInfo,
+			message: "started",
+			source:  "app",
+			attrs:   map[string]string{"host": "web1", "exampleSDID@32473.iut": "3", "exampleSDID@32473.eventSource": "Application"},
+		},
+		{
+			name:    "access log server error",
+			line:    `10.0.0.1 - alice [01/Feb/2025:10:00:00 +0000] "POST /api/orders HTTP/1.1" 502 157 "-" "curl/8.0"`,
+			format:  FormatAccess,
+			level:   LevelError,
+			message: "POST /api/orders 502",
+			attrs:   map[string]string{"remote_addr": "10.0.0.1", "user": "alice", "status": "502", "user_agent": "curl/8.0"},
+		},
+		{
+			name:    "access log not found",
+			line:    `10.0.0.1 - - [01/Feb/2025:10:00:00 +0000] "GET /missing HTTP/1.1" 404 0`,
+			format:  FormatAccess,
+			level:   LevelWarn,
+			message: "GET /missing 404",
+		},
+		{
+			name:    "structured klog",
+			line:    `E0201 10:00:00.123456    1234 reflector.go:138] "Failed to watch" err="context deadline exceeded" resource="pods"`,
+			format:  FormatKlog,
+			level:   LevelError,
+			message: "Failed to watch",
+			source:  "reflector.go:138",
+			attrs:   map[string]string{"thread": "1234", "err": "context deadline exceeded", "resource": "pods"},
+		},
+		{
+			name:    "text line in a json log",
+			line:    "panic: runtime error: index out of range",
+			format:  FormatJSON,
+			level:   LevelFatal,
+			message: "panic: runtime error: index out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := ParseLine(tt.line, tt.format)
+			if entry.Level != tt.level {
+				t.Errorf("Level = %v, want %v", entry.Level, tt.level)
+			}
+			if !strings.Contains(entry.Message, tt.message) {
+				t.Errorf("Message = %q, want %q", entry.Message, tt.message)
+			}
+			if entry.Source != tt.source {
+				t.Errorf("Source = %q, want %q", entry.Source, tt.source)
+			}
+			for key, want := range tt.attrs {
+				if got := entry.Attrs[key]; got != want {
+					t.Errorf("Attrs[%q] = %q, want %q", key, got, want)
+				}
+			}
+			if entry.Raw != tt.line {
+				t.Errorf("Raw = %q, want the line", entry.Raw)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStringJSON(t *testing.T) {
+	content := `{"level":"info","msg":"started","error_count":0}
+{"level":"info","msg":"healthy","error_count":0}
+{"level":"error","msg":"db down","retries":3}
+`
+	result := AnalyzeString(content)
+
+	if result.Format != FormatJSON {
+		t.Errorf("Format = %v, want json", result.Format)
+	}
+	if result.ByLevel[LevelError] != 1 || result.ByLevel[LevelInfo] != 2 {
+		t.Errorf("ByLevel = %v, want 1 error and 2 info", result.ByLevel)
+	}
+
+	formatted := result.ToFormat()
+	if !strings.Contains(formatted, "Log Format: json") {
+		t.Error("Formatted output should name the log format")
+	}
+	if !strings.Contains(formatted, "ERROR db down retries=3") {
+		t.Errorf("Formatted output should show structured entries, got:\n%s", formatted)
 	}
 }
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// displayHead and displayTail bound how much of a long stack trace goes
+// into the prompt; the end is kept because that is where Python puts the
+// exception and Java the root cause
+const (
+	displayHead = 20
+	displayTail = 10
+)
+
+var (
+	// traceLineRe matches lines that only ever continue an entry
+	traceLineRe = regexp.MustCompile(`^(Traceback \(most recent call last\):|Caused by:|Suppressed:|During handling of the above exception|The above exception was the direct cause|\.\.\. \d+ (more|common frames omitted)|goroutine \d+ \[|created by |\[signal |exit status \d+$)`)
+	// exceptionRe matches a Java-style exception header, like
+	// java.lang.IllegalStateException: boom
+	exceptionRe = regexp.MustCompile(`^([a-zA-Z_$][\w$]*\.)+[A-Z][\w$]*(Exception|Error|Throwable)\b`)
+	// traceStartRe matches lines that open a trace on their own, without
+	// a log line before them
+	traceStartRe = regexp.MustCompile(`^(Traceback \(most recent call last\):|panic: |fatal error: |Exception in thread )`)
+	// pyExceptionRe matches the exception line that ends a Python
+	// traceback, like ValueError: bad value or KeyboardInterrupt
+	pyExceptionRe = regexp.MustCompile(`^[A-Za-z_][\w.]*(: .*)?$`)
+	// goFrameRe matches a function line of a goroutine dump, like
+	// main.(*Server).Serve(0xc000010000)
+	goFrameRe = regexp.MustCompile(`^\S+\(.*\)$`)
+)
+
+// traceGrouper decides which lines continue the entry before them, so a
+// stack trace is read as part of the error that logged it
+type traceGrouper struct {
+	format Format
+	// python is set inside a Python traceback, whose last line is not
+	// indented
+	python bool
+	// goroutine is set inside a Go goroutine dump, whose function lines
+	// are not indented
+	goroutine bool
+}
+
+// start resets the state for a line that begins a new entry
+func (g *traceGrouper) start(line string) {
+	g.python = strings.HasPrefix(line, "Traceback ")
+	g.goroutine = strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ")
+}
+
+// continues reports whether line belongs to the entry before it
+func (g *traceGrouper) continues(line string) bool {
+	if g.format != FormatText {
+		// A line in the detected format always starts an entry
+		if g.parses(line) {
+			return false
+		}
+	}
+
+	switch {
+	case line[0] == ' ' || line[0] == '\t':
+		return true
+	case strings.HasPrefix(line, "Traceback "):
+		g.python = true
+		return true
+	case strings.HasPrefix(line, "goroutine "):
+		g.goroutine = true
+		return true
+	case traceLineRe.MatchString(line):
+		return true
+	case exceptionRe.MatchString(line) && !hasTimestamp(line):
+		return true
+	case g.goroutine && goFrameRe.MatchString(line):
+		return true
+	case g.python && pyExceptionRe.MatchString(line) && !hasTimestamp(line):
+		// The exception line ends the traceback
+		g.python = false
+		return true
+	}
+	return false
+}
+
+// parses reports whether line matches the grouper's structured format
+func (g *traceGrouper) parses(line string) bool {
+	for _, p := range formatParsers {
+		if p.format == g.format {
+			_, ok := p.parse(line)
+			return ok
+		}
+	}
+	return false
+}
+
+// hasTimestamp reports whether line carries a timestamp of its own
+func hasTimestamp(line string) bool {
+	_, _, ok := findTimestamp(line)
+	return ok
+}
+
+// appendLine adds a continuation line to an entry. Once a trace outgrows
+// what Display shows, only its head stays in Raw and the last lines are
+// kept in a ring, so memory stays bounded and the root cause is not lost.
+// A trace makes an entry an error, unless it was logged as a warning or
+// worse
+func (e *LogEntry) appendLine(line string) {
+	e.Lines++
+	switch {
+	case e.Lines <= displayHead+displayTail:
+		e.Raw += "\n" + line
+	case e.tail == nil:
+		lines := strings.Split(e.Raw, "\n")
+		e.Raw = strings.Join(lines[:1+displayHead], "\n")
+		e.tail = append(lines[len(lines)-displayTail+1:], line)
+	default:
+		e.tail = append(e.tail[1:], line)
+	}
+	if severity(e.Level) < severity(LevelWarn) && isTraceLine(line) {
+		e.Level = LevelError
+	}
+}
+
+// isTraceLine reports whether line is part of a stack trace, as opposed
+// to other indented output
+func isTraceLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return traceLineRe.MatchString(line) || traceStartRe.MatchString(line) ||
+		exceptionRe.MatchString(trimmed) || strings.HasPrefix(trimmed, "at ") ||
+		strings.HasPrefix(trimmed, "File \"")
+}
+
+// severity orders levels from debug to fatal
+func severity(level LogLevel) int {
+	switch level {
+	case LevelDebug:
+		return 0
+	case LevelInfo:
+		return 1
+	case LevelWarn:
+		return 2
+	case LevelError:
+		return 3
+	case LevelFatal:
+		return 4
+	}
+	return 1
+}
+
+// continuation returns the lines after the first, shortened to the head
+// and tail of a long trace
+func (e LogEntry) continuation() []string {
+	if e.Lines == 0 {
+		return nil
+	}
+	lines := strings.Split(e.Raw, "\n")[1:]
+	if e.tail == nil {
+		return lines
+	}
+	omitted := fmt.Sprintf("\t... %d lines omitted ...", e.Lines-displayHead-displayTail)
+	return append(append(lines, omitted), e.tail...)
+}
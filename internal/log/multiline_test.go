@@ -0,0 +1,154 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseEntriesMultiline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		levels  []LogLevel
+		// first and last are the first and last lines of the first entry
+		first string
+		last  string
+	}{
+		{
+			name: "java exception",
+			content: `2025-02-01 10:00:00 ERROR [main] c.e.App - request failed
+java.lang.IllegalStateException: boom
+	at com.example.App.handle(App.java:42)
+	at com.example.App.main(App.java:10)
+Caused by: java.io.IOException: connection reset
+	at java.base/sun.nio.ch.SocketDispatcher.read0(Native Method)
+	... 5 more
+2025-02-01 10:00:01 INFO [main] c.e.App - retrying`,
+			levels: []LogLevel{LevelError, LevelInfo},
+			first:  "2025-02-01 10:00:00 ERROR [main] c.e.App - request failed",
+			last:   "\t... 5 more",
+		},
+		{
+			name: "python logging exception",
+			content: `2025-02-01 10:00:00,123 ERROR app: job failed
+Traceback (most recent call last):
+  File "job.py", line 3, in <module>
+    run()
+ValueError: bad value
+2025-02-01 10:00:02,000 INFO app: next job`,
+			levels: []LogLevel{LevelError, LevelInfo},
+			first:  "2025-02-01 10:00:00,123 ERROR app: job failed",
+			last:   "ValueError: bad value",
+		},
+		{
+			name: "bare python traceback",
+			content: `Traceback (most recent call last):
+  File "main.py", line 1, in <module>
+KeyboardInterrupt
+Starting again`,
+			levels: []LogLevel{LevelError, LevelInfo},
+			first:  "Traceback (most recent call last):",
+			last:   "KeyboardInterrupt",
+		},
+		{
+			name: "go panic",
+			content: `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47b0a1]
+
+goroutine 1 [running]:
+main.(*Server).Serve(0x0)
+	/src/server.go:42 +0x21
+main.main()
+	/src/main.go:10 +0x1d
+exit status 2`,
+			levels: []LogLevel{LevelFatal},
+			first:  "panic: runtime error: invalid memory address or nil pointer dereference",
+			last:   "exit status 2",
+		},
+		{
+			name: "json log with a panic",
+			content: `{"level":"info","msg":"started"}
+{"level":"info","msg":"serving"}
+{"level":"info","msg":"request","path":"/"}
+panic: assignment to entry in nil map
+goroutine 7 [running]:
+main.handler()
+	/src/main.go:20 +0x3a`,
+			levels: []LogLevel{LevelInfo, LevelInfo, LevelInfo, LevelFatal},
+			first:  `{"level":"info","msg":"started"}`,
+			last:   `{"level":"info","msg":"started"}`,
+		},
+		{
+			name: "info with indented detail stays info",
+			content: `2025-02-01 10:00:00 INFO config loaded:
+    port: 8080
+    workers: 4`,
+			levels: []LogLevel{LevelInfo},
+			first:  "2025-02-01 10:00:00 INFO config loaded:",
+			last:   "    workers: 4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, entries := parseEntries(strings.Split(tt.content, "\n"))
+			var levels []LogLevel
+			for _, entry := range entries {
+				levels = append(levels, entry.Level)
+			}
+			if fmt.Sprint(levels) != fmt.Sprint(tt.levels) {
+				t.Fatalf("levels = %v, want %v", levels, tt.levels)
+			}
+			lines := strings.Split(entries[0].Raw, "\n")
+			if lines[0] != tt.first || lines[len(lines)-1] != tt.last {
+				t.Errorf("first entry = %q, want %q ... %q", entries[0].Raw, tt.first, tt.last)
+			}
+			if entries[0].Lines != len(lines)-1 {
+				t.Errorf("Lines = %d, want %d", entries[0].Lines, len(lines)-1)
+			}
+		})
+	}
+}
+
+func TestDisplayLongTrace(t *testing.T) {
+	lines := []string{"2025-02-01 10:00:00 ERROR boom", "java.lang.RuntimeException: boom"}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("\tat com.example.Frame%d(Frame.java:%d)", i, i))
+	}
+	lines = append(lines, "Caused by: java.lang.NullPointerException")
+
+	_, entries := parseEntries(lines)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the whole trace as one", len(entries))
+	}
+
+	display := entries[0].Display()
+	if !strings.HasPrefix(display, "2025-02-01 10:00:00 ERROR boom\njava.lang.RuntimeException: boom") {
+		t.Errorf("Display should start with the header and exception, got %q", display[:80])
+	}
+	if !strings.Contains(display, "lines omitted") || !strings.HasSuffix(display, "Caused by: java.lang.NullPointerException") {
+		t.Errorf("Display should shorten the middle and keep the root cause, got:\n%s", display)
+	}
+	if n := strings.Count(display, "\n"); n != displayHead+displayTail+1 {
+		t.Errorf("Display has %d continuation lines, want %d", n, displayHead+displayTail+1)
+	}
+}
+
+func TestLongTraceKeepsRootCause(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("2025-02-01 10:00:00 ERROR query failed\njava.lang.RuntimeException: query failed\n")
+	for i := 0; i < 700; i++ {
+		fmt.Fprintf(&sb, "\tat com.example.Dao.m%d(Dao.java:%d)\n", i, i)
+	}
+	sb.WriteString("Caused by: java.sql.SQLException: connection reset\n")
+
+	formatted := AnalyzeString(sb.String()).ToFormat()
+	if !strings.Contains(formatted, "... 672 lines omitted ...") {
+		t.Errorf("ToFormat() should count the omitted frames, got:\n%s", formatted)
+	}
+	last, cause := strings.Index(formatted, "m699(Dao.java:699)"), strings.Index(formatted, "Caused by: java.sql.SQLException")
+	if last < 0 || cause < last {
+		t.Errorf("ToFormat() should end the trace with its root cause, got:\n%s", formatted)
+	}
+}
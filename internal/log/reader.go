@@ -0,0 +1,266 @@
+package log
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// maxLineBytes bounds the part of a line that is kept; the rest of
+	// a longer line, like a minified JSON dump, is skipped
+	maxLineBytes = 1 << 20
+	// tailChunk is how much is read at a time going back from the end
+	tailChunk = 64 << 10
+)
+
+// compressed lists the suffixes of compressed logs
+var compressed = []string{".gz", ".bz2", ".zst"}
+
+// openLog opens a log file, decompressing .gz, .bz2 and .zst files
+func openLog(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return readCloser{gz, func() error { gz.Close(); return file.Close() }}, nil
+	case ".bz2":
+		return readCloser{bzip2.NewReader(file), file.Close}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return readCloser{zr, func() error { zr.Close(); return file.Close() }}, nil
+	}
+	return file, nil
+}
+
+// readCloser pairs a reader with the function that releases it
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+// Close releases the reader
+func (r readCloser) Close() error {
+	return r.close()
+}
+
+// readLines calls fn with each line of r until fn returns false. Lines of
+// any length are read, keeping at most maxLineBytes of each
+func readLines(r io.Reader, fn func(line string) bool) error {
+	reader := bufio.NewReaderSize(r, 64<<10)
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) <= maxLineBytes {
+			line = append(line, chunk...)
+		} else if len(line) < maxLineBytes {
+			line = append(line, chunk[:maxLineBytes-len(line)]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(line) > 0 && !fn(strings.TrimRight(string(line), "\r\n")) {
+			return nil
+		}
+		line = line[:0]
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+	}
+}
+
+// tailLines returns the last n non-blank lines of a log file. Plain files
+// are read backwards from the end in chunks; compressed ones cannot seek,
+// so they are streamed keeping only the last n lines
+func tailLines(path string, n int) ([]string, error) {
+	if isCompressed(path) {
+		r, err := openLog(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return tailReader(r, n)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back until n line breaks are found before non-blank lines
+	offset := info.Size()
+	found := 0
+	// text is set once the line after the current position has text
+	text := false
+	buf := make([]byte, tailChunk)
+	start := int64(0)
+search:
+	for offset > 0 {
+		size := int64(len(buf))
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		if _, err := file.ReadAt(buf[:size], offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read log file: %w", err)
+		}
+		for i := size - 1; i >= 0; i-- {
+			switch buf[i] {
+			case '\n':
+				if text {
+					found++
+					if found == n {
+						start = offset + i + 1
+						break search
+					}
+				}
+				text = false
+			case ' ', '\t', '\r':
+			default:
+				text = true
+			}
+		}
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var lines []string
+	err = readLines(file, func(line string) bool {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+		return true
+	})
+	return lines, err
+}
+
+// tailReader returns the last n non-blank lines read from r, keeping no
+// more than those in memory
+func tailReader(r io.Reader, n int) ([]string, error) {
+	ring := make([]string, 0, n)
+	next := 0
+	err := readLines(r, func(line string) bool {
+		if strings.TrimSpace(line) == "" {
+			return true
+		}
+		if len(ring) < n {
+			ring = append(ring, line)
+		} else {
+			ring[next] = line
+			next = (next + 1) % n
+		}
+		return true
+	})
+	return append(ring[next:], ring[:next]...), err
+}
+
+// isCompressed reports whether path names a compressed log
+func isCompressed(path string) bool {
+	return containsString(compressed, filepath.Ext(path))
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// rotatedRe matches what logrotate appends to a log's name: a number or
+// a date, optionally followed by a compression suffix
+var rotatedRe = regexp.MustCompile(`^[.-](\d+)(\.gz|\.bz2|\.zst)?$`)
+
+// RotatedFiles returns path together with its rotated siblings, oldest
+// first: app.log.3.gz, app.log.2.gz, app.log.1, app.log. Date suffixes
+// like app.log-20250201.gz sort by date, before the numbered ones
+func RotatedFiles(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated logs: %w", err)
+	}
+
+	type rotated struct {
+		path   string
+		dated  bool
+		number int
+	}
+	var found []rotated
+	for _, entry := range names {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		m := rotatedRe.FindStringSubmatch(name[len(base):])
+		if m == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(m[1])
+		found = append(found, rotated{
+			path:   filepath.Join(dir, name),
+			dated:  len(m[1]) == 8 && strings.HasPrefix(name[len(base):], "-"),
+			number: number,
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.dated != b.dated {
+			return a.dated
+		}
+		if a.dated {
+			return a.number < b.number
+		}
+		return a.number > b.number
+	})
+
+	files := make([]string, 0, len(found)+1)
+	for _, r := range found {
+		files = append(files, r.path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("failed to open log file: %s not found", path)
+	}
+	return files, nil
+}
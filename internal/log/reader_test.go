@@ -0,0 +1,256 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeLog writes content to name in dir, compressing by its extension
+func writeLog(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+
+	switch filepath.Ext(name) {
+	case ".gz":
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz := gzip.NewWriter(file)
+		if _, err := gz.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		gz.Close()
+		file.Close()
+	case ".zst":
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw, err := zstd.NewWriter(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := zw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		zw.Close()
+		file.Close()
+	case ".bz2":
+		// The standard library only decompresses bzip2
+		if _, err := exec.LookPath("bzip2"); err != nil {
+			t.Skip("bzip2 not available")
+		}
+		plain := strings.TrimSuffix(path, ".bz2")
+		if err := os.WriteFile(plain, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command("bzip2", "-q", plain).CombinedOutput(); err != nil {
+			t.Fatalf("bzip2: %v: %s", err, out)
+		}
+	default:
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// numberedLines returns timestamped lines "line from" to "line to"
+func numberedLines(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "2025-02-01 10:00:00 INFO line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestReadLinesLong(t *testing.T) {
+	long := strings.Repeat("x", 200<<10)
+	huge := strings.Repeat("y", maxLineBytes+10)
+	content := "short\n" + long + "\r\n" + huge + "\nlast"
+
+	var lines []string
+	err := readLines(strings.NewReader(content), func(line string) bool {
+		lines = append(lines, line)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("readLines() error = %v", err)
+	}
+	if len(lines) != 4 || lines[0] != "short" || lines[3] != "last" {
+		t.Fatalf("got %d lines, want 4 with the short ones intact", len(lines))
+	}
+	if lines[1] != long {
+		t.Errorf("a 200KB line should be read whole, got %d bytes", len(lines[1]))
+	}
+	if len(lines[2]) != maxLineBytes {
+		t.Errorf("an over-long line should be cut to %d bytes, got %d", maxLineBytes, len(lines[2]))
+	}
+}
+
+func TestOpenLogCompressed(t *testing.T) {
+	content := numberedLines(1, 3)
+
+	for _, name := range []string{"app.log", "app.log.gz", "app.log.bz2", "app.log.zst"} {
+		t.Run(name, func(t *testing.T) {
+			path := writeLog(t, t.TempDir(), name, content)
+			r, err := openLog(path)
+			if err != nil {
+				t.Fatalf("openLog() error = %v", err)
+			}
+			defer r.Close()
+
+			var lines []string
+			if err := readLines(r, func(line string) bool {
+				lines = append(lines, line)
+				return true
+			}); err != nil {
+				t.Fatalf("readLines() error = %v", err)
+			}
+			if strings.Join(lines, "\n")+"\n" != content {
+				t.Errorf("read %q, want %q", lines, content)
+			}
+		})
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	dir := t.TempDir()
+	// Large enough to span several chunks read from the end
+	content := numberedLines(1, 20000)
+
+	for _, name := range []string{"big.log", "big.log.gz", "big.log.zst"} {
+		t.Run(name, func(t *testing.T) {
+			path := writeLog(t, dir, name, content)
+
+			lines, err := tailLines(path, 3)
+			if err != nil {
+				t.Fatalf("tailLines() error = %v", err)
+			}
+			if len(lines) != 3 || !strings.HasSuffix(lines[0], "line 19998") || !strings.HasSuffix(lines[2], "line 20000") {
+				t.Errorf("tailLines(3) = %q, want lines 19998 to 20000", lines)
+			}
+
+			lines, err = tailLines(path, 50000)
+			if err != nil {
+				t.Fatalf("tailLines() error = %v", err)
+			}
+			if len(lines) != 20000 {
+				t.Errorf("tailLines(50000) = %d lines, want the whole file", len(lines))
+			}
+		})
+	}
+
+	// Without a final newline the last line still counts
+	path := writeLog(t, dir, "open.log", "a\nb\nc")
+	if lines, _ := tailLines(path, 2); strings.Join(lines, ",") != "b,c" {
+		t.Errorf("tailLines(2) = %q, want b,c", lines)
+	}
+
+	// Blank lines do not count towards n
+	for _, name := range []string{"blank.log", "blank.log.gz"} {
+		path := writeLog(t, dir, name, "a\nb\n\n  \nc\n\n")
+		if lines, _ := tailLines(path, 2); strings.Join(lines, ",") != "b,c" {
+			t.Errorf("%s: tailLines(2) = %q, want b,c", name, lines)
+		}
+	}
+}
+
+func TestRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.log", "app.log.1", "app.log.2.gz", "app.log.10.gz", "app.log-20250101.gz", "app.log.bak", "app.logger", "other.log.1"} {
+		writeLog(t, dir, name, "x\n")
+	}
+
+	files, err := RotatedFiles(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatalf("RotatedFiles() error = %v", err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	want := "app.log-20250101.gz app.log.10.gz app.log.2.gz app.log.1 app.log"
+	if strings.Join(names, " ") != want {
+		t.Errorf("RotatedFiles() = %v, want %s", names, want)
+	}
+
+	if _, err := RotatedFiles(filepath.Join(dir, "missing.log")); err == nil {
+		t.Error("RotatedFiles() should fail when there is no such log")
+	}
+}
+
+func TestAnalyzeRotatedTail(t *testing.T) {
+	dir := t.TempDir()
+	writeLog(t, dir, "app.log.2.gz", numberedLines(1, 10))
+	writeLog(t, dir, "app.log.1", numberedLines(11, 20))
+	path := writeLog(t, dir, "app.log", numberedLines(21, 25))
+
+	analyzer := New(path)
+	analyzer.SetRotated(true)
+	result, err := analyzer.Analyze(0)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if result.Total != 25 || len(result.Files) != 3 {
+		t.Errorf("rotated Total = %d from %d files, want 25 from 3", result.Total, len(result.Files))
+	}
+
+	// The last 8 lines reach back into app.log.1
+	analyzer.SetTail(8)
+	result, err = analyzer.Analyze(0)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if result.Total != 8 {
+		t.Errorf("tail Total = %d, want 8", result.Total)
+	}
+	if example := result.Templates[0].Values(); len(example) == 0 || example[0][0] != "18" {
+		t.Errorf("tail should start at line 18, got values %v", example)
+	}
+}
+
+func TestAnalyzeReader(t *testing.T) {
+	content := numberedLines(1, 1000) + "\n\n"
+
+	result, err := New("").AnalyzeReader(strings.NewReader(content), 100)
+	if err != nil {
+		t.Fatalf("AnalyzeReader() error = %v", err)
+	}
+	if result.Total != 100 {
+		t.Errorf("limit Total = %d, want 100", result.Total)
+	}
+
+	analyzer := New("")
+	analyzer.SetTail(5)
+	result, err = analyzer.AnalyzeReader(strings.NewReader(content), 0)
+	if err != nil {
+		t.Fatalf("AnalyzeReader() error = %v", err)
+	}
+	if result.Total != 5 {
+		t.Errorf("tail Total = %d, want 5", result.Total)
+	}
+	if values := result.Templates[0].Values(); len(values) == 0 || values[0][0] != "996" {
+		t.Errorf("tail should start at line 996, got values %v", values)
+	}
+}
+
+func TestAnalyzeLimitStopsEarly(t *testing.T) {
+	path := writeLog(t, t.TempDir(), "app.log", numberedLines(1, 1000)+"2025-02-01 10:00:00 ERROR late failure\n")
+
+	result, err := New(path).Analyze(100)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if result.Total != 100 || result.ErrorCount() != 0 {
+		t.Errorf("Total = %d, errors = %d, want the first 100 entries only", result.Total, result.ErrorCount())
+	}
+}
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"strings"
+	"time"
+)
+
+// entryStream turns lines into entries with bounded memory. It holds back
+// the first lines to detect the format and date order, groups continuation
+// lines into the entry before them, and hands each finished entry to emit
+type entryStream struct {
+	format Format
+	// order is how the file writes numeric dates, picked with the format
+	order    dateOrder
+	detected bool
+	sample   []string
+	grouper  traceGrouper
+	pending  *LogEntry
+	emit     func(LogEntry) bool
+	// done is set once emit wants no more entries
+	done bool
+}
+
+// newEntryStream creates a stream that passes entries to emit until it
+// returns false
+func newEntryStream(emit func(LogEntry) bool) *entryStream {
+	return &entryStream{emit: emit}
+}
+
+// add takes the next line; it returns false once no more are wanted
+func (s *entryStream) add(line string) bool {
+	if s.done {
+		return false
+	}
+	if strings.TrimSpace(line) == "" {
+		return true
+	}
+	if !s.detected {
+		s.sample = append(s.sample, line)
+		if len(s.sample) < detectSampleLines {
+			return true
+		}
+		return s.detect()
+	}
+	return s.parse(line)
+}
+
+// detect picks the format from the lines held back and parses them
+func (s *entryStream) detect() bool {
+	s.format = DetectFormat(s.sample)
+	s.order = detectDateOrder(s.sample)
+	s.grouper = traceGrouper{format: s.format}
+	s.detected = true
+
+	sample := s.sample
+	s.sample = nil
+	for _, line := range sample {
+		if !s.parse(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// parse adds a line to the pending entry or starts a new one
+func (s *entryStream) parse(line string) bool {
+	if s.pending != nil && s.grouper.continues(line) {
+		s.pending.appendLine(line)
+		return true
+	}
+	if s.pending != nil && !s.emit(*s.pending) {
+		s.pending = nil
+		s.done = true
+		return false
+	}
+
+	s.grouper.start(line)
+	entry := parseLineIn(line, s.format, s.order)
+	if traceStartRe.MatchString(line) && severity(entry.Level) < severity(LevelWarn) {
+		entry.Level = LevelError
+	}
+	s.pending = &entry
+	return true
+}
+
+// close parses what is still held back and emits the last entry
+func (s *entryStream) close() {
+	if !s.detected {
+		s.detect()
+	}
+	if s.pending != nil && !s.done {
+		s.emit(*s.pending)
+	}
+	s.pending = nil
+	s.done = true
+}
+
+// collector summarizes entries as they stream in into counts, a time
+// range and templates, without keeping the entries themselves
+type collector struct {
+	result *AnalysisResult
+	miner  *TemplateMiner
+	window Window
+	limit  int
+	// last is the time of the latest entry that had one, which entries
+	// without a timestamp go with
+	last time.Time
+}
+
+// newCollector creates a collector for the entries inside window, taking
+// at most limit of them when limit is positive
+func newCollector(window Window, limit int) *collector {
+	return &collector{
+		result: &AnalysisResult{ByLevel: make(map[LogLevel]int), Window: window},
+		miner:  NewTemplateMiner(),
+		window: window,
+		limit:  limit,
+	}
+}
+
+// add counts an entry; it returns false once the limit is reached
+func (c *collector) add(entry LogEntry) bool {
+	if !entry.Time.IsZero() {
+		c.last = entry.Time
+	}
+	if !c.window.IsZero() && (c.last.IsZero() || !c.window.Contains(c.last)) {
+		return true
+	}
+
+	r := c.result
+	r.Total++
+	r.ByLevel[entry.Level]++
+	c.miner.Add(entry)
+
+	if !entry.Time.IsZero() {
+		if r.First.IsZero() || entry.Time.Before(r.First) {
+			r.First = entry.Time
+		}
+		if entry.Time.After(r.Last) {
+			r.Last = entry.Time
+		}
+	}
+
+	return c.limit <= 0 || r.Total < c.limit
+}
+
+// finish completes the result once the stream is closed
+func (c *collector) finish(logType LogType, format Format) *AnalysisResult {
+	c.result.LogType = typeOfFormat(logType, format)
+	c.result.Format = format
+	c.result.Templates = c.miner.Templates()
+	return c.result
+}
@@ -0,0 +1,329 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Template mining follows Drain (He et al., ICWS 2017): messages are
+// tokenized, routed through a fixed-depth tree by token count and leading
+// tokens, and merged into the most similar template in the leaf, with the
+// differing tokens turned into wildcards
+
+const (
+	// wildcard stands for a variable token in a template
+	wildcard = "<*>"
+	// treeDepth is the number of leading tokens that route a message
+	treeDepth = 3
+	// maxChildren bounds a tree node; further tokens share a wildcard child
+	maxChildren = 100
+	// similarity is the share of equal tokens needed to join a template
+	similarity = 0.5
+	// maxExamples is how many example messages a template keeps
+	maxExamples = 5
+	// maxValues is how many distinct example values a wildcard keeps
+	maxValues = 5
+	// maxLeafTemplates bounds the templates a message is compared with;
+	// messages that fit none of them go to the leaf's overflow template
+	maxLeafTemplates = 50
+	// maxTemplates bounds the templates of a miner, keeping its memory
+	// independent of the input size; once reached, new shapes of message
+	// only go to overflow templates
+	maxTemplates = 2000
+)
+
+// Template is a cluster of log messages that differ only in their
+// variable parts, like ids, durations and addresses
+type Template struct {
+	Tokens []string
+	Level  LogLevel
+	Count  int
+	First  time.Time
+	Last   time.Time
+	// Example is the first entry of an error or warning template, with its
+	// stack trace shortened to what Display shows; only those templates
+	// show an example
+	Example LogEntry
+	// examples are the words of the first few messages, from which the
+	// wildcards' example values are taken
+	examples [][]string
+}
+
+// String returns the template text with <*> for variable tokens
+func (t *Template) String() string {
+	return strings.Join(t.Tokens, " ")
+}
+
+// Values returns up to maxValues distinct example values for each
+// wildcard, in order
+func (t *Template) Values() [][]string {
+	var values [][]string
+	for i, token := range t.Tokens {
+		if token != wildcard {
+			continue
+		}
+		var seen []string
+		for _, example := range t.examples {
+			if i < len(example) && !containsValue(seen, example[i]) && len(seen) < maxValues {
+				seen = append(seen, example[i])
+			}
+		}
+		values = append(values, seen)
+	}
+	return values
+}
+
+// containsValue reports whether values contains v
+func containsValue(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// templateNode is a node of the routing tree
+type templateNode struct {
+	children  map[string]*templateNode
+	templates []*Template
+	// overflow takes the messages of a full leaf, all tokens wildcards
+	overflow *Template
+}
+
+// newTemplateNode creates an empty tree node
+func newTemplateNode() *templateNode {
+	return &templateNode{children: make(map[string]*templateNode)}
+}
+
+// TemplateMiner clusters log messages into templates as they are added
+type TemplateMiner struct {
+	root      *templateNode
+	templates []*Template
+	// overflow holds a single-wildcard template per level for messages
+	// that find no leaf once maxTemplates is reached
+	overflow map[LogLevel]*Template
+}
+
+// NewTemplateMiner creates an empty template miner
+func NewTemplateMiner() *TemplateMiner {
+	return &TemplateMiner{root: newTemplateNode(), overflow: make(map[LogLevel]*Template)}
+}
+
+// Add clusters the entry's message into a template and returns it
+func (m *TemplateMiner) Add(entry LogEntry) *Template {
+	words := strings.Fields(templateText(entry))
+	tokens := maskTokens(words)
+	full := len(m.templates) >= maxTemplates
+	leaf := m.leaf(entry.Level, tokens, !full)
+
+	var best *Template
+	switch {
+	case leaf == nil:
+		best = m.overflow[entry.Level]
+		if best == nil {
+			best = m.overflowTemplate(entry, 1)
+			m.overflow[entry.Level] = best
+		}
+	default:
+		bestScore := 0.0
+		for _, t := range leaf.templates {
+			if score := tokenSimilarity(t.Tokens, tokens); score > bestScore {
+				best, bestScore = t, score
+			}
+		}
+		switch {
+		case best != nil && bestScore >= similarity:
+			for i, token := range tokens {
+				if best.Tokens[i] != token {
+					best.Tokens[i] = wildcard
+				}
+			}
+		case full || len(leaf.templates) >= maxLeafTemplates:
+			if leaf.overflow == nil {
+				leaf.overflow = m.overflowTemplate(entry, len(tokens))
+			}
+			best = leaf.overflow
+		default:
+			best = m.newTemplate(entry, tokens)
+			leaf.templates = append(leaf.templates, best)
+		}
+	}
+
+	best.Count++
+	if len(best.examples) < maxExamples {
+		best.examples = append(best.examples, words)
+	}
+	if !entry.Time.IsZero() {
+		if best.First.IsZero() || entry.Time.Before(best.First) {
+			best.First = entry.Time
+		}
+		if entry.Time.After(best.Last) {
+			best.Last = entry.Time
+		}
+	}
+	return best
+}
+
+// newTemplate starts a template from an entry's tokens
+func (m *TemplateMiner) newTemplate(entry LogEntry, tokens []string) *Template {
+	t := &Template{Tokens: append([]string(nil), tokens...), Level: entry.Level}
+	if severity(entry.Level) >= severity(LevelWarn) {
+		t.Example = entry
+	}
+	m.templates = append(m.templates, t)
+	return t
+}
+
+// overflowTemplate starts a template of n wildcards
+func (m *TemplateMiner) overflowTemplate(entry LogEntry, n int) *Template {
+	wildcards := make([]string, n)
+	for i := range wildcards {
+		wildcards[i] = wildcard
+	}
+	return m.newTemplate(entry, wildcards)
+}
+
+// leaf walks the tree by level, token count and leading tokens, adding
+// nodes as needed when create is set and returning nil for a missing one
+// otherwise; tokens with digits route through the wildcard child so that
+// ids in the first words do not split a template
+func (m *TemplateMiner) leaf(level LogLevel, tokens []string, create bool) *templateNode {
+	node := m.root.child(fmt.Sprintf("%s/%d", level, len(tokens)), create)
+	for i := 0; node != nil && i < treeDepth-1 && i < len(tokens); i++ {
+		key := tokens[i]
+		if key != wildcard && len(node.children) >= maxChildren {
+			if _, ok := node.children[key]; !ok {
+				key = wildcard
+			}
+		}
+		node = node.child(key, create)
+	}
+	return node
+}
+
+// child returns the child under key, creating it if needed and allowed
+func (n *templateNode) child(key string, create bool) *templateNode {
+	c, ok := n.children[key]
+	if !ok && create {
+		c = newTemplateNode()
+		n.children[key] = c
+	}
+	return c
+}
+
+// Templates returns the templates found so far, most frequent first
+func (m *TemplateMiner) Templates() []*Template {
+	templates := append([]*Template(nil), m.templates...)
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Count > templates[j].Count
+	})
+	return templates
+}
+
+// templateText is the part of an entry that is clustered: the first line
+// of its message, without the timestamp
+func templateText(entry LogEntry) string {
+	text := entry.Message
+	if text == "" {
+		text = entry.Raw
+	}
+	text, _, _ = strings.Cut(text, "\n")
+	if entry.Timestamp != "" {
+		text = strings.Replace(text, entry.Timestamp, "", 1)
+	}
+	return text
+}
+
+// maskTokens replaces the words with digits, which are nearly always
+// variable, with wildcards
+func maskTokens(words []string) []string {
+	tokens := make([]string, len(words))
+	for i, word := range words {
+		if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			tokens[i] = wildcard
+		} else {
+			tokens[i] = word
+		}
+	}
+	return tokens
+}
+
+// tokenSimilarity is the share of positions where template and tokens
+// hold the same token
+func tokenSimilarity(template, tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 1
+	}
+	same := 0
+	for i, token := range template {
+		if token == tokens[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
+
+// templatesOf returns the result's templates at the given levels, most
+// frequent first
+func (r *AnalysisResult) templatesOf(levels ...LogLevel) []*Template {
+	var templates []*Template
+	for _, t := range r.Templates {
+		for _, level := range levels {
+			if t.Level == level {
+				templates = append(templates, t)
+				break
+			}
+		}
+	}
+	return templates
+}
+
+// pickTemplates keeps at most n of the templates, sorted most frequent
+// first: half the most frequent and the rest from the rarest, which a
+// frequency cut alone would always miss
+func pickTemplates(templates []*Template, n int) []*Template {
+	if len(templates) <= n {
+		return templates
+	}
+	frequent := n / 2
+	picked := append([]*Template(nil), templates[:frequent]...)
+	return append(picked, templates[len(templates)-(n-frequent):]...)
+}
+
+// rarestFirst returns up to n templates, least frequent first
+func rarestFirst(templates []*Template, n int) []*Template {
+	var rare []*Template
+	for i := len(templates) - 1; i >= 0 && len(rare) < n; i-- {
+		rare = append(rare, templates[i])
+	}
+	return rare
+}
+
+// describe renders a template for the prompt with its count, time span
+// and example values
+func (t *Template) describe() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("- [%s] x%d %s", t.Level, t.Count, t.String()))
+	if !t.First.IsZero() {
+		const layout = "2006-01-02 15:04:05"
+		if t.First.Equal(t.Last) {
+			sb.WriteString(fmt.Sprintf(" (at %s)", t.First.Format(layout)))
+		} else {
+			sb.WriteString(fmt.Sprintf(" (first %s, last %s)", t.First.Format(layout), t.Last.Format(layout)))
+		}
+	}
+	sb.WriteString("\n")
+
+	var values []string
+	for _, v := range t.Values() {
+		values = append(values, strings.Join(v, ", "))
+	}
+	if len(values) > 0 && t.Count > 1 {
+		sb.WriteString("  values: " + strings.Join(values, " | ") + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,166 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateMiner(t *testing.T) {
+	miner := NewTemplateMiner()
+	at := func(sec int) time.Time { return time.Date(2025, 2, 1, 10, 0, sec, 0, time.UTC) }
+
+	miner.Add(LogEntry{Level: LevelError, Message: "connection to db-1 refused", Time: at(3)})
+	miner.Add(LogEntry{Level: LevelError, Message: "connection to db-2 refused", Time: at(1)})
+	miner.Add(LogEntry{Level: LevelError, Message: "connection to cache refused", Time: at(2)})
+	miner.Add(LogEntry{Level: LevelInfo, Message: "connection to cache refused"})
+	miner.Add(LogEntry{Level: LevelError, Message: "disk full"})
+
+	templates := miner.Templates()
+	if len(templates) != 3 {
+		t.Fatalf("got %d templates, want 3", len(templates))
+	}
+
+	top := templates[0]
+	if top.String() != "connection to <*> refused" || top.Count != 3 || top.Level != LevelError {
+		t.Errorf("top template = %q x%d %s, want the merged error template x3", top, top.Count, top.Level)
+	}
+	if !top.First.Equal(at(1)) || !top.Last.Equal(at(3)) {
+		t.Errorf("first/last = %v/%v, want %v/%v", top.First, top.Last, at(1), at(3))
+	}
+	if values := fmt.Sprint(top.Values()); values != "[[db-1 db-2 cache]]" {
+		t.Errorf("Values() = %s, want the wildcard's examples", values)
+	}
+	if top.Example.Message != "connection to db-1 refused" {
+		t.Errorf("Example = %q, want the first entry", top.Example.Message)
+	}
+}
+
+func TestTemplateMinerMasksNumbers(t *testing.T) {
+	miner := NewTemplateMiner()
+	for i := 0; i < 100; i++ {
+		miner.Add(LogEntry{Level: LevelInfo, Message: fmt.Sprintf("request %d took %dms", i, i*3)})
+	}
+
+	templates := miner.Templates()
+	if len(templates) != 1 || templates[0].String() != "request <*> took <*>" {
+		t.Fatalf("got %d templates starting %q, want one", len(templates), templates[0])
+	}
+	if values := templates[0].Values(); len(values) != 2 || len(values[0]) != maxValues {
+		t.Errorf("Values() = %v, want %d examples for each wildcard", values, maxValues)
+	}
+}
+
+func TestPickTemplates(t *testing.T) {
+	var templates []*Template
+	for i := 10; i > 0; i-- {
+		templates = append(templates, &Template{Tokens: []string{fmt.Sprint(i)}, Count: i})
+	}
+
+	var counts []int
+	for _, tmpl := range pickTemplates(templates, 4) {
+		counts = append(counts, tmpl.Count)
+	}
+	if fmt.Sprint(counts) != "[10 9 2 1]" {
+		t.Errorf("pickTemplates counts = %v, want the two most frequent and two rarest", counts)
+	}
+
+	counts = nil
+	for _, tmpl := range rarestFirst(templates, 3) {
+		counts = append(counts, tmpl.Count)
+	}
+	if fmt.Sprint(counts) != "[1 2 3]" {
+		t.Errorf("rarestFirst counts = %v, want [1 2 3]", counts)
+	}
+}
+
+func TestToFormatRareError(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "2025-02-01 10:%02d:%02d ERROR upstream timed out after %dms\n", i/60%60, i%60, i)
+		if i == 1234 {
+			sb.WriteString("2025-02-01 10:20:34 ERROR certificate for api.example.com expired\n")
+		}
+	}
+
+	formatted := AnalyzeString(sb.String()).ToFormat()
+	if !strings.Contains(formatted, "## Error Entries (2001 in 2 templates)") {
+		t.Errorf("Formatted output should count error templates, got:\n%s", formatted)
+	}
+	if !strings.Contains(formatted, "certificate for api.example.com expired") {
+		t.Error("Formatted output should include the one-off error")
+	}
+	if !strings.Contains(formatted, "- [ERROR] x2000 upstream timed out after <*>") {
+		t.Errorf("Formatted output should list the frequent template with its count, got:\n%s", formatted)
+	}
+	if n := strings.Count(formatted, "upstream timed out"); n > 3 {
+		t.Errorf("the frequent error appears %d times, want it summarized", n)
+	}
+}
+
+func TestTemplateMinerBounded(t *testing.T) {
+	miner := NewTemplateMiner()
+	word := func(n int) string {
+		var b []byte
+		for ; n > 0; n /= 26 {
+			b = append(b, byte('a'+n%26))
+		}
+		return string(b)
+	}
+
+	const n = 50000
+	for i := 0; i < n; i++ {
+		msg := fmt.Sprintf("%s %s %s %s", word(i), word(i*7+1), word(i*13+2), word(i*31+3))
+		miner.Add(LogEntry{Level: LevelInfo, Message: msg})
+	}
+
+	templates := miner.Templates()
+	// Each leaf may add an overflow template to the capped ones
+	if len(templates) > 2*maxTemplates {
+		t.Errorf("got %d templates, want at most %d", len(templates), 2*maxTemplates)
+	}
+	total := 0
+	for _, tmpl := range templates {
+		total += tmpl.Count
+	}
+	if total != n {
+		t.Errorf("templates count %d messages, want %d", total, n)
+	}
+}
+
+func TestTemplateMinerLeafOverflow(t *testing.T) {
+	miner := NewTemplateMiner()
+	for i := 0; i < maxLeafTemplates+10; i++ {
+		x, y, z := strings.Repeat("x", i+1), strings.Repeat("y", i+1), strings.Repeat("z", i+1)
+		miner.Add(LogEntry{Level: LevelInfo, Message: fmt.Sprintf("job ran %s %s %s", x, y, z)})
+	}
+
+	templates := miner.Templates()
+	if len(templates) != maxLeafTemplates+1 {
+		t.Fatalf("got %d templates, want %d and an overflow", len(templates), maxLeafTemplates)
+	}
+	if top := templates[0]; top.String() != "<*> <*> <*> <*> <*>" || top.Count != 10 {
+		t.Errorf("top template = %q x%d, want the overflow template x10", top, top.Count)
+	}
+}
+
+func TestTemplateExamples(t *testing.T) {
+	miner := NewTemplateMiner()
+	info := miner.Add(LogEntry{Level: LevelInfo, Raw: "server started", Message: "server started"})
+	if info.Example.Raw != "" {
+		t.Errorf("info template kept example %q, want none", info.Example.Raw)
+	}
+
+	entry := LogEntry{Level: LevelError, Raw: "panic: boom", Message: "panic: boom"}
+	for i := 0; i < 100; i++ {
+		entry.appendLine(fmt.Sprintf("\tframe %d", i))
+	}
+	errTemplate := miner.Add(entry)
+	if got, want := errTemplate.Example.Display(), entry.Display(); got != want {
+		t.Errorf("example Display() = %q, want %q", got, want)
+	}
+	if lines := strings.Count(errTemplate.Example.Raw, "\n") + len(errTemplate.Example.tail); lines != displayHead+displayTail {
+		t.Errorf("example keeps %d lines, want %d", lines, displayHead+displayTail)
+	}
+}
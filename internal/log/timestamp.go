@@ -0,0 +1,341 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is the clock used to place year-less timestamps and relative
+// filter times; tests replace it
+var now = time.Now
+
+// timeFormat is a timestamp shape found in log lines and the layouts
+// that parse it
+type timeFormat struct {
+	re      *regexp.Regexp
+	layouts []string
+	// noYear marks formats like syslog's that leave the year out
+	noYear bool
+	// numericDate marks 01/02/2006, whose layouts are month first and day
+	// first; which one applies is the file's dateOrder
+	numericDate bool
+}
+
+// dateOrder is the order a file writes numeric dates like 02/01/2025 in
+type dateOrder int
+
+const (
+	// orderUnknown reads only dates that a day over 12 makes unambiguous
+	orderUnknown dateOrder = iota
+	orderMonthFirst
+	orderDayFirst
+)
+
+// numericDateRe matches 01/02/2006 15:04:05, month or day first
+var numericDateRe = regexp.MustCompile(`\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}(?:\.\d+)?`)
+
+// timeFormats are tried in order; longer shapes come before the shorter
+// ones they contain, like ctime before syslog
+var timeFormats = []timeFormat{
+	{
+		// ISO 8601 / RFC 3339, with a space or T, optional fraction and zone
+		re: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2}| UTC\b)?`),
+		layouts: []string{
+			"2006-01-02T15:04:05Z07:00",
+			"2006-01-02T15:04:05Z0700",
+			"2006-01-02T15:04:05",
+		},
+	},
+	{
+		// Go's log package and nginx's error log
+		re:      regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts: []string{"2006/01/02 15:04:05"},
+	},
+	{
+		// Common/combined access log
+		re:      regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	{
+		// Month or day first, as the file's dateOrder says
+		re:          numericDateRe,
+		layouts:     []string{"01/02/2006 15:04:05", "02/01/2006 15:04:05"},
+		numericDate: true,
+	},
+	{
+		// ctime, as in Apache's error log
+		re:      regexp.MustCompile(`[A-Z][a-z]{2} [A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4}`),
+		layouts: []string{"Mon Jan _2 15:04:05 2006"},
+	},
+	{
+		// BSD syslog and journalctl's short output
+		re:      regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts: []string{"Jan _2 15:04:05"},
+		noYear:  true,
+	},
+	{
+		// klog header, as in I0102 15:04:05.000000
+		re:      regexp.MustCompile(`\d{4} \d{2}:\d{2}:\d{2}\.\d{6}\b`),
+		layouts: []string{"0102 15:04:05.000000"},
+		noYear:  true,
+	},
+}
+
+// findTimestamp returns the first timestamp in s along with its text,
+// skipping numeric dates whose order is ambiguous
+func findTimestamp(s string) (string, time.Time, bool) {
+	return findTimestampIn(s, orderUnknown)
+}
+
+// findTimestampIn returns the first timestamp in s, reading numeric dates
+// in the given order
+func findTimestampIn(s string, order dateOrder) (string, time.Time, bool) {
+	for _, f := range timeFormats {
+		for rest := s; ; {
+			loc := f.re.FindStringIndex(rest)
+			if loc == nil {
+				break
+			}
+			m := rest[loc[0]:loc[1]]
+			if t, ok := f.parse(m, order); ok {
+				return m, t, true
+			}
+			rest = rest[loc[1]:]
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// parse parses a match of the format, in local time when it has no zone
+func (f timeFormat) parse(s string, order dateOrder) (time.Time, bool) {
+	s = strings.Replace(s, ",", ".", 1)
+	if strings.HasSuffix(s, " UTC") {
+		s = strings.TrimSuffix(s, " UTC") + "Z"
+	}
+	if len(s) > 10 && s[4] == '-' && s[10] == ' ' {
+		s = s[:10] + "T" + s[11:]
+	}
+	layouts := f.layouts
+	if f.numericDate {
+		if order == orderUnknown {
+			order = numericDateOrder(s)
+		}
+		switch order {
+		case orderMonthFirst:
+			layouts = layouts[:1]
+		case orderDayFirst:
+			layouts = layouts[1:]
+		default:
+			return time.Time{}, false
+		}
+	}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if f.noYear {
+			t = withYear(t)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// numericDateOrder tells the order of a numeric date from a number over
+// 12, which can only be the day; 02/01/2025 could be either
+func numericDateOrder(s string) dateOrder {
+	first, _ := strconv.Atoi(s[0:2])
+	second, _ := strconv.Atoi(s[3:5])
+	switch {
+	case first > 12 && second <= 12:
+		return orderDayFirst
+	case second > 12 && first <= 12:
+		return orderMonthFirst
+	}
+	return orderUnknown
+}
+
+// detectDateOrder picks the order of numeric dates for a whole file from
+// the unambiguous dates among the sample lines, like DetectFormat picks
+// the format. It stays unknown when the lines have none or disagree
+func detectDateOrder(lines []string) dateOrder {
+	counts := make(map[dateOrder]int)
+	for _, line := range lines {
+		for _, m := range numericDateRe.FindAllString(line, -1) {
+			counts[numericDateOrder(m)]++
+		}
+	}
+	switch {
+	case counts[orderMonthFirst] > 0 && counts[orderDayFirst] == 0:
+		return orderMonthFirst
+	case counts[orderDayFirst] > 0 && counts[orderMonthFirst] == 0:
+		return orderDayFirst
+	}
+	return orderUnknown
+}
+
+// withYear places a year-less time in the current year, or the one before
+// when that would put it more than a day in the future, as happens when
+// reading December's logs in January
+func withYear(t time.Time) time.Time {
+	ref := now()
+	y := time.Date(ref.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+	if y.After(ref.Add(24 * time.Hour)) {
+		y = y.AddDate(-1, 0, 0)
+	}
+	return y
+}
+
+// parseTimeValue parses a timestamp field of a structured entry: the text
+// formats above, or Unix epoch seconds, milliseconds, microseconds or
+// nanoseconds told apart by their size
+func parseTimeValue(s string) (time.Time, bool) {
+	return parseTimeValueIn(s, orderUnknown)
+}
+
+// parseTimeValueIn parses a timestamp field, reading numeric dates in the
+// given order
+func parseTimeValueIn(s string, order dateOrder) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return epochTime(f)
+	}
+	_, t, ok := findTimestampIn(s, order)
+	return t, ok
+}
+
+// epochTime converts a Unix timestamp in whichever unit its size suggests
+func epochTime(f float64) (time.Time, bool) {
+	switch {
+	case f <= 0:
+		return time.Time{}, false
+	case f < 1e11:
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+	case f < 1e14:
+		return time.UnixMilli(int64(f)), true
+	case f < 1e17:
+		return time.UnixMicro(int64(f)), true
+	default:
+		return time.Unix(0, int64(f)), true
+	}
+}
+
+// Window limits analysis to entries between Since and Until; a zero bound
+// leaves that side open
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+// IsZero reports whether the window lets every entry through
+func (w Window) IsZero() bool {
+	return w.Since.IsZero() && w.Until.IsZero()
+}
+
+// Contains reports whether t falls inside the window
+func (w Window) Contains(t time.Time) bool {
+	if !w.Since.IsZero() && t.Before(w.Since) {
+		return false
+	}
+	if !w.Until.IsZero() && t.After(w.Until) {
+		return false
+	}
+	return true
+}
+
+// String describes the window for status lines and prompts
+func (w Window) String() string {
+	const layout = "2006-01-02 15:04:05"
+	switch {
+	case w.Since.IsZero() && w.Until.IsZero():
+		return "all entries"
+	case w.Until.IsZero():
+		return "since " + w.Since.Format(layout)
+	case w.Since.IsZero():
+		return "until " + w.Until.Format(layout)
+	}
+	return w.Since.Format(layout) + " to " + w.Until.Format(layout)
+}
+
+// ParseWindow builds a window from the --since, --until and --around
+// flags; around takes span on each side of the given time
+func ParseWindow(since, until, around string, span time.Duration) (Window, error) {
+	var w Window
+	var err error
+
+	if around != "" {
+		if since != "" || until != "" {
+			return w, fmt.Errorf("--around cannot be combined with --since or --until")
+		}
+		t, err := ParseTime(around)
+		if err != nil {
+			return w, err
+		}
+		return Window{Since: t.Add(-span), Until: t.Add(span)}, nil
+	}
+
+	if since != "" {
+		if w.Since, err = ParseTime(since); err != nil {
+			return w, err
+		}
+	}
+	if until != "" {
+		if w.Until, err = ParseTime(until); err != nil {
+			return w, err
+		}
+	}
+	if !w.Since.IsZero() && !w.Until.IsZero() && w.Until.Before(w.Since) {
+		return w, fmt.Errorf("--until %s is before --since %s", until, since)
+	}
+	return w, nil
+}
+
+// relativeRe matches durations counted back from now, like 2h or 3d
+var relativeRe = regexp.MustCompile(`^(\d+)d$`)
+
+// ParseTime parses a time given on the command line: a duration ago
+// (30m, 2h, 1d), now, today, yesterday, a time of day today (14:05), a
+// date, or any timestamp format found in logs
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	ref := now()
+	midnight := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, time.Local)
+
+	switch strings.ToLower(s) {
+	case "now":
+		return ref, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+	if m := relativeRe.FindStringSubmatch(s); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		return ref.AddDate(0, 0, -days), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return ref.Add(-d), nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return time.Date(ref.Year(), ref.Month(), ref.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, ok := parseTimeValue(s); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (try 2h, 14:05, 2025-02-01 14:05 or RFC 3339)", s)
+}
@@ -0,0 +1,246 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixNow pins the clock used for year-less timestamps and relative times
+func fixNow(t *testing.T, at time.Time) {
+	t.Helper()
+	old := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = old })
+}
+
+func TestFindTimestamp(t *testing.T) {
+	fixNow(t, time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local))
+	utc := func(h, m, s, ns int) time.Time { return time.Date(2025, 2, 1, h, m, s, ns, time.UTC) }
+	local := func(h, m, s, ns int) time.Time { return time.Date(2025, 2, 1, h, m, s, ns, time.Local) }
+
+	tests := []struct {
+		name string
+		line string
+		want time.Time
+	}{
+		{"rfc3339", "2025-02-01T10:00:00Z INFO started", utc(10, 0, 0, 0)},
+		{"rfc3339 offset", "ts=2025-02-01T12:00:00.5+02:00 level=info", utc(10, 0, 0, 5e8)},
+		{"space and comma fraction", "2025-02-01 10:00:00,250 ERROR boom", local(10, 0, 0, 25e7)},
+		{"utc suffix", "2025-02-01 10:00:00 UTC [1] LOG: ready", utc(10, 0, 0, 0)},
+		{"go log", "2025/02/01 10:00:00 listening on :8080", local(10, 0, 0, 0)},
+		{"access", `1.2.3.4 - - [01/Feb/2025:10:00:00 +0000] "GET / HTTP/1.1" 200 5`, utc(10, 0, 0, 0)},
+		{"month first", "02/13/2025 10:00:00 WARN slow", time.Date(2025, 2, 13, 10, 0, 0, 0, time.Local)},
+		{"day first", "13/02/2025 10:00:00 WARN slow", time.Date(2025, 2, 13, 10, 0, 0, 0, time.Local)},
+		{"ctime", "[Sat Feb 01 10:00:00.123456 2025] [core:error] oops", local(10, 0, 0, 123456000)},
+		{"syslog", "Feb  1 10:00:00 host sshd[1]: Accepted", local(10, 0, 0, 0)},
+		{"klog", "E0201 10:00:00.000001    1 main.go:1] failed", local(10, 0, 0, 1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, ok := findTimestamp(tt.line)
+			if !ok {
+				t.Fatalf("findTimestamp(%q) found nothing", tt.line)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("findTimestamp(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+
+	if _, _, ok := findTimestamp("ERROR no time here, port 8080"); ok {
+		t.Error("findTimestamp should find nothing in a line without a timestamp")
+	}
+	if _, _, ok := findTimestamp("02/01/2025 10:00:00 WARN slow"); ok {
+		t.Error("findTimestamp should skip a numeric date that could be either order")
+	}
+}
+
+func TestDateOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  dateOrder
+		// first is the date of the first line when read in that order
+		first time.Time
+	}{
+		{
+			"day first",
+			[]string{"01/02/2025 10:00:00 INFO start", "13/02/2025 10:00:00 WARN slow"},
+			orderDayFirst,
+			time.Date(2025, 2, 1, 10, 0, 0, 0, time.Local),
+		},
+		{
+			"month first",
+			[]string{"01/02/2025 10:00:00 INFO start", "01/13/2025 10:00:00 WARN slow"},
+			orderMonthFirst,
+			time.Date(2025, 1, 2, 10, 0, 0, 0, time.Local),
+		},
+		{
+			"ambiguous",
+			[]string{"01/02/2025 10:00:00 INFO start", "02/03/2025 10:00:00 WARN slow"},
+			orderUnknown,
+			time.Time{},
+		},
+		{
+			"conflicting",
+			[]string{"13/02/2025 10:00:00 INFO start", "01/13/2025 10:00:00 WARN slow"},
+			orderUnknown,
+			time.Date(2025, 2, 13, 10, 0, 0, 0, time.Local),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectDateOrder(tt.lines); got != tt.want {
+				t.Errorf("detectDateOrder() = %v, want %v", got, tt.want)
+			}
+			_, entries := parseEntries(tt.lines)
+			if len(entries) == 0 || !entries[0].Time.Equal(tt.first) {
+				t.Errorf("first entry time = %v, want %v", entries[0].Time, tt.first)
+			}
+		})
+	}
+}
+
+func TestWithYear(t *testing.T) {
+	fixNow(t, time.Date(2025, 1, 2, 12, 0, 0, 0, time.Local))
+
+	_, got, _ := findTimestamp("Dec 31 23:00:00 host app: late")
+	if got.Year() != 2024 {
+		t.Errorf("December seen in January should be last year, got %v", got)
+	}
+	_, got, _ = findTimestamp("Jan  2 11:00:00 host app: now")
+	if got.Year() != 2025 {
+		t.Errorf("today should be this year, got %v", got)
+	}
+}
+
+func TestParseTimeValue(t *testing.T) {
+	want := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	for _, value := range []string{
+		"1738404000",
+		"1738404000.0",
+		"1738404000000",
+		"1738404000000000",
+		"1738404000000000000",
+		"2025-02-01T10:00:00Z",
+	} {
+		got, ok := parseTimeValue(value)
+		if !ok || !got.Equal(want) {
+			t.Errorf("parseTimeValue(%q) = %v, %v, want %v", value, got, ok, want)
+		}
+	}
+	if _, ok := parseTimeValue("yesterday-ish"); ok {
+		t.Error("parseTimeValue should reject text without a timestamp")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	ref := time.Date(2025, 2, 1, 15, 0, 0, 0, time.Local)
+	fixNow(t, ref)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "2h", want: ref.Add(-2 * time.Hour)},
+		{input: "1d", want: ref.AddDate(0, 0, -1)},
+		{input: "now", want: ref},
+		{input: "today", want: time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local)},
+		{input: "14:05", want: time.Date(2025, 2, 1, 14, 5, 0, 0, time.Local)},
+		{input: "2025-01-31", want: time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)},
+		{input: "2025-01-31 14:05", want: time.Date(2025, 1, 31, 14, 5, 0, 0, time.Local)},
+		{input: "2025-01-31T14:05:00Z", want: time.Date(2025, 1, 31, 14, 5, 0, 0, time.UTC)},
+		{input: "teatime", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTime(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	fixNow(t, time.Date(2025, 2, 1, 15, 0, 0, 0, time.Local))
+
+	w, err := ParseWindow("", "", "14:05", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("ParseWindow() error = %v", err)
+	}
+	if w.Since.Format("15:04") != "14:00" || w.Until.Format("15:04") != "14:10" {
+		t.Errorf("--around 14:05 = %v, want 14:00 to 14:10", w)
+	}
+
+	if _, err := ParseWindow("14:00", "", "14:05", time.Minute); err == nil {
+		t.Error("--around with --since should be rejected")
+	}
+	if _, err := ParseWindow("14:30", "14:00", "", 0); err == nil {
+		t.Error("--until before --since should be rejected")
+	}
+	if w, _ := ParseWindow("", "", "", 0); !w.IsZero() {
+		t.Error("no flags should give an open window")
+	}
+}
+
+func TestAnalyzeStringWithin(t *testing.T) {
+	content := `2025-02-01 13:59:00 INFO warming up
+2025-02-01 14:01:00 ERROR request failed
+Traceback (most recent call last):
+  File "app.py", line 1, in <module>
+2025-02-01 14:02:00 WARN retrying
+2025-02-01 14:30:00 INFO recovered
+`
+	window := Window{
+		Since: time.Date(2025, 2, 1, 14, 0, 0, 0, time.Local),
+		Until: time.Date(2025, 2, 1, 14, 10, 0, 0, time.Local),
+	}
+	result := AnalyzeStringWithin(content, window)
+
+	if result.Total != 2 {
+		t.Errorf("Total = %d, want the 2 entries in the window", result.Total)
+	}
+	if result.ByLevel[LevelError] != 1 || result.ByLevel[LevelWarn] != 1 {
+		t.Errorf("ByLevel = %v, want 1 error and 1 warning", result.ByLevel)
+	}
+	if result.First.Minute() != 1 || result.Last.Minute() != 2 {
+		t.Errorf("time range = %v to %v, want 14:01 to 14:02", result.First, result.Last)
+	}
+	if formatted := result.ToFormat(); !strings.Contains(formatted, "Time Window: 2025-02-01 14:00:00 to 2025-02-01 14:10:00") {
+		t.Errorf("Formatted output should state the window, got:\n%s", formatted)
+	}
+
+	if all := AnalyzeString(content); all.Total != 4 {
+		t.Errorf("AnalyzeString Total = %d, want 4", all.Total)
+	}
+}
+
+func TestAnalyzeWindowLimit(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	content := "2025-02-01 13:00:00 INFO a\n2025-02-01 14:00:00 INFO b\n2025-02-01 14:01:00 INFO c\n2025-02-01 14:02:00 INFO d\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New(file)
+	analyzer.SetWindow(Window{Since: time.Date(2025, 2, 1, 14, 0, 0, 0, time.Local)})
+	result, err := analyzer.Analyze(2)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if result.Total != 2 || result.First.Hour() != 14 || result.Last.Minute() != 1 {
+		t.Errorf("the limit should count entries inside the window, got %d from %v to %v", result.Total, result.First, result.Last)
+	}
+}
@@ -0,0 +1,139 @@
+package man
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Candidate is an installed command that may do what the user described
+type Candidate struct {
+	Name    string
+	Section string
+	Summary string
+	Score   float64
+}
+
+// commandSections hold pages for runnable commands
+var commandSections = map[byte]bool{'1': true, '6': true, '8': true}
+
+// aproposLineRe matches "ls (1)  - list directory contents" as printed by
+// apropos and whatis, including "name, alias (8) - ..." forms
+var aproposLineRe = regexp.MustCompile(`^(\S+?)(?:,\s*\S+)*\s*\(([0-9][A-Za-z0-9]*)\)\s+-+\s+(.*)$`)
+
+// Apropos searches the whatis database for pages whose name or summary
+// matches any of the keywords
+func Apropos(keywords []string) ([]Candidate, error) {
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"--"}, keywords...)
+	output, err := exec.Command("apropos", args...).Output()
+	// apropos exits 16 when nothing matches, which isn't an error here
+	if err != nil && len(output) == 0 {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("apropos failed: %w", err)
+	}
+
+	return parseApropos(string(output)), nil
+}
+
+// parseApropos parses apropos output into candidates
+func parseApropos(output string) []Candidate {
+	var candidates []Candidate
+	for _, line := range strings.Split(output, "\n") {
+		m := aproposLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{Name: m[1], Section: m[2], Summary: m[3]})
+	}
+	return candidates
+}
+
+// FindCommands finds installed commands matching a description, combining
+// apropos with the full-text index when it has been built. Only commands
+// found on PATH are returned, best matches first. indexed reports whether
+// the index was available.
+func FindCommands(description string, limit int) (candidates []Candidate, indexed bool) {
+	terms := tokenize(description)
+	keywords := searchKeywords(description)
+
+	merged := make(map[string]*Candidate)
+	add := func(c Candidate, score float64) {
+		if c.Section == "" || !commandSections[c.Section[0]] {
+			return
+		}
+		if existing, ok := merged[c.Name]; ok {
+			existing.Score += score
+			return
+		}
+		c.Score = score
+		merged[c.Name] = &c
+	}
+
+	// apropos matches any keyword, rank by how many terms the summary covers
+	if results, err := Apropos(keywords); err == nil {
+		for _, c := range results {
+			matched := 0
+			text := tokenize(c.Name + " " + c.Summary)
+			for _, term := range terms {
+				for _, t := range text {
+					if t == term {
+						matched++
+						break
+					}
+				}
+			}
+			if matched > 0 {
+				add(c, float64(matched))
+			}
+		}
+	}
+
+	if index, err := LoadIndex(); err == nil {
+		indexed = true
+		for _, r := range index.Search(description, limit*4) {
+			add(Candidate{Name: r.Name, Section: r.Section, Summary: r.Summary}, r.Score)
+		}
+	}
+
+	for _, c := range merged {
+		if _, err := exec.LookPath(c.Name); err != nil {
+			continue
+		}
+		candidates = append(candidates, *c)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, indexed
+}
+
+// searchKeywords returns the distinct, unstemmed words of a description
+// worth searching for, without stop words
+func searchKeywords(description string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(description)) {
+		word = strings.Trim(word, `.,;:!?"'()`)
+		if len(word) < 3 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
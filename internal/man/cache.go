@@ -0,0 +1,165 @@
+package man
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CacheDir returns the directory holding rendered pages and the index
+func CacheDir() (string, error) {
+	// Check environment variable first
+	if dir := os.Getenv("OHMAN_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ohman", "man"), nil
+}
+
+// hashKey returns a short hex digest of s
+func hashKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// cacheFile returns where an entry for source is cached under sub. The
+// name has a part for the source and one for its version, like its
+// modification time, so writing a new version can evict the old one
+func cacheFile(sub, source, version string) (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := hashKey(source)
+	return filepath.Join(dir, sub, key[:2], key+"-"+hashKey(version)+".txt"), nil
+}
+
+// fileVersion identifies the version of a file by its modification time
+// and size
+func fileVersion(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d|%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// cachePath returns where the rendered page for a source path is cached;
+// updated pages get a new path and are rendered again
+func cachePath(path string) (string, error) {
+	version, err := fileVersion(path)
+	if err != nil {
+		return "", err
+	}
+	return cacheFile("pages", path, version)
+}
+
+// readCache returns the cached rendering of the page at path
+func readCache(path string) (string, bool) {
+	file, err := cachePath(path)
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || len(data) == 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// writeCache stores the rendering of the page at path
+func writeCache(path, content string) error {
+	file, err := cachePath(path)
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(file, content)
+}
+
+// writeCacheFile writes a file in the cache, creating its directory, and
+// removes the entries of older versions of the same source
+func writeCacheFile(file, content string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(file, []byte(content)); err != nil {
+		return err
+	}
+
+	evictStale(file)
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file of our own first, then
+// renames it, so that concurrent writers never see or clobber a partial file
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// evictStale removes the cached versions of file's source other than file
+func evictStale(file string) {
+	base := filepath.Base(file)
+	i := strings.LastIndex(base, "-")
+	if i < 0 {
+		return
+	}
+	stale, _ := filepath.Glob(filepath.Join(filepath.Dir(file), base[:i]+"-*.txt"))
+	for _, old := range stale {
+		if old != file {
+			os.Remove(old)
+		}
+	}
+}
+
+// ClearCache removes all cached pages and the index
+func ClearCache() error {
+	dir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// locate returns the source file of a man page, as reported by man -w or,
+// without man, found in the man paths
+func locate(command string, section int) (string, error) {
+	args := []string{"-w"}
+	if section > 0 {
+		args = append(args, fmt.Sprintf("%d", section))
+	}
+	args = append(args, command)
+
+	output, err := runMan(args...)
+	if err != nil {
+		return findPage(command, section)
+	}
+	path := strings.TrimSpace(strings.SplitN(output, "\n", 2)[0])
+	if path == "" {
+		return "", fmt.Errorf("man page for %s not found", command)
+	}
+	return path, nil
+}
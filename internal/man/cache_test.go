@@ -0,0 +1,99 @@
+package man
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPageCache(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
+
+	source := filepath.Join(t.TempDir(), "ls.1")
+	if err := os.WriteFile(source, []byte(".TH LS 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := readCache(source); ok {
+		t.Fatal("expected cache miss before writing")
+	}
+	if err := writeCache(source, "LS(1) rendered"); err != nil {
+		t.Fatalf("writeCache() error = %v", err)
+	}
+	if content, ok := readCache(source); !ok || content != "LS(1) rendered" {
+		t.Errorf("readCache() = %q, %v", content, ok)
+	}
+
+	// Updating the source invalidates the cached rendering
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(source, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readCache(source); ok {
+		t.Error("expected cache miss after the page changed")
+	}
+
+	if err := ClearCache(); err != nil {
+		t.Fatalf("ClearCache() error = %v", err)
+	}
+	if dir, _ := CacheDir(); dir != "" {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Error("ClearCache() should remove the cache directory")
+		}
+	}
+}
+
+func TestPageCacheEvictsStale(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
+
+	source := filepath.Join(t.TempDir(), "ls.1")
+	if err := os.WriteFile(source, []byte(".TH LS 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCache(source, "old"); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := cachePath(source)
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(source, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCache(source, "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("writing a new version should evict the old entry")
+	}
+	if content, ok := readCache(source); !ok || content != "new" {
+		t.Errorf("readCache() = %q, %v, want the new version", content, ok)
+	}
+}
+
+func TestWriteCacheFileConcurrent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pages", "ab", "abcd-ef.txt")
+	want := strings.Repeat("x", 1<<16)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := writeCacheFile(file, want); err != nil {
+				t.Errorf("writeCacheFile() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if data, err := os.ReadFile(file); err != nil || string(data) != want {
+		t.Errorf("cache file has %d bytes, %v, want the whole content", len(data), err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(filepath.Dir(file), "*.tmp")); len(tmps) > 0 {
+		t.Errorf("temporary files left behind: %v", tmps)
+	}
+}
@@ -0,0 +1,194 @@
+package man
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// helpTimeout bounds each help probe, for tools that ignore the flag and
+// start working or wait for input
+var helpTimeout = 3 * time.Second
+
+// HelpPolicy decides which binaries GetHelpOutput may run. Binaries in
+// system directories are run unless denied; others need to be allowed or
+// confirmed.
+type HelpPolicy struct {
+	// Allow lists commands that may run wherever they are installed
+	Allow []string
+	// Deny lists commands that are never run, in addition to the defaults
+	Deny []string
+	// Confirm asks whether to run a binary outside the system directories.
+	// Without it such binaries are refused.
+	Confirm func(command, path string) bool
+}
+
+// helpPolicy is the policy GetHelpOutput applies
+var helpPolicy HelpPolicy
+
+// SetHelpPolicy sets the policy for running help probes
+func SetHelpPolicy(policy HelpPolicy) {
+	helpPolicy = policy
+}
+
+// deniedHelp are commands that may act rather than print help when given
+// a flag they don't know, or take over the session
+var deniedHelp = map[string]bool{
+	"reboot": true, "shutdown": true, "halt": true, "poweroff": true,
+	"init": true, "telinit": true, "kexec": true, "systemd": true,
+	"mkswap": true, "wipefs": true, "shred": true, "dd": true, "killall5": true,
+	"sudo": true, "su": true, "doas": true, "pkexec": true, "login": true, "passwd": true,
+	"xdg-open": true, "open": true,
+}
+
+// systemDirs hold binaries installed by the system or a package manager.
+// Anything else, like ~/bin or ./script, is an unknown binary.
+var systemDirs = []string{
+	"/bin/", "/sbin/", "/usr/", "/opt/", "/snap/", "/nix/",
+	"/run/current-system/", "/home/linuxbrew/",
+}
+
+// helpEnv is the environment help probes run with: enough to print help
+// in plain text, without the user's tokens and credentials
+func helpEnv() []string {
+	env := []string{"TERM=dumb", "NO_COLOR=1", "PAGER=cat", "MANPAGER=cat", "GIT_PAGER=cat", "LC_ALL=C"}
+	for _, name := range []string{"PATH", "HOME", "USER", "TMPDIR"} {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// checkHelpAllowed returns the binary a help probe would run, or an error
+// if the policy doesn't allow running it
+func checkHelpAllowed(command string) (string, error) {
+	return checkProbeAllowed(command, true)
+}
+
+// checkProbeAllowed applies the help policy to a probe of command. Unknown
+// binaries are only confirmed when ask is set.
+func checkProbeAllowed(command string, ask bool) (string, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", fmt.Errorf("command not found: %s", command)
+	}
+	name := filepath.Base(command)
+	if deniedHelp[name] || strings.HasPrefix(name, "mkfs") || containsString(helpPolicy.Deny, name) {
+		return "", fmt.Errorf("not running %s to read its help", command)
+	}
+	if containsString(helpPolicy.Allow, name) {
+		return path, nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range systemDirs {
+		if strings.HasPrefix(abs, dir) {
+			return path, nil
+		}
+	}
+	if ask && helpPolicy.Confirm != nil && helpPolicy.Confirm(command, abs) {
+		return path, nil
+	}
+	return "", fmt.Errorf("not running unknown binary %s to read its help", abs)
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHelpOutput tries to get help output using --help, -h, or -help flags.
+// Subcommands, if given, go before the flag, as in "docker run --help".
+// Probes run with a timeout, closed stdin and a minimal environment, and
+// successful output is cached per binary version.
+func GetHelpOutput(command string, subcommands ...string) (string, error) {
+	path, err := checkHelpAllowed(command)
+	if err != nil {
+		return "", err
+	}
+
+	cacheFile, cacheErr := probeCachePath(path, subcommands)
+	if cacheErr == nil {
+		if data, err := os.ReadFile(cacheFile); err == nil && len(data) > 0 {
+			return string(data), nil
+		}
+	}
+
+	// Common help flags to try, in order of preference
+	helpFlags := []string{"--help", "-h", "-help"}
+
+	for _, flag := range helpFlags {
+		args := append(append([]string{}, subcommands...), flag)
+		content, err := runProbe(path, args)
+		if errors.Is(err, context.DeadlineExceeded) {
+			// A tool that hangs on one flag will likely hang on the others
+			return "", fmt.Errorf("%s %s did not finish within %s", command, strings.Join(args, " "), helpTimeout)
+		}
+		if err == nil && content != "" {
+			if cacheErr == nil {
+				_ = writeCacheFile(cacheFile, content)
+			}
+			return content, nil
+		}
+	}
+
+	return "", fmt.Errorf("no help output found for %s", command)
+}
+
+// runProbe runs one help or version probe. Output is read from stdout, or
+// from stderr for programs that print it there.
+func runProbe(path string, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), helpTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	// Usage lines name the program as invoked, show "sed" not "/usr/bin/sed"
+	cmd.Args[0] = filepath.Base(path)
+	cmd.Env = helpEnv()
+	cmd.Dir = os.TempDir() // Stdin is left nil, so the command reads from the null device
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Don't wait on children that keep the output pipes open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return "", err
+	}
+	if content := strings.TrimSpace(stdout.String()); content != "" {
+		return content, nil
+	}
+	return strings.TrimSpace(stderr.String()), nil
+}
+
+// probeCachePath returns where the output of probing a binary with args is
+// cached. The key includes the binary's modification time and size, so
+// upgrading the tool probes it again.
+func probeCachePath(path string, args []string) (string, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	version, err := fileVersion(path)
+	if err != nil {
+		return "", err
+	}
+	return cacheFile("help", path+"|"+strings.Join(args, " "), version)
+}
@@ -0,0 +1,134 @@
+package man
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScript installs an executable script in a directory outside the
+// system directories and puts it on PATH
+func writeScript(t *testing.T, name, body string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return path
+}
+
+// withHelpPolicy sets the help policy for the duration of a test
+func withHelpPolicy(t *testing.T, policy HelpPolicy) {
+	t.Helper()
+	old := helpPolicy
+	SetHelpPolicy(policy)
+	t.Cleanup(func() { SetHelpPolicy(old) })
+}
+
+func TestCheckHelpAllowed(t *testing.T) {
+	writeScript(t, "ohman-test-tool", "echo usage\n")
+
+	tests := []struct {
+		name    string
+		command string
+		policy  HelpPolicy
+		wantErr bool
+	}{
+		{name: "system binary", command: "ls", wantErr: false},
+		{name: "denied by default", command: "shutdown", wantErr: true},
+		{name: "denied by config", command: "ls", policy: HelpPolicy{Deny: []string{"ls"}}, wantErr: true},
+		{name: "unknown binary", command: "ohman-test-tool", wantErr: true},
+		{name: "unknown binary allowed", command: "ohman-test-tool", policy: HelpPolicy{Allow: []string{"ohman-test-tool"}}, wantErr: false},
+		{
+			name:    "unknown binary confirmed",
+			command: "ohman-test-tool",
+			policy:  HelpPolicy{Confirm: func(command, path string) bool { return true }},
+			wantErr: false,
+		},
+		{
+			name:    "unknown binary declined",
+			command: "ohman-test-tool",
+			policy:  HelpPolicy{Confirm: func(command, path string) bool { return false }},
+			wantErr: true,
+		},
+		{name: "missing", command: "nonexistentcmd123456", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withHelpPolicy(t, tt.policy)
+			if tt.command == "shutdown" {
+				// Only the name matters, not where it is installed
+				writeScript(t, "shutdown", "exit 1\n")
+			}
+			_, err := checkHelpAllowed(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkHelpAllowed(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetHelpOutputSandbox(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
+	t.Setenv("OHMAN_TEST_SECRET", "hunter2")
+	withHelpPolicy(t, HelpPolicy{Allow: []string{"ohman-env-tool"}})
+	// Reads stdin, which must be closed rather than the terminal
+	writeScript(t, "ohman-env-tool", `cat; echo "usage: ohman-env-tool secret=$OHMAN_TEST_SECRET term=$TERM"`+"\n")
+
+	output, err := GetHelpOutput("ohman-env-tool")
+	if err != nil {
+		t.Fatalf("GetHelpOutput() error = %v", err)
+	}
+	if output != "usage: ohman-env-tool secret= term=dumb" {
+		t.Errorf("GetHelpOutput() = %q, want the environment stripped", output)
+	}
+}
+
+func TestGetHelpOutputTimeout(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
+	withHelpPolicy(t, HelpPolicy{Allow: []string{"ohman-slow-tool"}})
+	writeScript(t, "ohman-slow-tool", "sleep 30\n")
+
+	old := helpTimeout
+	helpTimeout = 200 * time.Millisecond
+	defer func() { helpTimeout = old }()
+
+	start := time.Now()
+	_, err := GetHelpOutput("ohman-slow-tool")
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("GetHelpOutput() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetHelpOutput() took %s, want it stopped after the timeout", elapsed)
+	}
+}
+
+func TestGetHelpOutputCache(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
+	withHelpPolicy(t, HelpPolicy{Allow: []string{"ohman-count-tool"}})
+	counter := filepath.Join(t.TempDir(), "runs")
+	path := writeScript(t, "ohman-count-tool", "echo run >> "+counter+"\necho usage: ohman-count-tool\n")
+
+	for i := 0; i < 2; i++ {
+		if _, err := GetHelpOutput("ohman-count-tool"); err != nil {
+			t.Fatalf("GetHelpOutput() error = %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(counter); strings.Count(string(data), "run") != 1 {
+		t.Errorf("tool ran %d times, want the second lookup cached", strings.Count(string(data), "run"))
+	}
+
+	// A new version of the binary is probed again
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho run >> "+counter+"\necho usage: ohman-count-tool v2\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	output, err := GetHelpOutput("ohman-count-tool")
+	if err != nil || output != "usage: ohman-count-tool v2" {
+		t.Errorf("GetHelpOutput() = %q, %v, want the new version's help", output, err)
+	}
+}
@@ -0,0 +1,416 @@
+package man
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexVersion is bumped when the index format changes
+const indexVersion = 1
+
+// summaryWeight counts NAME terms several times, since the one-line summary
+// says what a page is about
+const summaryWeight = 3
+
+// defaultManPaths are searched when neither MANPATH nor manpath is available
+var defaultManPaths = []string{"/usr/local/share/man", "/usr/share/man", "/usr/man", "/opt/homebrew/share/man"}
+
+// compressionExts are stripped from man page file names
+var compressionExts = []string{".gz", ".bz2", ".xz", ".lzma", ".zst", ".Z"}
+
+// IndexEntry describes one indexed man page
+type IndexEntry struct {
+	Name    string `json:"name"`
+	Section string `json:"section"`
+	Path    string `json:"path"`
+	ModTime int64  `json:"mtime"`
+	Summary string `json:"summary"`
+	Length  int    `json:"length"`
+}
+
+// Posting records how often a term occurs in a page
+type Posting struct {
+	Page  int `json:"p"`
+	Count int `json:"n"`
+}
+
+// Index is a full-text index of the installed man pages
+type Index struct {
+	Version  int                  `json:"version"`
+	Built    time.Time            `json:"built"`
+	Pages    []IndexEntry         `json:"pages"`
+	Postings map[string][]Posting `json:"postings"`
+}
+
+// SearchResult is a page matching a search
+type SearchResult struct {
+	IndexEntry
+	Score float64
+}
+
+// pageFile is a man page source file found on disk
+type pageFile struct {
+	name    string
+	section string
+	path    string
+	modTime int64
+}
+
+// ManPaths returns the directories man pages are installed in
+func ManPaths() []string {
+	var paths []string
+	if env := os.Getenv("MANPATH"); env != "" {
+		for _, p := range strings.Split(env, ":") {
+			// An empty component stands for the system defaults
+			if p == "" {
+				paths = append(paths, systemManPaths()...)
+				continue
+			}
+			paths = append(paths, p)
+		}
+	} else {
+		paths = systemManPaths()
+	}
+
+	var existing []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
+
+// systemManPaths asks manpath for the configured directories
+func systemManPaths() []string {
+	if output, err := exec.Command("manpath", "-q").Output(); err == nil {
+		if paths := strings.TrimSpace(string(output)); paths != "" {
+			return strings.Split(paths, ":")
+		}
+	}
+	return defaultManPaths
+}
+
+// pageFromPath splits a man page file name like "git-commit.1.gz" or
+// "CA.pl.1ssl" into its name and section
+func pageFromPath(path string) (name, section string, ok bool) {
+	base := filepath.Base(path)
+	for _, ext := range compressionExts {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	idx := strings.LastIndex(base, ".")
+	if idx <= 0 || idx == len(base)-1 {
+		return "", "", false
+	}
+	name, section = base[:idx], base[idx+1:]
+	if c := section[0]; !(c >= '0' && c <= '9') && c != 'n' && c != 'l' {
+		return "", "", false
+	}
+	return name, section, true
+}
+
+// listPages finds all man page files under the man paths. Only the man*
+// directories are read, localized and preformatted (cat*) trees are skipped.
+func listPages(manPaths []string) []pageFile {
+	var pages []pageFile
+	seen := make(map[string]bool)
+
+	for _, root := range manPaths {
+		dirs, err := filepath.Glob(filepath.Join(root, "man*"))
+		if err != nil {
+			continue
+		}
+		for _, dir := range dirs {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				name, section, ok := pageFromPath(entry.Name())
+				if !ok {
+					continue
+				}
+
+				// Earlier man paths take precedence, like man itself
+				key := name + "." + section
+				if seen[key] {
+					continue
+				}
+
+				info, err := entry.Info()
+				if err != nil {
+					continue
+				}
+				seen[key] = true
+				pages = append(pages, pageFile{
+					name:    name,
+					section: section,
+					path:    filepath.Join(dir, entry.Name()),
+					modTime: info.ModTime().Unix(),
+				})
+			}
+		}
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].name != pages[j].name {
+			return pages[i].name < pages[j].name
+		}
+		return pages[i].section < pages[j].section
+	})
+	return pages
+}
+
+// renderPageFile renders a page file, using the page cache
+func renderPageFile(page pageFile) (string, error) {
+	if content, ok := readCache(page.path); ok {
+		return content, nil
+	}
+
+	// Without man, render the source in Go rather than failing every page
+	if _, err := exec.LookPath("man"); err != nil {
+		content, err := RenderFile(page.path)
+		if err != nil {
+			return "", err
+		}
+		_ = writeCache(page.path, content)
+		return content, nil
+	}
+
+	// man -l renders a file directly, without looking the page up again.
+	// BSD man lacks -l, fall back to a lookup by name and section.
+	output, err := exec.Command("sh", "-c", `man -l "$1" 2>/dev/null | col -b`, "sh", page.path).Output()
+	content := string(output)
+	if err != nil || strings.TrimSpace(content) == "" {
+		if content, err = render(page.name, page.section); err != nil {
+			if content, err = RenderFile(page.path); err != nil {
+				return "", fmt.Errorf("failed to render %s", page.path)
+			}
+		}
+	}
+
+	_ = writeCache(page.path, content)
+	return content, nil
+}
+
+// BuildIndex renders every installed man page, reusing cached renderings,
+// and writes the full-text index to the cache directory. progress, if set,
+// is called after each page.
+func BuildIndex(progress func(done, total int)) (*Index, error) {
+	index := buildIndex(listPages(ManPaths()), renderPageFile, progress)
+	if len(index.Pages) == 0 {
+		return nil, fmt.Errorf("no man pages found in %s", strings.Join(ManPaths(), ":"))
+	}
+	if err := index.Save(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// buildIndex renders pages concurrently and indexes their terms
+func buildIndex(pages []pageFile, renderFn func(pageFile) (string, error), progress func(done, total int)) *Index {
+	type rendered struct {
+		page    pageFile
+		content string
+		err     error
+	}
+
+	results := make([]rendered, len(pages))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				content, err := renderFn(pages[i])
+				results[i] = rendered{page: pages[i], content: content, err: err}
+				if progress != nil {
+					mu.Lock()
+					done++
+					progress(done, len(pages))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range pages {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	index := &Index{
+		Version:  indexVersion,
+		Built:    time.Now(),
+		Postings: make(map[string][]Posting),
+	}
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+
+		parsed := Parse(r.page.name, 0, r.content)
+		counts := make(map[string]int)
+		length := 0
+		for _, term := range tokenize(r.content) {
+			counts[term]++
+			length++
+		}
+		for _, term := range tokenize(parsed.Name) {
+			counts[term] += summaryWeight
+			length += summaryWeight
+		}
+
+		id := len(index.Pages)
+		index.Pages = append(index.Pages, IndexEntry{
+			Name:    r.page.name,
+			Section: r.page.section,
+			Path:    r.page.path,
+			ModTime: r.page.modTime,
+			Summary: summaryOf(parsed),
+			Length:  length,
+		})
+		for term, count := range counts {
+			index.Postings[term] = append(index.Postings[term], Posting{Page: id, Count: count})
+		}
+	}
+
+	return index
+}
+
+// summaryOf returns the description part of the NAME line, e.g.
+// "list directory contents" for "ls - list directory contents"
+func summaryOf(page *ManPage) string {
+	name := page.Name
+	for _, sep := range []string{" - ", " -- ", " — "} {
+		if _, summary, ok := strings.Cut(name, sep); ok {
+			return strings.TrimSpace(summary)
+		}
+	}
+	return name
+}
+
+// indexPath returns where the index is stored
+func indexPath() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "index.json"), nil
+}
+
+// Save writes the index to the cache directory
+func (idx *Index) Save() error {
+	path, err := indexPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// LoadIndex reads the index from the cache directory
+func LoadIndex() (*Index, error) {
+	path, err := indexPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("man index not built yet, run 'ohman index': %w", err)
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to read man index: %w", err)
+	}
+	if index.Version != indexVersion {
+		return nil, fmt.Errorf("man index is outdated, run 'ohman index'")
+	}
+	return &index, nil
+}
+
+// Lookup returns the indexed pages named name
+func (idx *Index) Lookup(name string) []IndexEntry {
+	var entries []IndexEntry
+	for _, page := range idx.Pages {
+		if page.Name == name {
+			entries = append(entries, page)
+		}
+	}
+	return entries
+}
+
+// Search ranks indexed pages against query with BM25
+func (idx *Index) Search(query string, limit int) []SearchResult {
+	if len(idx.Pages) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, page := range idx.Pages {
+		total += page.Length
+	}
+	avgLen := float64(total) / float64(len(idx.Pages))
+	n := float64(len(idx.Pages))
+
+	scores := make(map[int]float64)
+	for _, term := range tokenize(query) {
+		postings := idx.Postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.Count)
+			norm := tf + bm25K1*(1-bm25B+bm25B*float64(idx.Pages[p.Page].Length)/avgLen)
+			scores[p.Page] += idf * tf * (bm25K1 + 1) / norm
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, SearchResult{IndexEntry: idx.Pages[id], Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Name < results[j].Name
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,158 @@
+package man
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPageFromPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		name    string
+		section string
+		ok      bool
+	}{
+		{"/usr/share/man/man1/ls.1.gz", "ls", "1", true},
+		{"git-commit.1", "git-commit", "1", true},
+		{"/usr/share/man/man1/CA.pl.1ssl.gz", "CA.pl", "1ssl", true},
+		{"printf.3p.bz2", "printf", "3p", true},
+		{"README", "", "", false},
+		{"notes.txt", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			name, section, ok := pageFromPath(tt.path)
+			if name != tt.name || section != tt.section || ok != tt.ok {
+				t.Errorf("pageFromPath(%q) = %q, %q, %v", tt.path, name, section, ok)
+			}
+		})
+	}
+}
+
+func TestListPages(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	for _, f := range []string{
+		filepath.Join(first, "man1", "ls.1.gz"),
+		filepath.Join(first, "man8", "mount.8"),
+		filepath.Join(second, "man1", "ls.1"),
+		filepath.Join(second, "man1", "README"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pages := listPages([]string{first, second})
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %+v", pages)
+	}
+	if pages[0].name != "ls" || filepath.Dir(filepath.Dir(pages[0].path)) != first {
+		t.Errorf("ls should come from the first man path, got %s", pages[0].path)
+	}
+	if pages[1].name != "mount" || pages[1].section != "8" {
+		t.Errorf("unexpected page %+v", pages[1])
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
+
+	docs := map[string]string{
+		"rsync": "NAME\n       rsync - a fast, versatile, remote (and local) file-copying tool\n\nDESCRIPTION\n       Rsync copies files to remote hosts over ssh.\n",
+		"scp":   "NAME\n       scp - OpenSSH secure file copy\n\nDESCRIPTION\n       scp copies files between hosts on a network.\n",
+		"grep":  "NAME\n       grep - print lines that match patterns\n\nDESCRIPTION\n       grep searches for patterns in each file.\n",
+	}
+	pages := []pageFile{{name: "grep", section: "1"}, {name: "rsync", section: "1"}, {name: "scp", section: "1"}, {name: "broken", section: "1"}}
+	renderFn := func(p pageFile) (string, error) {
+		if content, ok := docs[p.name]; ok {
+			return content, nil
+		}
+		return "", fmt.Errorf("no page")
+	}
+
+	calls := 0
+	index := buildIndex(pages, renderFn, func(done, total int) { calls++ })
+	if calls != len(pages) {
+		t.Errorf("progress called %d times, want %d", calls, len(pages))
+	}
+	if len(index.Pages) != 3 {
+		t.Fatalf("expected 3 indexed pages, got %d", len(index.Pages))
+	}
+	if entries := index.Lookup("scp"); len(entries) != 1 || entries[0].Summary != "OpenSSH secure file copy" {
+		t.Errorf("Lookup(scp) = %+v", entries)
+	}
+
+	results := index.Search("copy files to a remote host", 2)
+	if len(results) != 2 || results[0].Name != "rsync" || results[1].Name != "scp" {
+		t.Errorf("Search() = %+v, want rsync then scp", results)
+	}
+
+	// The index survives a round trip through the cache directory
+	if err := index.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadIndex()
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	if results := loaded.Search("patterns", 1); len(results) != 1 || results[0].Name != "grep" {
+		t.Errorf("loaded Search() = %+v, want grep", results)
+	}
+
+	// Concurrent saves, like two background indexers, each use their own
+	// temporary file
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := index.Save(); err != nil {
+				t.Errorf("concurrent Save() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := LoadIndex(); err != nil {
+		t.Errorf("LoadIndex() after concurrent saves error = %v", err)
+	}
+	dir, _ := CacheDir()
+	if leftover, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftover) > 0 {
+		t.Errorf("temporary files left behind: %v", leftover)
+	}
+}
+
+func TestParseApropos(t *testing.T) {
+	output := `ls (1)               - list directory contents
+git-commit (1)       - Record changes to the repository
+mount, umount (8)    - mount a filesystem
+printf (3p)          - print formatted output
+garbage line
+`
+
+	candidates := parseApropos(output)
+	if len(candidates) != 4 {
+		t.Fatalf("expected 4 candidates, got %+v", candidates)
+	}
+	if c := candidates[1]; c.Name != "git-commit" || c.Section != "1" || c.Summary != "Record changes to the repository" {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+	if c := candidates[2]; c.Name != "mount" || c.Section != "8" {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+}
+
+func TestSearchKeywords(t *testing.T) {
+	got := searchKeywords("How do I copy files to a remote host, quickly?")
+	want := "copy,files,remote,host,quickly"
+	if strings.Join(got, ",") != want {
+		t.Errorf("searchKeywords() = %v, want %s", got, want)
+	}
+}
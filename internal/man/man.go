@@ -3,6 +3,7 @@ package man
 import (
 	"fmt"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
@@ -11,20 +12,82 @@ type ManPage struct {
 	Command string
 	Section int
 	Content string
+	// Source is the provider the documentation came from, e.g. "man"
+	Source string
+
+	// Parsed from Content by Parse
+	Name        string
+	Synopsis    string
+	Description string
+	Examples    string
+	SeeAlso     []string
+	Options     []Option
+	Sections    []Section
 }
 
-// Get retrieves the man page content
+// Get retrieves the man page content. Rendered pages are cached on disk,
+// keyed by their source path and modification time.
 func Get(command string, section int) (*ManPage, error) {
+	path, err := locate(command, section)
+	if err == nil {
+		if content, ok := readCache(path); ok {
+			return parseMan(command, section, content), nil
+		}
+	}
+
+	content, err := render(command, sectionArg(section))
+	if err != nil {
+		// Containers and minimal images often lack man or col, render the
+		// page source ourselves when it is installed
+		if path == "" {
+			return nil, err
+		}
+		if content, err = RenderFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if path != "" {
+		// Caching is best effort, a read-only cache dir must not break lookups
+		_ = writeCache(path, content)
+	}
+
+	return parseMan(command, section, content), nil
+}
+
+// parseMan parses a rendered man page, recording its source
+func parseMan(command string, section int, content string) *ManPage {
+	page := Parse(command, section, content)
+	page.Source = SourceMan
+	return page
+}
+
+// sectionArg formats a section number for man, "" meaning any section
+func sectionArg(section int) string {
+	if section > 0 {
+		return fmt.Sprintf("%d", section)
+	}
+	return ""
+}
+
+// render renders a man page as plain text
+func render(command string, section string) (string, error) {
+	// A leading dash would be read by man as an option
+	if command == "" || strings.HasPrefix(command, "-") {
+		return "", fmt.Errorf("man page for %s not found", command)
+	}
+
 	// Build man command arguments
 	args := []string{}
-	if section > 0 {
-		args = append(args, fmt.Sprintf("%d", section))
+	if section != "" {
+		args = append(args, section)
 	}
 	args = append(args, command)
 
-	// Use col -b to remove formatting control characters for plain text
-	cmdStr := fmt.Sprintf("man %s 2>/dev/null | col -b", strings.Join(args, " "))
-	cmd := exec.Command("sh", "-c", cmdStr)
+	// Use col -b to remove formatting control characters for plain text.
+	// The arguments are passed to the script, never pasted into it
+	script := append([]string{"-c", `man "$@" 2>/dev/null | col -b`, "sh"}, args...)
+	cmd := exec.Command("sh", script...)
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -32,40 +95,60 @@ func Get(command string, section int) (*ManPage, error) {
 		cmd = exec.Command("man", args...)
 		output, err = cmd.Output()
 		if err != nil {
-			return nil, fmt.Errorf("man page for %s not found", command)
+			return "", fmt.Errorf("man page for %s not found", command)
 		}
 	}
 
 	content := string(output)
 	if strings.TrimSpace(content) == "" {
-		return nil, fmt.Errorf("man page for %s not found", command)
+		return "", fmt.Errorf("man page for %s not found", command)
 	}
 
-	return &ManPage{
-		Command: command,
-		Section: section,
-		Content: content,
-	}, nil
+	return content, nil
 }
 
 // Exists checks if a man page exists
 func Exists(command string) bool {
 	cmd := exec.Command("man", "-w", command)
-	return cmd.Run() == nil
+	return cmd.Run() == nil || len(findPages(command, 0)) > 0
 }
 
 // GetSections gets which sections have man pages for a command
 func GetSections(command string) []int {
+	// man -wa lists every page in one call instead of probing each section
+	var paths []string
+	if output, err := runMan("-wa", command); err == nil {
+		paths = strings.Split(strings.TrimSpace(output), "\n")
+	} else {
+		paths = findPages(command, 0)
+	}
+
 	var sections []int
-	for i := 1; i <= 8; i++ {
-		cmd := exec.Command("man", "-w", fmt.Sprintf("%d", i), command)
-		if cmd.Run() == nil {
-			sections = append(sections, i)
+	seen := make(map[int]bool)
+	for _, path := range paths {
+		_, sec, ok := pageFromPath(path)
+		if !ok || sec == "" || sec[0] < '1' || sec[0] > '9' {
+			continue
+		}
+		n := int(sec[0] - '0')
+		if !seen[n] {
+			seen[n] = true
+			sections = append(sections, n)
 		}
 	}
+	sort.Ints(sections)
 	return sections
 }
 
+// runMan runs man with args and returns its output
+func runMan(args ...string) (string, error) {
+	output, err := exec.Command("man", args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
 // GetWhatis gets a short description of a command
 func GetWhatis(command string) (string, error) {
 	cmd := exec.Command("whatis", command)
@@ -76,30 +159,23 @@ func GetWhatis(command string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// GetHelpOutput tries to get help output using --help, -h, or -help flags
-func GetHelpOutput(command string) (string, error) {
-	// Common help flags to try, in order of preference
-	helpFlags := []string{"--help", "-h", "-help"}
-
-	for _, flag := range helpFlags {
-		cmd := exec.Command(command, flag)
-		output, err := cmd.Output()
-		if err == nil {
-			content := strings.TrimSpace(string(output))
-			if content != "" {
-				return content, nil
-			}
+// GetSectionSummaries returns the whatis summary of a command in each
+// section it has a page in, e.g. 3 → "formatted output conversion"
+func GetSectionSummaries(command string) map[int]string {
+	summaries := make(map[int]string)
+	output, err := GetWhatis(command)
+	if err != nil {
+		return summaries
+	}
+	for _, c := range parseApropos(output) {
+		if c.Name != command {
+			continue
 		}
-		// Try with combined stderr (some programs output help to stderr)
-		cmd = exec.Command(command, flag)
-		output, err = cmd.CombinedOutput()
-		if err == nil {
-			content := strings.TrimSpace(string(output))
-			if content != "" {
-				return content, nil
+		if n := int(c.Section[0] - '0'); n >= 1 && n <= 9 {
+			if _, ok := summaries[n]; !ok {
+				summaries[n] = c.Summary
 			}
 		}
 	}
-
-	return "", fmt.Errorf("no help output found for %s", command)
+	return summaries
 }
@@ -1,7 +1,10 @@
 package man
 
 import (
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -107,6 +110,7 @@ func TestGetWhatis(t *testing.T) {
 }
 
 func TestGetHelpOutput(t *testing.T) {
+	t.Setenv("OHMAN_CACHE_DIR", t.TempDir())
 	tests := []struct {
 		name    string
 		command string
@@ -152,3 +156,65 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func TestOptionExcerpt(t *testing.T) {
+	content := `GREP(1)
+
+OPTIONS
+       -i, --ignore-case
+              Ignore case distinctions in patterns and input data,
+              so that characters that differ only in case match each other.
+
+       -r, --recursive
+              Read all files under each directory, recursively.
+
+       --color[=WHEN], --colour[=WHEN]
+              Surround the matched strings with escape sequences.
+
+EXIT STATUS
+       Normally the exit status is 0 if a line is selected.
+`
+
+	tests := []struct {
+		flag     string
+		contains string
+		empty    bool
+	}{
+		{"-i", "Ignore case distinctions", false},
+		{"--ignore-case", "match each other", false},
+		{"--recursive", "Read all files", false},
+		{"--color", "escape sequences", false},
+		{"-z", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			excerpt := OptionExcerpt(content, tt.flag)
+			if tt.empty {
+				if excerpt != "" {
+					t.Errorf("OptionExcerpt(%q) = %q, want empty", tt.flag, excerpt)
+				}
+				return
+			}
+			if !strings.Contains(excerpt, tt.contains) {
+				t.Errorf("OptionExcerpt(%q) = %q, want it to contain %q", tt.flag, excerpt, tt.contains)
+			}
+			if strings.Contains(excerpt, "EXIT STATUS") || (tt.flag == "-i" && strings.Contains(excerpt, "recursive")) {
+				t.Errorf("OptionExcerpt(%q) ran past the option: %q", tt.flag, excerpt)
+			}
+		})
+	}
+}
+
+func TestRenderDoesNotRunShell(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	for _, name := range []string{"x;touch pwned", "x$(touch pwned)", "x`touch pwned`", "x|touch pwned"} {
+		_, _ = render(name, "")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); !os.IsNotExist(err) {
+		t.Fatal("a command name was run by the shell")
+	}
+}
@@ -0,0 +1,30 @@
+package man
+
+import (
+	"strings"
+)
+
+// maxExcerptLines bounds the description returned for a single option
+const maxExcerptLines = 8
+
+// OptionExcerpt finds the documentation of an option such as "-r" or
+// "--recursive" in rendered man page or --help text, returning the option
+// line and its indented description, or "" if the option isn't documented
+func OptionExcerpt(content, flag string) string {
+	if flag == "" {
+		return ""
+	}
+
+	for _, opt := range parseOptions(content) {
+		if opt.Has(flag) {
+			return opt.Excerpt()
+		}
+	}
+
+	return ""
+}
+
+// indentOf returns the number of leading whitespace characters of line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
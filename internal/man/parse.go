@@ -0,0 +1,295 @@
+package man
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Section is a top-level section of a man page, such as NAME or OPTIONS
+type Section struct {
+	Name    string
+	Content string
+}
+
+// Option is a documented command-line option with its aliases
+type Option struct {
+	// Flags lists the option and its aliases, e.g. ["-r", "-R", "--recursive"]
+	Flags []string
+	// Arg is the option argument placeholder, e.g. "WHEN" for --color[=WHEN]
+	Arg string
+	// Header is the option line as written in the page
+	Header string
+	// Description is the option's description, one line per page line
+	Description string
+}
+
+// Has reports whether flag is one of the option's aliases
+func (o Option) Has(flag string) bool {
+	for _, f := range o.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Excerpt returns the option header and description on a single line,
+// bounded to maxExcerptLines page lines
+func (o Option) Excerpt() string {
+	parts := []string{o.Header}
+	for _, line := range strings.Split(o.Description, "\n") {
+		if len(parts) >= maxExcerptLines {
+			break
+		}
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	sectionHeaderRe = regexp.MustCompile(`^[A-Z][A-Z0-9 ,/&_-]*[A-Z0-9]$`)
+	optionFlagRe    = regexp.MustCompile(`^(--?[A-Za-z0-9?#@][A-Za-z0-9_?#@.+-]*)(.*)$`)
+	seeAlsoRe       = regexp.MustCompile(`[A-Za-z0-9_.:+-]+\([0-9][A-Za-z]*\)`)
+)
+
+// Parse splits rendered man page text into its sections and indexes the
+// options it documents
+func Parse(command string, section int, content string) *ManPage {
+	page := &ManPage{
+		Command:  command,
+		Section:  section,
+		Content:  content,
+		Sections: parseSections(content),
+	}
+
+	if text, ok := page.SectionContent("NAME"); ok {
+		page.Name = strings.Join(strings.Fields(text), " ")
+	}
+	if text, ok := page.SectionContent("SYNOPSIS"); ok {
+		page.Synopsis = dedent(text)
+	}
+	if text, ok := page.SectionContent("DESCRIPTION"); ok {
+		page.Description = dedent(text)
+	}
+	if text, ok := page.SectionContent("EXAMPLES"); ok {
+		page.Examples = dedent(text)
+	} else if text, ok := page.SectionContent("EXAMPLE"); ok {
+		page.Examples = dedent(text)
+	}
+	if text, ok := page.SectionContent("SEE ALSO"); ok {
+		page.SeeAlso = seeAlsoRe.FindAllString(text, -1)
+	}
+
+	// --help output has no sections, index its options directly
+	if len(page.Sections) == 0 {
+		page.Options = parseOptions(content)
+		return page
+	}
+
+	// GNU pages document options under DESCRIPTION, so index every section
+	// that may hold them rather than OPTIONS alone
+	seen := make(map[string]bool)
+	for _, s := range page.Sections {
+		switch s.Name {
+		case "NAME", "SYNOPSIS", "EXAMPLES", "EXAMPLE", "SEE ALSO":
+			continue
+		}
+		for _, opt := range parseOptions(s.Content) {
+			if seen[opt.Flags[0]] {
+				continue
+			}
+			seen[opt.Flags[0]] = true
+			page.Options = append(page.Options, opt)
+		}
+	}
+
+	return page
+}
+
+// SectionContent returns the content of the named section, matched case
+// insensitively
+func (p *ManPage) SectionContent(name string) (string, bool) {
+	for _, s := range p.Sections {
+		if strings.EqualFold(s.Name, name) {
+			return s.Content, true
+		}
+	}
+	return "", false
+}
+
+// SectionNames returns the names of the page's sections in order
+func (p *ManPage) SectionNames() []string {
+	names := make([]string, 0, len(p.Sections))
+	for _, s := range p.Sections {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Option returns the option documenting flag
+func (p *ManPage) Option(flag string) (Option, bool) {
+	for _, opt := range p.Options {
+		if opt.Has(flag) {
+			return opt, true
+		}
+	}
+	return Option{}, false
+}
+
+// parseSections splits man page text at its unindented upper-case headings.
+// The page header and footer lines are skipped.
+func parseSections(content string) []Section {
+	var sections []Section
+	var current *Section
+	var body []string
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Content = strings.TrimRight(strings.Join(body, "\n"), " \t\n")
+		current.Content = strings.TrimLeft(current.Content, "\n")
+		sections = append(sections, *current)
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if isSectionHeader(line) {
+			flush()
+			current = &Section{Name: line}
+			body = nil
+			continue
+		}
+		// Section bodies are indented, unindented text is a page header or footer
+		if current == nil || (line != "" && indentOf(line) == 0) {
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	return sections
+}
+
+// isSectionHeader reports whether line is a top-level section heading
+func isSectionHeader(line string) bool {
+	return len(line) <= 40 && sectionHeaderRe.MatchString(line)
+}
+
+// parseOptions extracts the options documented in indented man page text.
+// An option starts with a line of flags; the more indented lines that
+// follow, possibly across blank lines, are its description.
+func parseOptions(text string) []Option {
+	var options []Option
+	var current *Option
+	var desc []string
+	currentIndent := 0
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Description = strings.TrimRight(strings.Join(desc, "\n"), "\n")
+		options = append(options, *current)
+		current = nil
+		desc = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		indent := indentOf(line)
+
+		if trimmed == "" {
+			if current != nil && len(desc) > 0 {
+				desc = append(desc, "")
+			}
+			continue
+		}
+
+		if current != nil && indent > currentIndent && !(indent <= currentIndent+2 && strings.HasPrefix(trimmed, "-")) {
+			desc = append(desc, trimmed)
+			continue
+		}
+
+		flush()
+		if !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		if opt, rest, ok := parseOptionHeader(trimmed); ok {
+			current = &opt
+			currentIndent = indent
+			if rest != "" {
+				desc = append(desc, rest)
+			}
+		}
+	}
+	flush()
+
+	return options
+}
+
+// parseOptionHeader parses an option line such as "-r, -R, --recursive",
+// "--color[=WHEN]" or "-C dir, --directory=dir", returning the option and
+// any description on the same line
+func parseOptionHeader(line string) (Option, string, bool) {
+	// The option list ends where the description starts
+	head, rest := line, ""
+	if idx := strings.IndexAny(head, "\t"); idx != -1 {
+		head, rest = head[:idx], head[idx+1:]
+	}
+	if idx := strings.Index(head, "  "); idx != -1 {
+		head, rest = head[:idx], head[idx+2:]+rest
+	}
+
+	opt := Option{Header: strings.TrimSpace(head)}
+	words := 0
+	for _, item := range strings.FieldsFunc(head, func(r rune) bool { return r == ',' || r == ' ' || r == '|' }) {
+		m := optionFlagRe.FindStringSubmatch(item)
+		if m == nil {
+			// A placeholder argument like "-C dir"; more than that is prose
+			if words++; words > 2 || len(opt.Flags) == 0 {
+				return Option{}, "", false
+			}
+			if opt.Arg == "" {
+				opt.Arg = strings.Trim(item, "<>[]")
+			}
+			continue
+		}
+		opt.Flags = append(opt.Flags, m[1])
+		if arg := strings.Trim(m[2], "[]=<> "); arg != "" && opt.Arg == "" {
+			opt.Arg = arg
+		}
+	}
+	if len(opt.Flags) == 0 {
+		return Option{}, "", false
+	}
+
+	return opt, strings.TrimSpace(rest), true
+}
+
+// dedent removes the indentation shared by all non-blank lines
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := indentOf(line); common == -1 || n < common {
+			common = n
+		}
+	}
+	if common <= 0 {
+		return text
+	}
+	for i, line := range lines {
+		if len(line) >= common {
+			lines[i] = line[common:]
+		} else {
+			lines[i] = strings.TrimSpace(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,162 @@
+package man
+
+import (
+	"strings"
+	"testing"
+)
+
+const lsPage = `LS(1)                            User Commands                           LS(1)
+
+NAME
+       ls - list directory contents
+
+SYNOPSIS
+       ls [OPTION]... [FILE]...
+
+DESCRIPTION
+       List  information  about  the FILEs (the current directory by default).
+       Sort entries alphabetically if none of -cftuvSUX nor --sort is  speci‐
+       fied.
+
+       Mandatory  arguments  to  long  options are mandatory for short options
+       too.
+
+       -a, --all
+              do not ignore entries starting with .
+
+       --color[=WHEN]
+              color the output WHEN; more info below
+
+       -I, --ignore=PATTERN
+              do not list implied entries matching shell PATTERN
+
+       -w COLS, --width=COLS
+              set output width to COLS.  0 means no limit
+
+   Exit status:
+       0      if OK,
+
+EXAMPLES
+       ls -la /tmp
+
+SEE ALSO
+       dircolors(1), stat(1), info ls
+
+GNU coreutils 9.1                 April 2022                             LS(1)
+`
+
+const bsdPage = `LS(1)                   BSD General Commands Manual                  LS(1)
+
+NAME
+     ls -- list directory contents
+
+OPTIONS
+     -A      List all entries except for . and ...  Always set for the
+             super-user.
+
+     -B      Force printing of non-printable characters.
+`
+
+func TestParse(t *testing.T) {
+	page := Parse("ls", 1, lsPage)
+
+	wantSections := "NAME,SYNOPSIS,DESCRIPTION,EXAMPLES,SEE ALSO"
+	if got := strings.Join(page.SectionNames(), ","); got != wantSections {
+		t.Errorf("SectionNames() = %s, want %s", got, wantSections)
+	}
+
+	if page.Name != "ls - list directory contents" {
+		t.Errorf("Name = %q", page.Name)
+	}
+	if page.Synopsis != "ls [OPTION]... [FILE]..." {
+		t.Errorf("Synopsis = %q", page.Synopsis)
+	}
+	if !strings.Contains(page.Description, "List  information") || !strings.Contains(page.Description, "\nExit status:") {
+		t.Errorf("Description = %q", page.Description)
+	}
+	if page.Examples != "ls -la /tmp" {
+		t.Errorf("Examples = %q", page.Examples)
+	}
+	if strings.Join(page.SeeAlso, ",") != "dircolors(1),stat(1)" {
+		t.Errorf("SeeAlso = %v", page.SeeAlso)
+	}
+	if content, ok := page.SectionContent("see also"); !ok || strings.Contains(content, "GNU coreutils") {
+		t.Errorf("SectionContent(see also) = %q, %v; footer should be skipped", content, ok)
+	}
+
+	tests := []struct {
+		flag  string
+		flags string
+		arg   string
+		desc  string
+	}{
+		{"--all", "-a,--all", "", "do not ignore entries starting with ."},
+		{"--color", "--color", "WHEN", "color the output WHEN; more info below"},
+		{"-I", "-I,--ignore", "PATTERN", "do not list implied entries matching shell PATTERN"},
+		{"--width", "-w,--width", "COLS", "set output width to COLS.  0 means no limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			opt, ok := page.Option(tt.flag)
+			if !ok {
+				t.Fatalf("Option(%q) not found", tt.flag)
+			}
+			if strings.Join(opt.Flags, ",") != tt.flags || opt.Arg != tt.arg || opt.Description != tt.desc {
+				t.Errorf("Option(%q) = %+v", tt.flag, opt)
+			}
+		})
+	}
+
+	if _, ok := page.Option("-Z"); ok {
+		t.Error("Option(-Z) should not be found")
+	}
+}
+
+func TestParseSameLineDescriptions(t *testing.T) {
+	page := Parse("ls", 1, bsdPage)
+
+	if len(page.Options) != 2 {
+		t.Fatalf("expected 2 options, got %+v", page.Options)
+	}
+
+	opt, ok := page.Option("-A")
+	if !ok {
+		t.Fatal("Option(-A) not found")
+	}
+	want := "List all entries except for . and ...  Always set for the\nsuper-user."
+	if opt.Description != want {
+		t.Errorf("Description = %q, want %q", opt.Description, want)
+	}
+	if opt.Excerpt() != "-A List all entries except for . and ...  Always set for the super-user." {
+		t.Errorf("Excerpt() = %q", opt.Excerpt())
+	}
+}
+
+func TestParseOptionHeader(t *testing.T) {
+	tests := []struct {
+		line  string
+		flags string
+		arg   string
+		ok    bool
+	}{
+		{"-r, -R, --recursive", "-r,-R,--recursive", "", true},
+		{"-C dir, --directory=dir", "-C,--directory", "dir", true},
+		{"--colour[=WHEN]", "--colour", "WHEN", true},
+		{"-o <file>", "-o", "file", true},
+		{"- a bulleted sentence of prose", "", "", false},
+		{"-1 means there is no limit at all", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			opt, _, ok := parseOptionHeader(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseOptionHeader(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if ok && (strings.Join(opt.Flags, ",") != tt.flags || opt.Arg != tt.arg) {
+				t.Errorf("parseOptionHeader(%q) = %+v", tt.line, opt)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package man
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Documentation sources, as named in the docs.providers config
+const (
+	SourceMan     = "man"
+	SourceInfo    = "info"
+	SourceBuiltin = "builtin"
+	SourceHelp    = "help"
+)
+
+// DefaultProviders is the order documentation sources are tried in when
+// none is configured. Builtins come first since a builtin without a binary,
+// like cd, has at best a POSIX man page describing some other shell.
+var DefaultProviders = []string{SourceBuiltin, SourceMan, SourceInfo, SourceHelp}
+
+// sourceNames describe each source in messages
+var sourceNames = map[string]string{
+	SourceMan:     "man page",
+	SourceInfo:    "info manual",
+	SourceBuiltin: "shell builtin help",
+	SourceHelp:    "--help output",
+}
+
+// Provider is a source of documentation for commands
+type Provider interface {
+	// Name identifies the provider in the config, e.g. "info"
+	Name() string
+	// Get returns the documentation of command
+	Get(command string, section int) (*ManPage, error)
+}
+
+// providers are the available sources by name
+var providers = map[string]Provider{
+	SourceMan:     manProvider{},
+	SourceInfo:    infoProvider{},
+	SourceBuiltin: builtinProvider{},
+	SourceHelp:    helpProvider{},
+}
+
+// stubInfoRe matches the pointers to Texinfo manuals that stub man pages
+// consist of
+var stubInfoRe = regexp.MustCompile(`(?i)texinfo|\binfo\s+[('"` + "`" + `]*[a-z0-9_.-]+`)
+
+// maxStubChars is the size below which a man page pointing to info is
+// considered a stub
+const maxStubChars = 2000
+
+// SourceName describes where the page's documentation came from
+func (p *ManPage) SourceName() string {
+	if name, ok := sourceNames[p.Source]; ok {
+		return name
+	}
+	return sourceNames[SourceMan]
+}
+
+// Lookup finds the documentation of a single command, trying the named
+// providers in order. An explicit section only applies to man pages, so the
+// other providers are skipped then. A stub man page that only points to
+// the info manual is returned only when no provider has more.
+func Lookup(command string, section int, order []string) (*ManPage, error) {
+	if len(order) == 0 {
+		order = DefaultProviders
+	}
+
+	for _, name := range order {
+		if _, ok := providers[name]; !ok {
+			return nil, fmt.Errorf("unknown documentation provider %q (available: builtin, man, info, help)", name)
+		}
+	}
+
+	var stub *ManPage
+	for _, name := range order {
+		provider := providers[name]
+		if section > 0 && name != SourceMan {
+			continue
+		}
+
+		page, err := provider.Get(command, section)
+		if err != nil {
+			continue
+		}
+		page.Source = name
+		if name == SourceMan && isStub(page) {
+			stub = page
+			continue
+		}
+		return page, nil
+	}
+
+	if stub != nil {
+		return stub, nil
+	}
+	return nil, fmt.Errorf("no documentation found for %s", command)
+}
+
+// isStub reports whether a man page only refers the reader to info
+func isStub(page *ManPage) bool {
+	content := strings.TrimSpace(page.Content)
+	return len(content) < maxStubChars && stubInfoRe.MatchString(content)
+}
+
+// manProvider reads man pages
+type manProvider struct{}
+
+func (manProvider) Name() string { return SourceMan }
+
+func (manProvider) Get(command string, section int) (*ManPage, error) {
+	return Get(command, section)
+}
+
+// infoProvider reads the node of a GNU info manual documenting a command
+type infoProvider struct{}
+
+func (infoProvider) Name() string { return SourceInfo }
+
+func (infoProvider) Get(command string, section int) (*ManPage, error) {
+	if !subcommandRe.MatchString(command) {
+		return nil, fmt.Errorf("invalid command name: %s", command)
+	}
+	output, err := exec.Command("info", "--output=-", command).Output()
+	if err != nil {
+		return nil, fmt.Errorf("no info manual for %s", command)
+	}
+	content, ok := infoContent(string(output))
+	if !ok {
+		return nil, fmt.Errorf("no info manual for %s", command)
+	}
+	return Parse(command, 0, content), nil
+}
+
+// infoContent strips the node header from info output. info falls back to
+// the directory node or to the man page when no manual matches; those don't
+// count as info documentation.
+func infoContent(output string) (string, bool) {
+	header, body, _ := strings.Cut(output, "\n")
+	if !strings.HasPrefix(header, "File: ") ||
+		strings.Contains(header, "File: dir,") ||
+		strings.Contains(header, "*manpages*") {
+		return "", false
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", false
+	}
+	return body, true
+}
+
+// builtinProvider reads the help of shell builtins and keywords that have
+// no executable, like cd, export and ulimit
+type builtinProvider struct{}
+
+func (builtinProvider) Name() string { return SourceBuiltin }
+
+func (builtinProvider) Get(command string, section int) (*ManPage, error) {
+	if !subcommandRe.MatchString(command) {
+		return nil, fmt.Errorf("invalid command name: %s", command)
+	}
+	// Commands with a binary, like echo or kill, are documented by man
+	if _, err := exec.LookPath(command); err == nil {
+		return nil, fmt.Errorf("%s is not only a shell builtin", command)
+	}
+
+	sources := []func(string) (string, error){bashHelp, zshHelp}
+	if filepath.Base(os.Getenv("SHELL")) == "zsh" {
+		sources = []func(string) (string, error){zshHelp, bashHelp}
+	}
+	for _, source := range sources {
+		if content, err := source(command); err == nil {
+			return Parse(command, 0, content), nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a shell builtin", command)
+}
+
+// bashHelp returns bash's "help -m" output for a builtin or keyword, which
+// is laid out like a man page
+func bashHelp(command string) (string, error) {
+	kind, err := exec.Command("bash", "-c", `type -t -- "$1"`, "bash", command).Output()
+	if err != nil {
+		return "", err
+	}
+	if k := strings.TrimSpace(string(kind)); k != "builtin" && k != "keyword" {
+		return "", fmt.Errorf("%s is not a bash builtin", command)
+	}
+	output, err := exec.Command("bash", "-c", `help -m -- "$1"`, "bash", command).Output()
+	if err != nil || strings.TrimSpace(string(output)) == "" {
+		return "", fmt.Errorf("no bash help for %s", command)
+	}
+	return string(output), nil
+}
+
+// zshHelpDirs are where zsh installs the builtin help files run-help reads
+var zshHelpDirs = []string{
+	"/usr/share/zsh/help",
+	"/usr/share/zsh/*/help",
+	"/usr/local/share/zsh/help",
+	"/opt/homebrew/share/zsh/help",
+}
+
+// zshHelp returns the help file zsh's run-help shows for a builtin
+func zshHelp(command string) (string, error) {
+	patterns := zshHelpDirs
+	if dir := os.Getenv("HELPDIR"); dir != "" {
+		patterns = append([]string{dir}, patterns...)
+	}
+	for _, pattern := range patterns {
+		dirs, _ := filepath.Glob(pattern)
+		for _, dir := range dirs {
+			data, err := os.ReadFile(filepath.Join(dir, command))
+			if err == nil && strings.TrimSpace(string(data)) != "" {
+				return string(data), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no zsh help for %s", command)
+}
+
+// helpProvider reads a command's --help output
+type helpProvider struct{}
+
+func (helpProvider) Name() string { return SourceHelp }
+
+func (helpProvider) Get(command string, section int) (*ManPage, error) {
+	help, err := GetHelpOutput(command)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(command, 0, help), nil
+}
@@ -0,0 +1,127 @@
+package man
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestInfoContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+		ok     bool
+	}{
+		{
+			name:   "manual node",
+			output: "File: sed.info,  Node: Invoking sed,  Next: sed scripts,  Up: Top\n\n2 Running sed\n*************\n",
+			want:   "2 Running sed\n*************",
+			ok:     true,
+		},
+		{
+			name:   "directory node",
+			output: "File: dir,\tNode: Top\tThis is the top of the INFO tree\n\n* Menu:\n",
+			ok:     false,
+		},
+		{
+			name:   "man page fallback",
+			output: "File: *manpages*,  Node: ls,  Up: (dir)\n\nLS(1)  User Commands\n",
+			ok:     false,
+		},
+		{
+			name:   "no header",
+			output: "info: No menu item 'foo' in node '(dir)Top'\n",
+			ok:     false,
+		},
+		{
+			name:   "empty node",
+			output: "File: foo.info,  Node: Top\n\n",
+			ok:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := infoContent(tt.output)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("infoContent() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsStub(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{
+			name:    "points to info",
+			content: "NAME\n       tar - an archiving utility\n\nSEE ALSO\n       The full documentation for tar is maintained as a Texinfo manual.\n",
+			want:    true,
+		},
+		{
+			name:    "info command",
+			content: "NAME\n       sed - stream editor\n\n       info sed\n\n       should give you access to the complete manual.\n",
+			want:    true,
+		},
+		{
+			name:    "complete page",
+			content: "NAME\n       grep - print lines that match patterns\n\nDESCRIPTION\n       grep searches for PATTERNS in each FILE.\n",
+			want:    false,
+		},
+		{
+			name:    "long page mentioning info",
+			content: "NAME\n       ls - list directory contents\n" + strings.Repeat("       -a, --all  do not ignore entries starting with .\n", 60) + "       info coreutils 'ls invocation'\n",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStub(&ManPage{Content: tt.content}); got != tt.want {
+				t.Errorf("isStub() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupUnknownProvider(t *testing.T) {
+	if _, err := Lookup("ls", 0, []string{"man", "wiki"}); err == nil || !strings.Contains(err.Error(), `"wiki"`) {
+		t.Errorf("Lookup() error = %v, want unknown provider error", err)
+	}
+}
+
+func TestLookupSection(t *testing.T) {
+	t.Setenv("MANPATH", "testdata/man")
+	// With a section only man pages apply, so help must not be tried
+	if _, err := Lookup("ohman-no-such-command", 1, []string{"help"}); err == nil {
+		t.Error("Lookup() with a section and no man provider should fail")
+	}
+}
+
+func TestBuiltinProvider(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	if _, err := exec.LookPath("cd"); err == nil {
+		t.Skip("cd has an executable on this system")
+	}
+
+	page, err := Lookup("cd", 0, []string{SourceBuiltin})
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if page.Source != SourceBuiltin || page.SourceName() != "shell builtin help" {
+		t.Errorf("Source = %q (%s), want builtin", page.Source, page.SourceName())
+	}
+	if !strings.Contains(page.Content, "cd") || !strings.Contains(page.Content, "directory") {
+		t.Errorf("Content = %q, want the cd help", page.Content)
+	}
+
+	if _, err := (builtinProvider{}).Get("bash", 0); err == nil {
+		t.Error("builtinProvider.Get(bash) should fail for a command with a binary")
+	}
+}
@@ -0,0 +1,186 @@
+package man
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// sectionOrder is the order man searches sections in when none is given
+var sectionOrder = []string{"1", "n", "l", "8", "3", "2", "5", "4", "9", "6", "7"}
+
+// maxIncludes bounds how many ".so" includes are followed for one page
+const maxIncludes = 5
+
+// findPages returns the source files of a command's pages in the man paths,
+// in man's search order. Section 0 means every section.
+func findPages(command string, section int) []string {
+	sections := sectionOrder
+	if section > 0 {
+		sections = []string{strconv.Itoa(section)}
+	}
+
+	var paths []string
+	roots := ManPaths()
+	for _, sec := range sections {
+		for _, root := range roots {
+			dir := filepath.Join(root, "man"+sec)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				name, pageSection, ok := pageFromPath(entry.Name())
+				if ok && name == command && strings.HasPrefix(pageSection, sec) {
+					paths = append(paths, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// findPage returns the source file man would show for a command, without
+// needing man itself
+func findPage(command string, section int) (string, error) {
+	paths := findPages(command, section)
+	if len(paths) == 0 {
+		return "", fmt.Errorf("man page for %s not found", command)
+	}
+	return paths[0], nil
+}
+
+// RenderFile renders a man page source file as plain text without man,
+// groff or col, decompressing it and following ".so" includes
+func RenderFile(path string) (string, error) {
+	source, err := readSource(path)
+	if err != nil {
+		return "", err
+	}
+	content := RenderRoff(source)
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("man page %s is empty", path)
+	}
+	return content, nil
+}
+
+// readSource reads a page's roff source. Pages that only include another,
+// like gunzip.1 with ".so man1/gzip.1", are replaced by the included page.
+func readSource(path string) (string, error) {
+	for i := 0; ; i++ {
+		data, err := decompress(path)
+		if err != nil {
+			return "", err
+		}
+		source := string(data)
+
+		target, ok := soTarget(source)
+		if !ok || i >= maxIncludes {
+			return source, nil
+		}
+		// Includes are relative to the man path root, above the man1 dir
+		included, err := findInclude(filepath.Dir(filepath.Dir(path)), target)
+		if err != nil {
+			return "", err
+		}
+		path = included
+	}
+}
+
+// soTarget returns the file a page includes when it consists of a ".so"
+// request, ignoring comments
+func soTarget(source string) (string, bool) {
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, `.\"`) || strings.HasPrefix(line, `'\"`) {
+			continue
+		}
+		if target, ok := strings.CutPrefix(line, ".so "); ok {
+			return strings.TrimSpace(target), true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// findInclude locates an included file, which may be installed compressed
+func findInclude(root, target string) (string, error) {
+	base := filepath.Join(root, target)
+	if filepath.IsAbs(target) {
+		base = target
+	}
+	for _, ext := range append([]string{""}, compressionExts...) {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext, nil
+		}
+	}
+	return "", fmt.Errorf("included man page %s not found", target)
+}
+
+// decompress reads a page file, decompressing it by its extension. Every
+// format is decoded in Go except the legacy .Z, which needs gzip installed
+func decompress(path string) ([]byte, error) {
+	ext := filepath.Ext(path)
+	if ext == ".Z" {
+		if _, err := exec.LookPath("gzip"); err != nil {
+			return nil, fmt.Errorf("cannot read %s: .Z pages need gzip, which is not installed", path)
+		}
+		output, err := exec.Command("gzip", "-dc", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		return output, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	switch ext {
+	case ".gz":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	case ".bz2":
+		reader = bzip2.NewReader(bytes.NewReader(data))
+	case ".xz":
+		if reader, err = xz.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+	case ".lzma":
+		if reader, err = lzma.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+	case ".zst":
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer zr.Close()
+		reader = zr
+	default:
+		return data, nil
+	}
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,121 @@
+package man
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+func TestFindPages(t *testing.T) {
+	t.Setenv("MANPATH", "testdata/man")
+
+	tests := []struct {
+		command string
+		section int
+		want    []string
+	}{
+		{"grep", 0, []string{"testdata/man/man1/grep.1"}},
+		{"printf", 0, []string{"testdata/man/man1/printf.1", "testdata/man/man3/printf.3"}},
+		{"printf", 3, []string{"testdata/man/man3/printf.3"}},
+		{"tar", 1, []string{"testdata/man/man1/tar.1.bz2"}},
+		{"grep", 3, nil},
+		{"nonexistentcmd123456", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got := findPages(tt.command, tt.section)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("findPages(%q, %d) = %v, want %v", tt.command, tt.section, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// Compressed pages are written here rather than committed
+	source, err := os.ReadFile("testdata/man/man1/grep.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writers := map[string]func(io.Writer) (io.WriteCloser, error){
+		".gz":   func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		".xz":   func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) },
+		".lzma": func(w io.Writer) (io.WriteCloser, error) { return lzma.NewWriter(w) },
+		".zst":  func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+	}
+	compressed := make(map[string]string)
+	for ext, newWriter := range writers {
+		path := filepath.Join(dir, "grep.1"+ext)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := newWriter(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(source); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		file.Close()
+		compressed[ext] = path
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"plain", "testdata/man/man1/grep.1", "grep, egrep - print lines that match patterns"},
+		{"gzip", compressed[".gz"], "grep, egrep - print lines that match patterns"},
+		{"bzip2", "testdata/man/man1/tar.1.bz2", "tar - an archiving utility"},
+		{"xz", compressed[".xz"], "grep, egrep - print lines that match patterns"},
+		{"lzma", compressed[".lzma"], "grep, egrep - print lines that match patterns"},
+		{"zstd", compressed[".zst"], "grep, egrep - print lines that match patterns"},
+		{"so include", "testdata/man/man1/egrep.1", "grep, egrep - print lines that match patterns"},
+		{"mdoc", "testdata/man/man1/cal.1", "cal — displays a calendar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := RenderFile(tt.path)
+			if err != nil {
+				t.Fatalf("RenderFile() error = %v", err)
+			}
+			if name := Parse("test", 1, content).Name; name != tt.want {
+				t.Errorf("NAME = %q, want %q", name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoTarget(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		ok     bool
+	}{
+		{".so man1/grep.1\n", "man1/grep.1", true},
+		{".\\\" comment\n.so man3/printf.3\n", "man3/printf.3", true},
+		{".TH GREP 1\n.so man1/other.1\n", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := soTarget(tt.source)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("soTarget(%q) = %q, %v, want %q, %v", tt.source, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package man
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// maxChunkChars bounds the size of a retrieval chunk
+const maxChunkChars = 1500
+
+// Chunk is a piece of a man page section scored against a query
+type Chunk struct {
+	Section string
+	Text    string
+	// Index is the chunk's position in the page, to restore page order
+	Index int
+	Score float64
+}
+
+// Chunks splits a page into chunks of whole paragraphs within each section,
+// so an option and its description stay together
+func Chunks(page *ManPage) []Chunk {
+	var chunks []Chunk
+	for _, s := range page.Sections {
+		var current []string
+		size := 0
+		flush := func() {
+			if len(current) == 0 {
+				return
+			}
+			chunks = append(chunks, Chunk{
+				Section: s.Name,
+				Text:    strings.Join(current, "\n\n"),
+				Index:   len(chunks),
+			})
+			current, size = nil, 0
+		}
+
+		for _, para := range splitParagraphs(s.Content) {
+			if size > 0 && size+len(para) > maxChunkChars {
+				flush()
+			}
+			current = append(current, para)
+			size += len(para)
+		}
+		flush()
+	}
+	return chunks
+}
+
+// splitParagraphs splits text at blank lines, cutting paragraphs longer
+// than maxChunkChars at line boundaries
+func splitParagraphs(text string) []string {
+	var paras []string
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.Trim(para, "\n")
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		for len(para) > maxChunkChars {
+			cut := strings.LastIndex(para[:maxChunkChars], "\n")
+			if cut <= 0 {
+				cut = maxChunkChars
+			}
+			paras = append(paras, para[:cut])
+			para = strings.TrimLeft(para[cut:], "\n")
+		}
+		paras = append(paras, para)
+	}
+	return paras
+}
+
+// Retrieve scores the page's chunks against query with BM25 and returns the
+// best ones fitting in maxChars, in page order. The SYNOPSIS is always
+// included first since it anchors every answer.
+func Retrieve(page *ManPage, query string, maxChars int) []Chunk {
+	chunks := Chunks(page)
+	scoreChunks(chunks, query)
+
+	var selected []Chunk
+	used := 0
+	for _, c := range chunks {
+		if c.Section == "NAME" || c.Section == "SYNOPSIS" {
+			selected = append(selected, c)
+			used += len(c.Text)
+		}
+	}
+
+	ranked := make([]Chunk, 0, len(chunks))
+	for _, c := range chunks {
+		if c.Score > 0 && c.Section != "NAME" && c.Section != "SYNOPSIS" {
+			ranked = append(ranked, c)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+
+	for _, c := range ranked {
+		if used+len(c.Text) > maxChars {
+			continue
+		}
+		selected = append(selected, c)
+		used += len(c.Text)
+	}
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Index < selected[j].Index })
+	return selected
+}
+
+// FormatChunks renders retrieved chunks under their section headings,
+// marking the gaps between non-adjacent chunks
+func FormatChunks(chunks []Chunk) string {
+	var sb strings.Builder
+	prevSection := ""
+	prevIndex := -1
+	for _, c := range chunks {
+		if c.Section != prevSection {
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(c.Section + "\n")
+			prevSection = c.Section
+		} else if c.Index != prevIndex+1 {
+			sb.WriteString("       [...]\n\n")
+		}
+		sb.WriteString(c.Text + "\n\n")
+		prevIndex = c.Index
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// scoreChunks sets the BM25 score of every chunk against query
+func scoreChunks(chunks []Chunk, query string) {
+	terms := tokenize(query)
+	if len(chunks) == 0 || len(terms) == 0 {
+		return
+	}
+
+	docs := make([]map[string]int, len(chunks))
+	lengths := make([]int, len(chunks))
+	df := make(map[string]int)
+	total := 0
+	for i, c := range chunks {
+		docs[i] = make(map[string]int)
+		tokens := tokenize(c.Text)
+		for _, tok := range tokens {
+			docs[i][tok]++
+		}
+		for tok := range docs[i] {
+			df[tok]++
+		}
+		lengths[i] = len(tokens)
+		total += len(tokens)
+	}
+	avgLen := float64(total) / float64(len(chunks))
+	if avgLen == 0 {
+		return
+	}
+
+	n := float64(len(chunks))
+	for i := range chunks {
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(docs[i][term])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[term])+0.5)/(float64(df[term])+0.5))
+			norm := tf + bm25K1*(1-bm25B+bm25B*float64(lengths[i])/avgLen)
+			score += idf * tf * (bm25K1 + 1) / norm
+		}
+		chunks[i].Score = score
+	}
+}
+
+// stopWords are common question words that carry no retrieval signal
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "does": true, "for": true,
+	"from": true, "how": true, "i": true, "if": true, "in": true, "is": true,
+	"it": true, "me": true, "my": true, "of": true, "on": true, "or": true,
+	"should": true, "that": true, "the": true, "this": true, "to": true,
+	"use": true, "what": true, "when": true, "which": true, "with": true,
+	"without": true, "you": true,
+}
+
+// tokenize lower-cases text into stemmed terms, keeping the words of
+// options like --no-clobber as separate terms
+func tokenize(text string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if stopWords[word] {
+			continue
+		}
+		tokens = append(tokens, stem(word))
+	}
+	return tokens
+}
+
+// stem strips common English suffixes so "recursively" matches "recursive"
+// and "files" matches "file"
+func stem(word string) string {
+	for _, suffix := range []string{"ches", "shes", "sses", "xes"} {
+		if strings.HasSuffix(word, suffix) {
+			return word[:len(word)-2]
+		}
+	}
+	for _, suffix := range []string{"ively", "ive", "ing", "ly", "ed", "s"} {
+		if len(word) > len(suffix)+3 && strings.HasSuffix(word, suffix) && !strings.HasSuffix(word, "ss") {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+	return word
+}
@@ -0,0 +1,79 @@
+package man
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// bigPage builds a page with many filler options and a few distinctive ones
+func bigPage() *ManPage {
+	var sb strings.Builder
+	sb.WriteString("GREP(1)\n\nNAME\n       grep - print lines that match patterns\n\n")
+	sb.WriteString("SYNOPSIS\n       grep [OPTION...] PATTERNS [FILE...]\n\nOPTIONS\n")
+	for i := 0; i < 200; i++ {
+		sb.WriteString(fmt.Sprintf("       --filler-%d\n              Filler option number %d that controls output formatting.\n\n", i, i))
+	}
+	sb.WriteString("       -r, --recursive\n              Read all files under each directory, recursively.\n\n")
+	sb.WriteString("       -i, --ignore-case\n              Ignore case distinctions in patterns and input data.\n\n")
+	sb.WriteString("EXIT STATUS\n       Normally the exit status is 0 if a line is selected.\n")
+	return Parse("grep", 1, sb.String())
+}
+
+func TestChunks(t *testing.T) {
+	chunks := Chunks(bigPage())
+
+	for i, c := range chunks {
+		if c.Index != i {
+			t.Errorf("chunk %d has index %d", i, c.Index)
+		}
+		if len(c.Text) > maxChunkChars+200 {
+			t.Errorf("chunk %d is %d characters", i, len(c.Text))
+		}
+	}
+	if chunks[0].Section != "NAME" || chunks[len(chunks)-1].Section != "EXIT STATUS" {
+		t.Errorf("chunks should follow page order, got %s ... %s", chunks[0].Section, chunks[len(chunks)-1].Section)
+	}
+}
+
+func TestRetrieve(t *testing.T) {
+	page := bigPage()
+
+	chunks := Retrieve(page, "How do I search directories recursively?", 2000)
+	if len(chunks) < 3 {
+		t.Fatalf("expected NAME, SYNOPSIS and a relevant chunk, got %d", len(chunks))
+	}
+	if chunks[0].Section != "NAME" || chunks[1].Section != "SYNOPSIS" {
+		t.Errorf("NAME and SYNOPSIS should come first, got %s, %s", chunks[0].Section, chunks[1].Section)
+	}
+
+	content := FormatChunks(chunks)
+	if !strings.Contains(content, "--recursive") {
+		t.Errorf("retrieved content should contain --recursive:\n%s", content)
+	}
+	if len(content) > 2500 {
+		t.Errorf("retrieved content is %d characters, over budget", len(content))
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].Index <= chunks[i-1].Index {
+			t.Errorf("chunks should be in page order")
+		}
+	}
+
+	// A query matching nothing only keeps the synopsis
+	if chunks := Retrieve(page, "zzz", 2000); len(chunks) != 2 {
+		t.Errorf("expected only NAME and SYNOPSIS, got %d chunks", len(chunks))
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(tokenize("How to match files recursively with --no-clobber?"), ",")
+	want := "match,file,recurs,no,clobber"
+	if got != want {
+		t.Errorf("tokenize() = %s, want %s", got, want)
+	}
+
+	if stem("recursive") != stem("recursively") || stem("matches") != stem("match") || stem("class") != "class" {
+		t.Error("stem() should reduce word variants to the same term")
+	}
+}
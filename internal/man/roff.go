@@ -0,0 +1,1353 @@
+package man
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// roffWidth is the width pages are filled to, like man on an 80-column
+	// terminal
+	roffWidth = 78
+	// roffIndent is the indentation of section bodies and the default
+	// indentation of paragraphs, tags and lists
+	roffIndent = 7
+	// roffMaxDepth bounds the nesting of macro and string expansion
+	roffMaxDepth = 16
+	// roffMaxExpansions bounds the macro lines and strings expanded in one
+	// render, so a macro calling itself several times can't blow up
+	// exponentially within the depth limit
+	roffMaxExpansions = 100000
+)
+
+// unbreakable stands in for unbreakable spaces like "\ " while filling
+const unbreakable = '\ue000'
+
+// roffChars maps roff special characters, as in \(em or \[em], to text
+var roffChars = map[string]string{
+	"em": "—", "en": "–", "hy": "-", "mi": "-", "bu": "•", "ci": "○",
+	"aq": "'", "dq": "\"", "lq": "“", "rq": "”", "oq": "‘", "cq": "’",
+	"Fo": "«", "Fc": "»", "fo": "‹", "fc": "›",
+	"co": "©", "rg": "®", "tm": "™", "de": "°", "ct": "¢", "ps": "¶", "sc": "§",
+	"mu": "×", "di": "÷", "+-": "±", "<=": "≤", ">=": "≥", "!=": "≠", "==": "≡",
+	"->": "→", "<-": "←", "<>": "↔", "ua": "↑", "da": "↓", "rA": "⇒", "lA": "⇐",
+	"ti": "~", "ha": "^", "rs": "\\", "ga": "`", "aa": "´", "ul": "_", "sl": "/",
+	"ba": "|", "br": "│", "or": "|", "lB": "[", "rB": "]", "lC": "{", "rC": "}",
+	"la": "⟨", "ra": "⟩", "Do": "$", "at": "@", "sh": "#", "pl": "+", "eq": "=",
+	"ss": "ß", "ae": "æ", "AE": "Æ", "o/": "ø", "O/": "Ø", ":a": "ä", ":o": "ö",
+	":u": "ü", ":A": "Ä", ":O": "Ö", ":U": "Ü", "'e": "é", "`e": "è", "'a": "á",
+	"*a": "α", "*b": "β", "*g": "γ", "*d": "δ", "*m": "µ", "*p": "π", "*W": "Ω",
+	"if": "∞", "pd": "∂", "sr": "√", "fm": "′", "sd": "″", "dg": "†", "dd": "‡",
+	"r!": "¡", "r?": "¿", "12": "½", "14": "¼", "34": "¾", "Eu": "€", "eu": "€",
+}
+
+// roffStrings are the predefined strings of man(7)
+var roffStrings = map[string]string{
+	"R": "®", "Tm": "™", "lq": "“", "rq": "”",
+}
+
+// mdocCallable are the mdoc(7) macros that may appear inside a macro line
+var mdocCallable = map[string]bool{
+	"Ac": true, "Ad": true, "An": true, "Ao": true, "Ap": true, "Aq": true,
+	"Ar": true, "At": true, "Bc": true, "Bo": true, "Bq": true, "Brc": true,
+	"Bro": true, "Brq": true, "Bsx": true, "Bx": true, "Cd": true, "Cm": true,
+	"Dc": true, "Do": true, "Dq": true, "Dv": true, "Dx": true, "Ec": true,
+	"Em": true, "Eo": true, "Er": true, "Ev": true, "Fa": true, "Fl": true,
+	"Fn": true, "Ft": true, "Fx": true, "Ic": true, "In": true, "Li": true,
+	"Lk": true, "Ms": true, "Mt": true, "Nm": true, "No": true, "Ns": true,
+	"Nx": true, "Oc": true, "Oo": true, "Op": true, "Ot": true, "Ox": true,
+	"Pa": true, "Pc": true, "Pf": true, "Po": true, "Pq": true, "Qc": true,
+	"Ql": true, "Qo": true, "Qq": true, "Sc": true, "So": true, "Sq": true,
+	"St": true, "Sx": true, "Sy": true, "Ta": true, "Tn": true, "Ux": true,
+	"Va": true, "Vt": true, "Xr": true,
+}
+
+// mdocEnclosures are the opening and closing text of mdoc's enclosing
+// macros, both the one-line form (Op) and the open/close pairs (Oo, Oc)
+var mdocEnclosures = map[string][2]string{
+	"Op": {"[", "]"}, "Oo": {"[", ""}, "Oc": {"", "]"},
+	"Bq": {"[", "]"}, "Bo": {"[", ""}, "Bc": {"", "]"},
+	"Brq": {"{", "}"}, "Bro": {"{", ""}, "Brc": {"", "}"},
+	"Pq": {"(", ")"}, "Po": {"(", ""}, "Pc": {"", ")"},
+	"Aq": {"<", ">"}, "Ao": {"<", ""}, "Ac": {"", ">"},
+	"Dq": {"“", "”"}, "Do": {"“", ""}, "Dc": {"", "”"},
+	"Sq": {"‘", "’"}, "So": {"‘", ""}, "Sc": {"", "’"},
+	"Qq": {"\"", "\""}, "Qo": {"\"", ""}, "Qc": {"", "\""},
+	"Ql": {"‘", "’"},
+}
+
+// mdocSystems are the names printed by mdoc's operating system macros
+var mdocSystems = map[string]string{
+	"At": "AT&T UNIX", "Bsx": "BSD/OS", "Bx": "BSD", "Dx": "DragonFly",
+	"Fx": "FreeBSD", "Nx": "NetBSD", "Ox": "OpenBSD", "Ux": "UNIX",
+}
+
+// mdocStandards names the most common standards cited with .St
+var mdocStandards = map[string]string{
+	"-ansiC":        "ANSI X3.159-1989 (“ANSI C89”)",
+	"-isoC":         "ISO/IEC 9899:1990 (“ISO C90”)",
+	"-isoC-99":      "ISO/IEC 9899:1999 (“ISO C99”)",
+	"-isoC-2011":    "ISO/IEC 9899:2011 (“ISO C11”)",
+	"-p1003.1":      "IEEE Std 1003.1 (“POSIX.1”)",
+	"-p1003.1-2001": "IEEE Std 1003.1-2001 (“POSIX.1”)",
+	"-p1003.1-2004": "IEEE Std 1003.1-2004 (“POSIX.1”)",
+	"-p1003.1-2008": "IEEE Std 1003.1-2008 (“POSIX.1”)",
+	"-p1003.2":      "IEEE Std 1003.2 (“POSIX.2”)",
+	"-susv2":        "Version 2 of the Single UNIX Specification (“SUSv2”)",
+	"-susv3":        "Version 3 of the Single UNIX Specification (“SUSv3”)",
+	"-susv4":        "Version 4 of the Single UNIX Specification (“SUSv4”)",
+	"-xpg4":         "X/Open Portability Guide Issue 4 (“XPG4”)",
+}
+
+// mdocList is an open .Bl list
+type mdocList struct {
+	kind      string
+	tagIndent int
+	indent    int
+	outer     int
+	compact   bool
+	count     int
+}
+
+// mdocDisplay is an open .Bd display
+type mdocDisplay struct {
+	outer  int
+	nofill bool
+}
+
+// roffRenderer renders roff source line by line
+type roffRenderer struct {
+	out strings.Builder
+
+	// Filling
+	words       []string
+	nospace     bool
+	nofill      bool
+	compact     bool
+	lastBlank   bool
+	afterHeader bool
+
+	// Layout
+	indent    int
+	base      int
+	margins   []int
+	tag       string
+	tagSet    bool
+	tagNext   bool
+	tagIndent int
+	heading   int // the next text line is a heading of this level + 1
+	url       string
+
+	// Definitions
+	strs     map[string]string
+	macros   map[string][]string
+	defining string
+	defEnd   string
+	defBody  []string
+	skip     int // depth of a conditional block being skipped
+	lastCond bool
+	depth    int
+	expanded int // macro lines and strings expanded so far
+	table    int // 1 while reading a table's format, 2 for its data
+
+	// mdoc
+	name     string
+	section  string
+	date     string
+	lists    []mdocList
+	displays []mdocDisplay
+	fnArgs   int
+	spacing  bool // false between .Sm off and .Sm on
+
+	// The footer, as in "GNU coreutils 9.4    April 2024    LS(1)"
+	title  string
+	source string
+}
+
+// RenderRoff renders man(7) or mdoc(7) source as plain text laid out like
+// man's own output: unindented section headings, bodies indented by seven
+// columns and option tags hanging over their descriptions
+func RenderRoff(source string) string {
+	r := &roffRenderer{
+		indent:  roffIndent,
+		base:    roffIndent,
+		strs:    make(map[string]string),
+		macros:  make(map[string][]string),
+		spacing: true,
+	}
+	for k, v := range roffStrings {
+		r.strs[k] = v
+	}
+
+	for _, line := range roffLines(source) {
+		r.line(line)
+	}
+	r.breakLine()
+	if r.title != "" && (r.source != "" || r.date != "") {
+		r.blank()
+		r.writeLine(footerLine(r.source, r.date, r.title))
+	}
+
+	return strings.TrimRight(r.out.String(), "\n") + "\n"
+}
+
+// roffLines splits source into lines, joining lines continued with a
+// trailing backslash
+func roffLines(source string) []string {
+	var lines []string
+	var cont strings.Builder
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		if trailingBackslashes(line)%2 == 1 {
+			cont.WriteString(line[:len(line)-1])
+			continue
+		}
+		cont.WriteString(line)
+		lines = append(lines, cont.String())
+		cont.Reset()
+	}
+	if cont.Len() > 0 {
+		lines = append(lines, cont.String())
+	}
+	return lines
+}
+
+// trailingBackslashes counts the backslashes ending line
+func trailingBackslashes(line string) int {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n
+}
+
+// stripComment removes a \" or \# comment from line
+func stripComment(line string) string {
+	for i := 0; i < len(line)-1; i++ {
+		if line[i] != '\\' {
+			continue
+		}
+		if line[i+1] == '"' || line[i+1] == '#' {
+			return strings.TrimRight(line[:i], " \t")
+		}
+		i++
+	}
+	return line
+}
+
+// line processes one input line
+func (r *roffRenderer) line(line string) {
+	if r.defining != "" {
+		r.define(line)
+		return
+	}
+
+	line = stripComment(line)
+	if r.skip > 0 {
+		r.skip += strings.Count(line, `\{`) - strings.Count(line, `\}`)
+		if r.skip < 0 {
+			r.skip = 0
+		}
+		return
+	}
+
+	isRequest := line != "" && (line[0] == '.' || line[0] == '\'')
+	if r.table > 0 && !isRequest {
+		r.tableLine(line)
+		return
+	}
+
+	switch {
+	case isRequest:
+		r.request(line[1:])
+	case strings.TrimSpace(line) == "":
+		r.breakLine()
+		r.blank()
+	default:
+		joined := strings.HasSuffix(line, `\c`) && trailingBackslashes(line[:len(line)-1])%2 == 1
+		if r.nofill && r.heading == 0 && !r.tagNext {
+			r.breakLine()
+			r.writeLine(strings.Repeat(" ", r.indent) + r.escape(line))
+		} else {
+			r.text(r.escape(line))
+		}
+		if joined {
+			r.nospace = true
+		}
+	}
+}
+
+// define collects the body of a macro being defined with .de
+func (r *roffRenderer) define(line string) {
+	if strings.TrimSpace(line) == "."+r.defEnd {
+		if r.defining != ".ig" {
+			r.macros[r.defining] = append(r.macros[r.defining], r.defBody...)
+		}
+		r.defining, r.defBody = "", nil
+		return
+	}
+	r.defBody = append(r.defBody, line)
+}
+
+// tableLine renders a line of a tbl(1) table, one row per line with the
+// cells separated by two spaces
+func (r *roffRenderer) tableLine(line string) {
+	trimmed := strings.TrimSpace(line)
+	if r.table == 1 {
+		// The format ends with a line ending in a period
+		if strings.HasSuffix(trimmed, ".") {
+			r.table = 2
+		}
+		return
+	}
+	if trimmed == "_" || trimmed == "=" || trimmed == "T}" {
+		return
+	}
+
+	var cells []string
+	for _, cell := range strings.Split(line, "\t") {
+		cell = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(cell), "T{"), "T}")
+		if cell = strings.TrimSpace(r.escape(cell)); cell != "" {
+			cells = append(cells, cell)
+		}
+	}
+	if len(cells) > 0 {
+		r.breakLine()
+		r.writeLine(strings.Repeat(" ", r.indent) + strings.Join(cells, "  "))
+	}
+}
+
+// request processes a request or macro line, without its control character
+func (r *roffRenderer) request(line string) {
+	line = strings.TrimLeft(line, " \t")
+	name, rest, _ := strings.Cut(line, " ")
+	if tab := strings.IndexByte(name, '\t'); tab != -1 {
+		name, rest = name[:tab], name[tab+1:]+" "+rest
+	}
+	if name == "" {
+		return
+	}
+	args := roffArgs(rest)
+
+	// Macros the page defines itself take precedence
+	if body, ok := r.macros[name]; ok {
+		r.expand(body, args)
+		return
+	}
+
+	switch name {
+	case "de", "de1", "am", "am1", "ig":
+		r.defining, r.defEnd = ".ig", "."
+		if name != "ig" && len(args) > 0 {
+			r.defining = args[0]
+			if name == "de" || name == "de1" {
+				delete(r.macros, args[0])
+			}
+		}
+		if len(args) > 1 && name != "ig" {
+			r.defEnd = args[1]
+		} else if len(args) > 0 && name == "ig" {
+			r.defEnd = args[0]
+		}
+	case "ds", "ds1", "as", "as1":
+		key, value, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+		value = strings.ReplaceAll(strings.TrimPrefix(strings.TrimLeft(value, " "), `"`), `\\`, `\`)
+		if name == "as" || name == "as1" {
+			value = r.strs[key] + value
+		}
+		r.strs[key] = value
+	case "if":
+		ok, body := r.condition(rest)
+		r.branch(ok, body)
+	case "ie":
+		ok, body := r.condition(rest)
+		r.lastCond = ok
+		r.branch(ok, body)
+	case "el":
+		r.branch(!r.lastCond, strings.TrimLeft(rest, " \t"))
+	case "br":
+		r.breakLine()
+	case "sp":
+		r.breakLine()
+		r.blank()
+	case "nf":
+		r.breakLine()
+		r.nofill = true
+	case "fi":
+		r.breakLine()
+		r.nofill = false
+	case "TS":
+		r.breakLine()
+		r.table = 1
+	case "TE":
+		r.table = 0
+	case "T&":
+		r.table = 1
+	default:
+		if !r.manMacro(name, args) {
+			r.mdocMacro(name, args)
+		}
+	}
+}
+
+// expand runs the body of a page-defined macro with its arguments
+func (r *roffRenderer) expand(body, args []string) {
+	if r.depth >= roffMaxDepth {
+		return
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+
+	for _, line := range body {
+		if r.expanded >= roffMaxExpansions {
+			return
+		}
+		r.expanded++
+		// Bodies are read in copy mode, where \\ stands for a backslash
+		line = strings.ReplaceAll(line, `\\`, `\`)
+		line = strings.ReplaceAll(line, `\$*`, strings.Join(args, " "))
+		line = strings.ReplaceAll(line, `\$@`, strings.Join(args, " "))
+		for i := 9; i >= 1; i-- {
+			arg := ""
+			if i <= len(args) {
+				arg = args[i-1]
+			}
+			line = strings.ReplaceAll(line, `\$`+strconv.Itoa(i), arg)
+		}
+		r.line(line)
+	}
+}
+
+// condition evaluates the condition of an .if or .ie request, returning
+// whether it holds and the rest of the line. Pages are rendered as nroff
+// would, for a terminal.
+func (r *roffRenderer) condition(s string) (bool, string) {
+	s = strings.TrimLeft(s, " \t")
+	negate := strings.HasPrefix(s, "!")
+	if negate {
+		s = s[1:]
+	}
+	if s == "" {
+		return false, ""
+	}
+
+	var ok bool
+	switch c := s[0]; {
+	case c == 'n' || c == 'o':
+		ok, s = true, s[1:]
+	case c == 't' || c == 'e' || c == 'v':
+		ok, s = false, s[1:]
+	case (c == 'd' || c == 'r' || c == 'c' || c == 'm' || c == 'F' || c == 'S') && len(s) > 1 && s[1] == ' ':
+		name, rest, _ := strings.Cut(strings.TrimLeft(s[1:], " "), " ")
+		_, isString := r.strs[name]
+		_, isMacro := r.macros[name]
+		ok, s = c == 'd' && (isString || isMacro), rest
+	case c == '\'' || c == '"':
+		// String comparison: 'a'b'
+		parts := strings.SplitN(s[1:], string(c), 3)
+		if len(parts) == 3 {
+			ok, s = r.escape(parts[0]) == r.escape(parts[1]), parts[2]
+		} else {
+			s = ""
+		}
+	default:
+		// Numeric expressions mostly test registers, which aren't tracked;
+		// only literal positive numbers hold
+		expr, rest, _ := strings.Cut(s, " ")
+		n, err := strconv.Atoi(strings.TrimLeft(r.escape(expr), "+"))
+		ok, s = err == nil && n > 0, rest
+	}
+
+	if negate {
+		ok = !ok
+	}
+	return ok, strings.TrimLeft(s, " \t")
+}
+
+// branch runs the body of a conditional when ok, or skips it, including
+// any \{ ... \} block it opens
+func (r *roffRenderer) branch(ok bool, body string) {
+	if !ok {
+		r.skip = strings.Count(body, `\{`) - strings.Count(body, `\}`)
+		if r.skip < 0 {
+			r.skip = 0
+		}
+		return
+	}
+	body = strings.TrimLeft(strings.TrimPrefix(body, `\{`), " \t")
+	if body != "" {
+		r.line(body)
+	}
+}
+
+// manMacro processes a man(7) macro, reporting whether name is one
+func (r *roffRenderer) manMacro(name string, args []string) bool {
+	switch name {
+	case "TH":
+		if len(args) >= 2 {
+			r.title = fmt.Sprintf("%s(%s)", r.escape(args[0]), r.escape(args[1]))
+			r.writeLine(r.title)
+			if len(args) >= 3 {
+				r.date = r.escape(args[2])
+			}
+			if len(args) >= 4 {
+				r.source = r.escape(args[3])
+			}
+		}
+	case "SH", "SS":
+		level := 0
+		if name == "SS" {
+			level = 1
+		}
+		if len(args) == 0 {
+			r.breakLine()
+			r.heading = level + 1
+			return true
+		}
+		r.header(r.escapeArgs(args, " "), level)
+	case "PP", "LP", "P", "HP":
+		r.paragraph()
+	case "TP":
+		r.paragraph()
+		r.tagIndent = r.base
+		r.indent = r.base + roffArgWidth(args, roffIndent)
+		r.tagNext = true
+	case "TQ":
+		r.breakLine()
+		r.tagNext = true
+	case "IP":
+		r.paragraph()
+		r.tagIndent = r.base
+		r.indent = r.base + roffArgWidth(args[min(1, len(args)):], roffIndent)
+		if len(args) > 0 && args[0] != "" {
+			r.tag, r.tagSet = r.escape(args[0]), true
+		}
+	case "RS":
+		r.breakLine()
+		r.margins = append(r.margins, r.base)
+		r.base += roffArgWidth(args, roffIndent)
+		r.indent = r.base
+	case "RE":
+		r.breakLine()
+		if n := len(r.margins); n > 0 {
+			r.base, r.margins = r.margins[n-1], r.margins[:n-1]
+		}
+		r.indent = r.base
+	case "B", "I", "SM", "SB":
+		if len(args) > 0 {
+			r.text(r.escapeArgs(args, " "))
+		}
+	case "BR", "BI", "IB", "RB", "IR", "RI":
+		r.text(r.escapeArgs(args, ""))
+	case "EX":
+		r.breakLine()
+		r.nofill = true
+	case "EE":
+		r.breakLine()
+		r.nofill = false
+	case "PD":
+		r.compact = len(args) > 0 && args[0] == "0"
+	case "UR", "MT":
+		if len(args) > 0 {
+			r.url = r.escape(args[0])
+		}
+	case "UE", "ME":
+		if r.url != "" {
+			r.text("<" + r.url + ">" + r.escapeArgs(args, ""))
+			r.url = ""
+		}
+	case "SY":
+		r.breakLine()
+		r.text(r.escapeArgs(args, " "))
+	case "YS":
+		r.breakLine()
+	case "OP":
+		r.text("[" + r.escapeArgs(args, " ") + "]")
+	case "DT", "UC", "AT", "IX":
+	default:
+		return false
+	}
+	return true
+}
+
+// mdocMacro processes an mdoc(7) macro; unknown macros are ignored
+func (r *roffRenderer) mdocMacro(name string, args []string) {
+	switch name {
+	case "Dd":
+		r.date = r.escapeArgs(args, " ")
+	case "Dt":
+		if len(args) >= 2 {
+			r.title = fmt.Sprintf("%s(%s)", r.escape(args[0]), r.escape(args[1]))
+			r.writeLine(r.title)
+		}
+	case "Os":
+		r.source = r.escapeArgs(args, " ")
+	case "Sh", "Ss":
+		level := 0
+		if name == "Ss" {
+			level = 1
+		} else {
+			r.section = strings.ToUpper(strings.Join(args, " "))
+		}
+		r.lists, r.displays = nil, nil
+		r.header(r.escapeArgs(args, " "), level)
+	case "Pp", "Lp":
+		r.paragraph()
+	case "Sm":
+		r.spacing = len(args) > 0 && args[0] == "on" || len(args) == 0 && !r.spacing
+	case "Nd":
+		r.text("— " + r.mdocPhrase(args))
+	case "Nm":
+		if r.name == "" && len(args) > 0 {
+			r.name = r.escape(args[0])
+		}
+		// Each command in a synopsis starts a new line
+		if r.section == "SYNOPSIS" && len(r.lists) == 0 {
+			r.breakLine()
+		}
+		r.text(r.mdocPhrase(append([]string{name}, args...)))
+	case "D1", "Dl":
+		r.breakLine()
+		outer := r.indent
+		r.indent += roffIndent
+		if name == "Dl" {
+			r.writeLine(strings.Repeat(" ", r.indent) + r.escapeArgs(args, " "))
+		} else {
+			r.text(r.mdocPhrase(args))
+			r.breakLine()
+		}
+		r.indent = outer
+	case "Bd":
+		r.paragraph()
+		d := mdocDisplay{outer: r.indent, nofill: r.nofill}
+		for i := 0; i < len(args); i++ {
+			switch args[i] {
+			case "-literal", "-unfilled":
+				r.nofill = true
+			case "-offset":
+				if i+1 < len(args) {
+					i++
+					r.indent += mdocOffset(args[i])
+				}
+			}
+		}
+		r.displays = append(r.displays, d)
+	case "Ed":
+		r.breakLine()
+		if n := len(r.displays); n > 0 {
+			d := r.displays[n-1]
+			r.displays = r.displays[:n-1]
+			r.indent, r.nofill = d.outer, d.nofill
+		}
+	case "Bl":
+		r.breakLine()
+		l := mdocList{kind: "tag", outer: r.indent, tagIndent: r.indent}
+		width := -1
+		for i := 0; i < len(args); i++ {
+			switch arg := args[i]; arg {
+			case "-compact":
+				l.compact = true
+			case "-width":
+				if i+1 < len(args) {
+					i++
+					width = mdocWidth(args[i])
+				}
+			case "-offset":
+				if i+1 < len(args) {
+					i++
+					l.tagIndent += mdocOffset(args[i])
+				}
+			default:
+				if strings.HasPrefix(arg, "-") {
+					l.kind = arg[1:]
+				}
+			}
+		}
+		if width < 0 {
+			switch l.kind {
+			case "bullet", "dash", "hyphen":
+				width = 3
+			case "enum":
+				width = 5
+			case "tag", "hang":
+				width = 8
+			default:
+				width = 0
+			}
+		}
+		l.indent = l.tagIndent + width
+		r.lists = append(r.lists, l)
+	case "It":
+		r.mdocItem(args)
+	case "El":
+		r.breakLine()
+		if n := len(r.lists); n > 0 {
+			r.indent = r.lists[n-1].outer
+			r.lists = r.lists[:n-1]
+		}
+	case "Ft":
+		if r.section == "SYNOPSIS" {
+			r.paragraph()
+		}
+		r.text(r.escapeArgs(args, " "))
+	case "Fn":
+		r.text(r.mdocPhrase(append([]string{name}, args...)))
+		if r.section == "SYNOPSIS" {
+			r.nospace = true
+			r.text(";")
+			r.breakLine()
+		}
+	case "Fo":
+		if len(args) > 0 {
+			r.text(r.escape(args[0]) + "(")
+			r.nospace = true
+			r.fnArgs = 0
+		}
+	case "Fa":
+		if r.fnArgs > 0 {
+			r.nospace = true
+			r.text(",")
+		}
+		if r.fnArgs == 0 {
+			r.nospace = true
+		}
+		r.text(r.escapeArgs(args, " "))
+		r.fnArgs++
+	case "Fc":
+		r.nospace = true
+		r.text(")")
+		r.fnArgs = 0
+		if r.section == "SYNOPSIS" {
+			r.nospace = true
+			r.text(";")
+			r.breakLine()
+		}
+	case "Fd", "In", "Cd":
+		if r.section == "SYNOPSIS" {
+			r.breakLine()
+		}
+		r.text(r.mdocPhrase(append([]string{name}, args...)))
+		if r.section == "SYNOPSIS" {
+			r.breakLine()
+		}
+	case "Ex", "Rv":
+		r.text(r.mdocStandardText(name, args))
+	case "%A", "%B", "%C", "%D", "%I", "%J", "%N", "%O", "%P", "%Q", "%R", "%T", "%V", "%U":
+		r.text(r.escapeArgs(args, " ") + ",")
+	default:
+		if mdocCallable[name] {
+			r.text(r.mdocPhrase(append([]string{name}, args...)))
+		}
+	}
+}
+
+// mdocItem starts an item of the innermost .Bl list
+func (r *roffRenderer) mdocItem(args []string) {
+	if len(r.lists) == 0 {
+		r.paragraph()
+		r.text(r.mdocPhrase(args))
+		return
+	}
+	l := &r.lists[len(r.lists)-1]
+	r.breakLine()
+	if !l.compact && !r.afterHeader {
+		r.blank()
+	}
+	l.count++
+	r.tagIndent = l.tagIndent
+	r.indent = l.indent
+
+	switch l.kind {
+	case "tag", "hang":
+		r.tag, r.tagSet = r.mdocPhrase(args), true
+	case "bullet":
+		r.tag, r.tagSet = "•", true
+	case "dash", "hyphen":
+		r.tag, r.tagSet = "-", true
+	case "enum":
+		r.tag, r.tagSet = fmt.Sprintf("%d.", l.count), true
+	case "column":
+		var cells []string
+		var cell []string
+		for _, arg := range args {
+			if arg == "Ta" {
+				cells = append(cells, r.mdocPhrase(cell))
+				cell = nil
+				continue
+			}
+			cell = append(cell, arg)
+		}
+		cells = append(cells, r.mdocPhrase(cell))
+		r.indent = l.tagIndent
+		r.writeLine(strings.Repeat(" ", r.indent) + strings.Join(cells, "  "))
+	case "ohang", "diag", "inset":
+		r.indent = l.tagIndent
+		if len(args) > 0 {
+			r.text(r.mdocPhrase(args))
+		}
+		if l.kind == "ohang" {
+			r.breakLine()
+		}
+	}
+}
+
+// mdocPhrase renders the arguments of an mdoc macro line, which may call
+// further macros like ".Op Fl o Ar file"
+func (r *roffRenderer) mdocPhrase(args []string) string {
+	var sb strings.Builder
+	glue := true
+	emit := func(s string, attach bool) {
+		if s == "" {
+			return
+		}
+		if sb.Len() > 0 && !attach && !glue && r.spacing {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(s)
+		glue = false
+	}
+
+	for i := 0; i < len(args); {
+		tok := args[i]
+		i++
+		if !mdocCallable[tok] {
+			switch {
+			case isClosingPunct(tok):
+				emit(tok, true)
+			case tok == "(" || tok == "[":
+				emit(tok, false)
+				glue = true
+			default:
+				emit(r.escape(tok), false)
+			}
+			continue
+		}
+
+		switch tok {
+		case "Ns":
+			glue = true
+		case "Ap":
+			emit("'", true)
+			glue = true
+		case "Pf":
+			if i < len(args) {
+				emit(r.escape(args[i]), false)
+				i++
+				glue = true
+			}
+		case "Op", "Bq", "Brq", "Pq", "Aq", "Dq", "Sq", "Qq", "Ql":
+			// Enclose the rest of the line, except trailing punctuation
+			end := len(args)
+			for end > i && isClosingPunct(args[end-1]) {
+				end--
+			}
+			enc := mdocEnclosures[tok]
+			emit(enc[0]+r.mdocPhrase(args[i:end])+enc[1], false)
+			i = end
+		case "Oo", "Bo", "Bro", "Po", "Ao", "Do", "So", "Qo":
+			emit(mdocEnclosures[tok][0], false)
+			glue = true
+		case "Oc", "Bc", "Brc", "Pc", "Ac", "Dc", "Sc", "Qc":
+			emit(mdocEnclosures[tok][1], true)
+		case "Eo":
+			if i < len(args) {
+				emit(r.escape(args[i]), false)
+				i++
+				glue = true
+			}
+		case "Ec":
+			if i < len(args) {
+				emit(r.escape(args[i]), true)
+				i++
+			}
+		default:
+			j := i
+			for j < len(args) && !mdocCallable[args[j]] && !isClosingPunct(args[j]) {
+				j++
+			}
+			emit(r.mdocInline(tok, args[i:j]), false)
+			i = j
+		}
+	}
+	return sb.String()
+}
+
+// mdocInline renders an inline mdoc macro and its operands
+func (r *roffRenderer) mdocInline(macro string, ops []string) string {
+	escaped := make([]string, len(ops))
+	for i, op := range ops {
+		escaped[i] = r.escape(op)
+	}
+	joined := strings.Join(escaped, " ")
+
+	switch macro {
+	case "Fl":
+		if len(escaped) == 0 {
+			return "-"
+		}
+		flags := make([]string, len(escaped))
+		for i, op := range escaped {
+			flags[i] = "-" + op
+		}
+		return strings.Join(flags, " ")
+	case "Ar":
+		if joined == "" {
+			return "file ..."
+		}
+	case "Nm":
+		if joined == "" {
+			return r.name
+		}
+	case "Xr":
+		if len(escaped) >= 2 {
+			return fmt.Sprintf("%s(%s)%s", escaped[0], escaped[1], strings.Join(escaped[2:], ""))
+		}
+	case "Fn":
+		if len(escaped) > 0 {
+			return escaped[0] + "(" + strings.Join(escaped[1:], ", ") + ")"
+		}
+	case "Fa":
+		return strings.Join(escaped, ", ")
+	case "In":
+		if joined != "" {
+			if r.section == "SYNOPSIS" {
+				return "#include <" + joined + ">"
+			}
+			return "<" + joined + ">"
+		}
+	case "Lk":
+		if len(escaped) > 1 {
+			return strings.Join(escaped[1:], " ") + " <" + escaped[0] + ">"
+		}
+	case "St":
+		if s, ok := mdocStandards[joined]; ok {
+			return s
+		}
+	case "At", "Bsx", "Bx", "Dx", "Fx", "Nx", "Ox", "Ux":
+		return strings.TrimSpace(mdocSystems[macro] + " " + joined)
+	case "Ta":
+		return "  " + joined
+	}
+	return joined
+}
+
+// mdocStandardText renders the boilerplate sentences of .Ex and .Rv
+func (r *roffRenderer) mdocStandardText(macro string, args []string) string {
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg != "-std" {
+			names = append(names, r.escape(arg))
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, r.name)
+	}
+
+	if macro == "Ex" {
+		return fmt.Sprintf("The %s utility exits 0 on success, and >0 if an error occurs.", strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("The %s() function returns the value 0 if successful; otherwise the value -1 is returned and the global variable errno is set to indicate the error.", strings.Join(names, "(), "))
+}
+
+// isClosingPunct reports whether an mdoc token is punctuation that attaches
+// to the preceding text
+func isClosingPunct(tok string) bool {
+	switch tok {
+	case ".", ",", ";", ":", "?", "!", ")", "]":
+		return true
+	}
+	return false
+}
+
+// mdocWidth returns the indentation a .Bl -width argument asks for, either
+// a number of columns or a string as wide as the tags
+func mdocWidth(arg string) int {
+	if n, ok := roffNumber(arg); ok {
+		return n
+	}
+	if arg == "Ds" {
+		return 6
+	}
+	if arg == "indent" {
+		return roffIndent
+	}
+	return utf8.RuneCountInString(arg) + 2
+}
+
+// mdocOffset returns the indentation of an -offset argument
+func mdocOffset(arg string) int {
+	switch arg {
+	case "left":
+		return 0
+	case "indent", "Ds":
+		return roffIndent - 1
+	case "indent-two":
+		return 2 * (roffIndent - 1)
+	}
+	if n, ok := roffNumber(arg); ok {
+		return n
+	}
+	return roffIndent - 1
+}
+
+// roffArgWidth returns the width given as the first of args, or def
+func roffArgWidth(args []string, def int) int {
+	if len(args) == 0 {
+		return def
+	}
+	if n, ok := roffNumber(args[0]); ok {
+		return n
+	}
+	return def
+}
+
+// roffNumber parses a roff length like "4", "4n" or "0.5i" in columns
+func roffNumber(s string) (int, bool) {
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(s, "n"), strings.HasSuffix(s, "m"):
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "i"):
+		s, unit = s[:len(s)-1], 10
+	case strings.HasSuffix(s, "c"):
+		s, unit = s[:len(s)-1], 4
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	return int(f * unit), true
+}
+
+// roffArgs splits macro arguments, honouring double quotes
+func roffArgs(s string) []string {
+	var args []string
+	for i := 0; i < len(s); {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+
+		var sb strings.Builder
+		quoted := s[i] == '"'
+		if quoted {
+			i++
+		}
+		for i < len(s) {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				sb.WriteString(s[i : i+2])
+				i += 2
+				continue
+			}
+			if quoted && c == '"' {
+				if i+1 < len(s) && s[i+1] == '"' {
+					sb.WriteByte('"')
+					i += 2
+					continue
+				}
+				i++
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+			sb.WriteByte(c)
+			i++
+		}
+		args = append(args, sb.String())
+	}
+	return args
+}
+
+// escapeArgs escapes args and joins them with sep
+func (r *roffRenderer) escapeArgs(args []string, sep string) string {
+	escaped := make([]string, len(args))
+	for i, arg := range args {
+		escaped[i] = r.escape(arg)
+	}
+	return strings.Join(escaped, sep)
+}
+
+// escape interprets the escape sequences of s. Font and size changes are
+// dropped, special characters and strings are replaced by their text.
+func (r *roffRenderer) escape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case '\\', 'e', 'E':
+			sb.WriteByte('\\')
+		case '-', '.', '`':
+			sb.WriteByte(e)
+		case '\'':
+			sb.WriteByte('\'')
+		case ' ', '~', '0':
+			sb.WriteRune(unbreakable)
+		case 't':
+			sb.WriteByte('\t')
+		case '(', '[':
+			var name string
+			name, i = roffName(s, i)
+			sb.WriteString(roffChar(name))
+		case 'C':
+			var name string
+			name, i = roffDelimited(s, i+1)
+			sb.WriteString(roffChar(name))
+		case '*':
+			var name string
+			name, i = roffName(s, i+1)
+			if r.depth < roffMaxDepth && r.expanded < roffMaxExpansions {
+				r.expanded++
+				r.depth++
+				sb.WriteString(r.escape(r.strs[name]))
+				r.depth--
+			}
+		case 'f', 'F', 'n', 'g', 'k', 'm', 'M', 'V', 'Y', '$':
+			if e == 'n' && i+1 < len(s) && (s[i+1] == '+' || s[i+1] == '-') {
+				i++
+			}
+			_, i = roffName(s, i+1)
+		case 's':
+			i = roffSize(s, i+1)
+		case 'w', 'h', 'v', 'l', 'L', 'o', 'b', 'x', 'D', 'X', 'N', 'R', 'S', 'H', 'Z', 'A', 'B':
+			_, i = roffDelimited(s, i+1)
+		case 'z':
+			// Zero-width character, printed as is
+		default:
+			// \& \) \| \^ \% \/ \, \: \c \{ \} \a \p \d \u \r print nothing
+			if strings.IndexByte(`&)|^%/,:c{}apdur`, e) == -1 {
+				sb.WriteByte(e)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// roffName reads the name of an escape starting at s[i]: one character,
+// two after "(", or any number within "[...]". It returns the name and the
+// index of its last character.
+func roffName(s string, i int) (string, int) {
+	if i >= len(s) {
+		return "", len(s) - 1
+	}
+	switch s[i] {
+	case '(':
+		if i+2 < len(s) {
+			return s[i+1 : i+3], i + 2
+		}
+		return s[i+1:], len(s) - 1
+	case '[':
+		if end := strings.IndexByte(s[i:], ']'); end != -1 {
+			return s[i+1 : i+end], i + end
+		}
+		return s[i+1:], len(s) - 1
+	}
+	return s[i : i+1], i
+}
+
+// roffDelimited reads an argument like 'text' whose delimiter is s[i],
+// returning it and the index of the closing delimiter
+func roffDelimited(s string, i int) (string, int) {
+	if i >= len(s) {
+		return "", len(s) - 1
+	}
+	if end := strings.IndexByte(s[i+1:], s[i]); end != -1 {
+		return s[i+1 : i+1+end], i + 1 + end
+	}
+	return s[i+1:], len(s) - 1
+}
+
+// roffSize skips the argument of a \s size change starting at s[i]
+func roffSize(s string, i int) int {
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	if i >= len(s) {
+		return len(s) - 1
+	}
+	switch s[i] {
+	case '(', '[':
+		_, i = roffName(s, i)
+		return i
+	case '\'':
+		_, i = roffDelimited(s, i)
+		return i
+	}
+	return i
+}
+
+// roffChar returns the text of a special character name
+func roffChar(name string) string {
+	if c, ok := roffChars[name]; ok {
+		return c
+	}
+	// \[u00E9] and \[char233] name characters by code point
+	if hex, ok := strings.CutPrefix(name, "u"); ok {
+		if n, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return string(rune(n))
+		}
+	}
+	if dec, ok := strings.CutPrefix(name, "char"); ok {
+		if n, err := strconv.Atoi(dec); err == nil {
+			return string(rune(n))
+		}
+	}
+	return ""
+}
+
+// text adds text to the output, as a heading or tag when one is expected
+func (r *roffRenderer) text(s string) {
+	switch {
+	case r.heading > 0:
+		level := r.heading - 1
+		r.heading = 0
+		r.header(strings.TrimSpace(s), level)
+		return
+	case r.tagNext:
+		r.tagNext = false
+		r.tag, r.tagSet = strings.TrimSpace(s), true
+		return
+	case r.nofill:
+		r.breakLine()
+		r.writeLine(strings.Repeat(" ", r.indent) + s)
+		return
+	}
+
+	fields := strings.Fields(s)
+	if r.nospace && len(fields) > 0 && len(r.words) > 0 && s[0] != ' ' {
+		r.words[len(r.words)-1] += fields[0]
+		fields = fields[1:]
+	}
+	r.nospace = false
+	r.words = append(r.words, fields...)
+}
+
+// header writes a section (level 0) or subsection (level 1) heading
+func (r *roffRenderer) header(name string, level int) {
+	r.breakLine()
+	r.tagNext, r.heading = false, 0
+	if !r.afterHeader {
+		r.blank()
+	}
+	r.writeLine(strings.Repeat(" ", 3*level) + name)
+	r.afterHeader = true
+	r.margins = nil
+	r.base, r.indent = roffIndent, roffIndent
+}
+
+// paragraph starts a new paragraph at the current margin
+func (r *roffRenderer) paragraph() {
+	r.breakLine()
+	if !r.compact && !r.afterHeader {
+		r.blank()
+	}
+	r.indent = r.base
+	r.tagNext = false
+}
+
+// blank writes a blank line, unless the output already ends with one
+func (r *roffRenderer) blank() {
+	if r.lastBlank || r.out.Len() == 0 {
+		return
+	}
+	r.out.WriteByte('\n')
+	r.lastBlank = true
+}
+
+// breakLine writes out the pending text, filled to the page width, with any
+// pending tag hanging in front of it
+func (r *roffRenderer) breakLine() {
+	if r.tagSet {
+		r.tagSet = false
+		prefix := strings.Repeat(" ", r.tagIndent) + r.tag
+		// The tag shares the first line when it leaves a gap before the text
+		if len(r.words) > 0 && utf8.RuneCountInString(prefix)+2 <= r.indent {
+			lines := fillWords(r.words, roffWidth-r.indent)
+			r.writeLine(prefix + strings.Repeat(" ", r.indent-utf8.RuneCountInString(prefix)) + lines[0])
+			for _, line := range lines[1:] {
+				r.writeLine(strings.Repeat(" ", r.indent) + line)
+			}
+			r.words = nil
+			return
+		}
+		r.writeLine(prefix)
+	}
+
+	if len(r.words) == 0 {
+		return
+	}
+	for _, line := range fillWords(r.words, roffWidth-r.indent) {
+		r.writeLine(strings.Repeat(" ", r.indent) + line)
+	}
+	r.words = nil
+}
+
+// footerLine lays out a page footer like man: source on the left, date
+// centered and title on the right
+func footerLine(source, date, title string) string {
+	line := []rune(strings.Repeat(" ", roffWidth))
+	put := func(col int, text string) {
+		for i, c := range []rune(text) {
+			if col+i >= 0 && col+i < len(line) {
+				line[col+i] = c
+			}
+		}
+	}
+	put((roffWidth-len([]rune(date)))/2, date)
+	put(roffWidth-len([]rune(title)), title)
+	put(0, source)
+	return strings.TrimRight(string(line), " ")
+}
+
+// writeLine writes one output line
+func (r *roffRenderer) writeLine(line string) {
+	line = strings.TrimRight(strings.ReplaceAll(line, string(unbreakable), " "), " \t")
+	if line == "" {
+		r.blank()
+		return
+	}
+	r.out.WriteString(line)
+	r.out.WriteByte('\n')
+	r.lastBlank = false
+	r.afterHeader = false
+}
+
+// fillWords fills words into lines of at most width columns
+func fillWords(words []string, width int) []string {
+	if width < 20 {
+		width = 20
+	}
+	var lines []string
+	var line strings.Builder
+	length := 0
+	for _, word := range words {
+		n := utf8.RuneCountInString(word)
+		if length > 0 && length+1+n > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			length = 0
+		}
+		if length > 0 {
+			line.WriteByte(' ')
+			length++
+		}
+		line.WriteString(word)
+		length += n
+	}
+	if length > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
@@ -0,0 +1,177 @@
+package man
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderRoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "section and paragraph",
+			source: ".TH LS 1\n.SH NAME\nls \\- list directory contents\n",
+			want:   "LS(1)\n\nNAME\n       ls - list directory contents\n",
+		},
+		{
+			name:   "footer with source and date",
+			source: ".TH SED 1 \"January 2023\" \"GNU sed 4.9\" \"User Commands\"\n.SH NAME\nsed \\- stream editor\n",
+			want: "SED(1)\n\nNAME\n       sed - stream editor\n\n" +
+				"GNU sed 4.9                      January 2023                           SED(1)\n",
+		},
+		{
+			name:   "short tag shares the line",
+			source: ".SH OPTIONS\n.TP\n.B \\-a\ndo not ignore entries\n",
+			want:   "OPTIONS\n       -a     do not ignore entries\n",
+		},
+		{
+			name:   "long tag on its own line",
+			source: ".SH OPTIONS\n.TP\n\\fB\\-a\\fR, \\fB\\-\\-all\\fR\ndo not ignore entries\n",
+			want:   "OPTIONS\n       -a, --all\n              do not ignore entries\n",
+		},
+		{
+			name:   "alternating fonts",
+			source: ".SH SEE ALSO\n.BR awk (1),\n.IR sed (1)\n",
+			want:   "SEE ALSO\n       awk(1), sed(1)\n",
+		},
+		{
+			name:   "no-fill keeps lines",
+			source: ".SH EXAMPLES\n.nf\nfind . \\-name '*.go'\n  | wc \\-l\n.fi\n",
+			want:   "EXAMPLES\n       find . -name '*.go'\n         | wc -l\n",
+		},
+		{
+			name:   "special characters and strings",
+			source: ".ds Vn 1.2\n.SH NAME\nfoo \\(em version \\*(Vn\\ \\[u00E9]t\\[char233]\n",
+			want:   "NAME\n       foo — version 1.2 été\n",
+		},
+		{
+			name:   "nroff conditions",
+			source: ".ie n .ds Q \"\"\n.el .ds Q ``\n.if t \\{\\\n.ds Q ``\n.\\}\n.SH NAME\n\\*Qx\\*Q\n",
+			want:   "NAME\n       \"x\"\n",
+		},
+		{
+			name:   "page-defined macro",
+			source: ".de Sp\n.if n .sp\n..\n.SH NAME\none\n.Sp\ntwo\n",
+			want:   "NAME\n       one\n\n       two\n",
+		},
+		{
+			name:   "mdoc flags and options",
+			source: ".Dt CAL 1\n.Sh SYNOPSIS\n.Nm cal\n.Op Fl jy\n.Op Oo Ar month Oc Ar year\n",
+			want:   "CAL(1)\n\nSYNOPSIS\n       cal [-jy] [[month] year]\n",
+		},
+		{
+			name:   "mdoc tag list",
+			source: ".Sh DESCRIPTION\n.Bl -tag -width Ds\n.It Fl j\nJulian dates.\n.It Fl o Ar file\nOutput file.\n.El\n",
+			want:   "DESCRIPTION\n       -j    Julian dates.\n\n       -o file\n             Output file.\n",
+		},
+		{
+			name:   "mdoc spacing",
+			source: ".Sh DESCRIPTION\nin the form\n.Sm off\n.Oo user @ Oc host : Op path ,\n.Sm on\nor\n.Xr ssh 1 .\n",
+			want:   "DESCRIPTION\n       in the form [user@]host:[path], or ssh(1).\n",
+		},
+		{
+			name:   "table",
+			source: ".SH UNITS\n.TS\nll.\nk\tkibibytes\nM\tmebibytes\n.TE\n",
+			want:   "UNITS\n       k  kibibytes\n       M  mebibytes\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderRoff(tt.source); got != tt.want {
+				t.Errorf("RenderRoff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderRoffFills(t *testing.T) {
+	source := ".SH DESCRIPTION\n" + strings.Repeat("word ", 40) + "\n"
+
+	lines := strings.Split(strings.TrimSpace(RenderRoff(source)), "\n")
+
+	if len(lines) < 3 {
+		t.Fatalf("expected the paragraph to be filled over several lines, got %d", len(lines))
+	}
+	for _, line := range lines[1:] {
+		if len(line) > roffWidth {
+			t.Errorf("line longer than %d columns: %q", roffWidth, line)
+		}
+		if !strings.HasPrefix(line, "       word") {
+			t.Errorf("line not indented: %q", line)
+		}
+	}
+}
+
+func TestRenderRoffRecursionBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"macro", ".de a\n.a\n.a\n.a\n.a\n..\n.a\ndone\n"},
+		{"string", ".ds a \\*a\\*a\\*a\\*a\n\\*a done\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got := RenderRoff(tt.source)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("RenderRoff() took %v, want well under a second", elapsed)
+			}
+			if !strings.Contains(got, "done") {
+				t.Errorf("RenderRoff() = %q, want the text after the expansion", got)
+			}
+		})
+	}
+}
+
+func TestRenderRoffFixtures(t *testing.T) {
+	tests := []struct {
+		file     string
+		name     string
+		sections []string
+		options  []string
+	}{
+		{
+			file:     "testdata/man/man1/grep.1",
+			name:     "grep, egrep - print lines that match patterns",
+			sections: []string{"NAME", "SYNOPSIS", "DESCRIPTION", "OPTIONS", "EXAMPLES", "SEE ALSO"},
+			options:  []string{"-e", "--regexp", "-i", "--ignore-case", "-v", "-C", "--context"},
+		},
+		{
+			file:     "testdata/man/man1/cal.1",
+			name:     "cal — displays a calendar",
+			sections: []string{"NAME", "SYNOPSIS", "DESCRIPTION", "EXIT STATUS", "SEE ALSO"},
+			options:  []string{"-j", "-m", "-w"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			source, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page := Parse("test", 1, RenderRoff(string(source)))
+
+			if page.Name != tt.name {
+				t.Errorf("Name = %q, want %q", page.Name, tt.name)
+			}
+			if got := strings.Join(page.SectionNames(), ","); got != strings.Join(tt.sections, ",") {
+				t.Errorf("sections = %s, want %s", got, strings.Join(tt.sections, ","))
+			}
+			for _, flag := range tt.options {
+				if _, ok := page.Option(flag); !ok {
+					t.Errorf("option %s not indexed", flag)
+				}
+			}
+		})
+	}
+}
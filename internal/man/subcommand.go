@@ -0,0 +1,123 @@
+package man
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// subcommandRe matches words that can be subcommands; anything else, like
+// flags or paths, is never passed to a help probe
+var subcommandRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+
+// ValidName reports whether name can be looked up as a command or
+// subcommand; names taken from scripts or model replies must pass it
+func ValidName(name string) bool {
+	return subcommandRe.MatchString(name)
+}
+
+// Resolve finds documentation for a command that may include subcommands,
+// like "git commit" or "docker run", trying the providers named in order
+// (DefaultProviders when empty). Multi-word commands resolve to git-commit
+// style man pages, then to "<cmd> <sub> --help" output, and finally to the
+// base command's documentation.
+func Resolve(command string, section int, order []string) (*ManPage, error) {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+	if len(words) == 1 {
+		return Lookup(words[0], section, order)
+	}
+	for _, word := range words[1:] {
+		if !subcommandRe.MatchString(word) {
+			return nil, fmt.Errorf("invalid subcommand: %s", word)
+		}
+	}
+
+	// Longest match first, so "git stash list" can't stop at git-stash
+	// when a git-stash-list page exists
+	for n := len(words); n >= 2; n-- {
+		if page, err := Get(strings.Join(words[:n], "-"), section); err == nil {
+			return page, nil
+		}
+	}
+
+	if help, err := GetHelpOutput(words[0], words[1:]...); err == nil {
+		page := Parse(command, 0, help)
+		page.Source = SourceHelp
+		return page, nil
+	}
+
+	return Lookup(words[0], section, order)
+}
+
+// HasSubcommandPage reports whether a git-commit style man page exists for
+// a subcommand
+func HasSubcommandPage(command, subcommand string) bool {
+	if !subcommandRe.MatchString(subcommand) {
+		return false
+	}
+	_, err := locate(command+"-"+subcommand, 0)
+	return err == nil
+}
+
+// GuessSubcommand picks the subcommand page of command that best matches a
+// question, e.g. git-commit for "git: how do I amend". It needs the man
+// index and returns false without it. The index is only loaded when
+// command has subcommand pages at all.
+func GuessSubcommand(command, question string) (string, bool) {
+	if !hasSubcommandPages(command) {
+		return "", false
+	}
+	index, err := LoadIndex()
+	if err != nil {
+		return "", false
+	}
+	return index.guessSubcommand(command, question)
+}
+
+// hasSubcommandPages reports whether any "<command>-*" page is installed in
+// a command section of the man paths
+func hasSubcommandPages(command string) bool {
+	if !subcommandRe.MatchString(command) {
+		return false
+	}
+	for _, root := range ManPaths() {
+		matches, _ := filepath.Glob(filepath.Join(root, "man*", command+"-*"))
+		for _, match := range matches {
+			if _, section, ok := pageFromPath(match); ok && commandSections[section[0]] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subcommandMargin is how much better than the command's own page a
+// subcommand page must match a question to be used instead
+const subcommandMargin = 1.5
+
+// guessSubcommand returns the best-scoring indexed command page named
+// "<command>-<sub>", when it scores clearly higher than the page of
+// command itself
+func (idx *Index) guessSubcommand(command, question string) (string, bool) {
+	prefix := command + "-"
+	sub, subScore, baseScore := "", 0.0, 0.0
+	for _, r := range idx.Search(question, 0) {
+		if r.Section == "" || !commandSections[r.Section[0]] {
+			continue
+		}
+		switch {
+		case r.Name == command && baseScore == 0:
+			baseScore = r.Score
+		case strings.HasPrefix(r.Name, prefix) && sub == "":
+			sub, subScore = strings.TrimPrefix(r.Name, prefix), r.Score
+		}
+	}
+	if sub == "" || subScore <= baseScore*subcommandMargin {
+		return "", false
+	}
+	return sub, true
+}
@@ -0,0 +1,113 @@
+package man
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGuessSubcommand(t *testing.T) {
+	docs := map[string]string{
+		"git":        "NAME\n       git - the stupid content tracker\n\nDESCRIPTION\n       Git is a version control system with many subcommands.\n",
+		"git-commit": "NAME\n       git-commit - Record changes to the repository\n\nOPTIONS\n       --amend\n              Replace the tip of the current branch by creating a new commit.\n",
+		"git-config": "NAME\n       git-config - Get and set repository or global options\n",
+		"gitk":       "NAME\n       gitk - The Git repository browser\n\nDESCRIPTION\n       Amend nothing, just browse.\n",
+	}
+	var pages []pageFile
+	for name := range docs {
+		pages = append(pages, pageFile{name: name, section: "1"})
+	}
+	index := buildIndex(pages, func(p pageFile) (string, error) { return docs[p.name], nil }, nil)
+
+	if sub, ok := index.guessSubcommand("git", "how do I amend the last commit"); !ok || sub != "commit" {
+		t.Errorf("guessSubcommand() = %q, %v, want commit", sub, ok)
+	}
+	if sub, ok := index.guessSubcommand("git", "set my global user name option"); !ok || sub != "config" {
+		t.Errorf("guessSubcommand() = %q, %v, want config", sub, ok)
+	}
+	if _, ok := index.guessSubcommand("docker", "how do I amend"); ok {
+		t.Error("guessSubcommand() should find nothing for a command without subcommand pages")
+	}
+}
+
+func TestGuessSubcommandPrefersBasePage(t *testing.T) {
+	docs := map[string]string{
+		"find":       "NAME\n       find - search for files in a directory hierarchy\n\nDESCRIPTION\n       Search the directory tree for files by name, type and modification time.\n       -name pattern matches the file name; -mtime n matches the modification time.\n",
+		"find-debug": "NAME\n       find-debug - print debug information about a directory scan\n",
+		"ls":         "NAME\n       ls - list directory contents\n",
+		"grep":       "NAME\n       grep - print lines that match patterns\n",
+	}
+	var pages []pageFile
+	for name := range docs {
+		pages = append(pages, pageFile{name: name, section: "1"})
+	}
+	index := buildIndex(pages, func(p pageFile) (string, error) { return docs[p.name], nil }, nil)
+
+	if sub, ok := index.guessSubcommand("find", "find files by name modified in the last day"); ok {
+		t.Errorf("guessSubcommand() = %q, want the find page itself", sub)
+	}
+	if sub, ok := index.guessSubcommand("find", "print debug information"); !ok || sub != "debug" {
+		t.Errorf("guessSubcommand() = %q, %v, want debug", sub, ok)
+	}
+}
+
+func TestHasSubcommandPages(t *testing.T) {
+	root := t.TempDir()
+	for _, page := range []string{"man1/git-commit.1.gz", "man1/ls.1", "man3/ls-extra.3", "man8/ip-route.8"} {
+		path := filepath.Join(root, page)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("MANPATH", root)
+
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"git", true},
+		{"ip", true},
+		{"ls", false}, // only a library page
+		{"docker", false},
+		{"g*", false},
+	}
+	for _, tt := range tests {
+		if got := hasSubcommandPages(tt.command); got != tt.want {
+			t.Errorf("hasSubcommandPages(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRejectsUnsafeSubcommands(t *testing.T) {
+	for _, command := range []string{"docker --rm", "git ../../etc/passwd", "kubectl $(id)"} {
+		if _, err := Resolve(command, 0, nil); err == nil {
+			t.Errorf("Resolve(%q) expected error", command)
+		}
+	}
+	if _, err := Resolve("   ", 0, nil); err == nil {
+		t.Error("Resolve() expected error for an empty command")
+	}
+}
+
+func TestParseHelpOutput(t *testing.T) {
+	help := `Usage:  docker run [OPTIONS] IMAGE [COMMAND] [ARG...]
+
+Create and run a new container from an image
+
+Options:
+  -d, --detach              Run container in background and print container ID
+  -v, --volume list         Bind mount a volume
+`
+	page := Parse("docker run", 0, help)
+
+	opt, ok := page.Option("-v")
+	if !ok {
+		t.Fatal("Option(-v) not found in --help output")
+	}
+	if opt.Arg != "list" || opt.Description != "Bind mount a volume" {
+		t.Errorf("Option(-v) = %+v", opt)
+	}
+}
@@ -0,0 +1,317 @@
+package man
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Tool flavors, which often differ in the options they accept
+const (
+	FlavorGNU     = "GNU"
+	FlavorBSD     = "BSD"
+	FlavorBusyBox = "BusyBox"
+)
+
+// Binary describes the installed executable a command runs
+type Binary struct {
+	Command string `json:"command"`
+	// Path is the executable, with symlinks resolved
+	Path string `json:"path"`
+	// Version is the first line of its --version output
+	Version string `json:"version,omitempty"`
+	// Package is the owning package and its version, e.g. "sed 4.9-1"
+	Package string `json:"package,omitempty"`
+	// Flavor is GNU, BSD or BusyBox, when known
+	Flavor string `json:"flavor,omitempty"`
+}
+
+var (
+	// versionRe matches a dotted version number
+	versionRe = regexp.MustCompile(`\d+\.\d+(?:\.\d+)*`)
+	// footerTitleRe matches the page title ending a man page footer
+	footerTitleRe = regexp.MustCompile(`\S+\([0-9n][a-zA-Z0-9]*\)$`)
+	// footerFieldRe separates the fields of a man page footer
+	footerFieldRe = regexp.MustCompile(`\s{2,}`)
+	// ownedByRe matches pacman's and apk's answer to who owns a file
+	ownedByRe = regexp.MustCompile(`is owned by (\S+)(?: (\S+))?`)
+	// nixStoreRe matches a Nix store path, capturing its name-version
+	nixStoreRe = regexp.MustCompile(`^/nix/store/[a-z0-9]{32}-([^/]+)/`)
+	// cellarRe matches a Homebrew keg path, capturing the formula and version
+	cellarRe = regexp.MustCompile(`/Cellar/([^/]+)/([^/]+)/`)
+)
+
+// DetectBinary finds the executable a command runs, its version and the
+// package it belongs to. Only binaries the help policy allows without
+// asking are run for their version; others get their package only.
+func DetectBinary(command string) (*Binary, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("command not found: %s", command)
+	}
+	resolved := path
+	if abs, err := filepath.Abs(path); err == nil {
+		resolved = abs
+	}
+	if target, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = target
+	}
+
+	cacheFile, cacheErr := probeCachePath(resolved, []string{"[binary]", command})
+	if cacheErr == nil {
+		if data, err := os.ReadFile(cacheFile); err == nil {
+			var bin Binary
+			if json.Unmarshal(data, &bin) == nil {
+				return &bin, nil
+			}
+		}
+	}
+
+	bin := &Binary{Command: command, Path: resolved}
+	if filepath.Base(resolved) == "busybox" {
+		bin.Flavor = FlavorBusyBox
+		if output, err := runProbe(resolved, []string{"--help"}); err == nil {
+			bin.Version = firstLine(output)
+		}
+	} else if _, err := checkProbeAllowed(command, false); err == nil {
+		output, err := runProbe(path, []string{"--version"})
+		if line := firstLine(output); err == nil && versionRe.MatchString(line) {
+			bin.Version = shortVersion(line)
+		}
+	}
+	bin.Package = packageOf(path, resolved)
+	if bin.Flavor == "" {
+		bin.Flavor = flavorOf(bin)
+	}
+
+	if cacheErr == nil {
+		if data, err := json.Marshal(bin); err == nil {
+			_ = writeCacheFile(cacheFile, string(data))
+		}
+	}
+	return bin, nil
+}
+
+// firstLine returns the first non-empty line of output
+func firstLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// maxVersionChars bounds version lines, which for tools like curl go on
+// to list every library
+const maxVersionChars = 80
+
+// shortVersion cuts a long version line after the version number
+func shortVersion(line string) string {
+	if len(line) <= maxVersionChars {
+		return line
+	}
+	if loc := versionRe.FindStringIndex(line); loc != nil && loc[1] <= maxVersionChars {
+		return line[:loc[1]]
+	}
+	return line[:maxVersionChars]
+}
+
+// flavorOf guesses whether a binary is the GNU, BSD or BusyBox tool
+func flavorOf(bin *Binary) string {
+	text := bin.Version + " " + bin.Package
+	switch {
+	case strings.Contains(text, "BusyBox") || strings.HasPrefix(bin.Package, "busybox"):
+		return FlavorBusyBox
+	case strings.Contains(text, "GNU") || strings.HasPrefix(bin.Package, "coreutils") || strings.Contains(bin.Path, "/opt/homebrew/opt/gnu-"):
+		return FlavorGNU
+	case bin.Version == "" && isBSD() && (strings.HasPrefix(bin.Path, "/bin/") || strings.HasPrefix(bin.Path, "/usr/bin/") || strings.HasPrefix(bin.Path, "/usr/sbin/")):
+		// BSD tools reject --version
+		return FlavorBSD
+	}
+	return ""
+}
+
+// isBSD reports whether the system tools are BSD's
+func isBSD() bool {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return true
+	}
+	return false
+}
+
+// packageOf returns the package that installed a binary and its version,
+// from the install path for Homebrew and Nix, or by asking the system's
+// package manager
+func packageOf(path, resolved string) string {
+	if m := cellarRe.FindStringSubmatch(resolved); m != nil {
+		return m[1] + " " + m[2]
+	}
+	if m := nixStoreRe.FindStringSubmatch(resolved); m != nil {
+		return m[1]
+	}
+
+	candidates := []string{path, resolved}
+	// On merged-/usr systems dpkg records /bin/sed for /usr/bin/sed
+	if rest, ok := strings.CutPrefix(path, "/usr"); ok {
+		candidates = append(candidates, rest)
+	}
+
+	for _, file := range candidates {
+		if pkg := queryPackage(file); pkg != "" {
+			return pkg
+		}
+	}
+	return ""
+}
+
+// queryPackage asks the installed package manager which package owns file
+func queryPackage(file string) string {
+	switch {
+	case hasTool("dpkg-query"):
+		output, err := runProbe("dpkg-query", []string{"-S", file})
+		if err != nil {
+			return ""
+		}
+		// "sed: /bin/sed", or "libc-bin:amd64: /sbin/ldconfig"
+		name, _, ok := strings.Cut(firstLine(output), ": ")
+		if !ok || strings.Contains(name, ",") {
+			return ""
+		}
+		pkg, _, _ := strings.Cut(name, ":")
+		version, err := runProbe("dpkg-query", []string{"-W", "-f=${Version}", name})
+		if err != nil {
+			return pkg
+		}
+		return pkg + " " + firstLine(version)
+	case hasTool("rpm"):
+		output, err := runProbe("rpm", []string{"-qf", "--qf", "%{NAME} %{VERSION}-%{RELEASE}", file})
+		if err != nil {
+			return ""
+		}
+		return firstLine(output)
+	case hasTool("pacman"):
+		output, err := runProbe("pacman", []string{"-Qo", file})
+		if err != nil {
+			return ""
+		}
+		if m := ownedByRe.FindStringSubmatch(output); m != nil {
+			return strings.TrimSpace(m[1] + " " + m[2])
+		}
+	case hasTool("apk"):
+		output, err := runProbe("apk", []string{"info", "--who-owns", file})
+		if err != nil {
+			return ""
+		}
+		if m := ownedByRe.FindStringSubmatch(output); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// hasTool reports whether a tool is on PATH
+func hasTool(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// String describes the binary for prompts, e.g.
+// "sed (GNU sed) 4.9 at /usr/bin/sed, package sed 4.9-1"
+func (b *Binary) String() string {
+	if b == nil {
+		return ""
+	}
+	var parts []string
+	switch {
+	case b.Version != "":
+		parts = append(parts, b.Version)
+	case b.Flavor != "":
+		parts = append(parts, b.Flavor+" "+b.Command)
+	default:
+		parts = append(parts, b.Command)
+	}
+	parts[0] += " at " + b.Path
+	if b.Package != "" {
+		parts = append(parts, "package "+b.Package)
+	}
+	if b.Flavor != "" && !strings.Contains(parts[0], b.Flavor) {
+		parts = append(parts, b.Flavor+" flavor")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// VersionNumber returns the binary's version number, e.g. "4.9"
+func (b *Binary) VersionNumber() string {
+	if v := versionRe.FindString(b.Version); v != "" {
+		return v
+	}
+	if _, version, ok := strings.Cut(b.Package, " "); ok {
+		return versionRe.FindString(version)
+	}
+	return ""
+}
+
+// PageSource returns what a man page documents according to its footer,
+// e.g. "GNU coreutils 9.4", or "" if the page has none
+func PageSource(page *ManPage) string {
+	content := strings.TrimRight(page.Content, "\n ")
+	footer := strings.TrimSpace(content[strings.LastIndex(content, "\n")+1:])
+	fields := footerFieldRe.Split(footer, -1)
+	if len(fields) < 2 {
+		return ""
+	}
+	// man(7) footers end with the title, mdoc(7) ones repeat the source
+	last := fields[len(fields)-1]
+	if !footerTitleRe.MatchString(last) && last != fields[0] {
+		return ""
+	}
+	// source, date, title; a footer with two fields may lack the source
+	if len(fields) == 2 && !versionRe.MatchString(fields[0]) {
+		return ""
+	}
+	return fields[0]
+}
+
+// VersionMismatch describes how the man page and the installed binary
+// disagree, or returns "" when they agree or can't be compared. Versions
+// are compared by major and minor number.
+func VersionMismatch(page *ManPage, bin *Binary) string {
+	if page == nil || bin == nil {
+		return ""
+	}
+	source := PageSource(page)
+	if source == "" {
+		return ""
+	}
+
+	pageGNU := strings.Contains(source, "GNU")
+	if pageGNU && (bin.Flavor == FlavorBSD || bin.Flavor == FlavorBusyBox) {
+		return fmt.Sprintf("the man page documents %s, but %s is the %s version", source, bin.Path, bin.Flavor)
+	}
+	if !pageGNU && strings.Contains(source, "BSD") && bin.Flavor == FlavorGNU {
+		return fmt.Sprintf("the man page documents %s, but %s is the GNU version", source, bin.Path)
+	}
+
+	pageVersion := versionRe.FindString(source)
+	binVersion := bin.VersionNumber()
+	if pageVersion == "" || binVersion == "" || majorMinor(pageVersion) == majorMinor(binVersion) {
+		return ""
+	}
+	return fmt.Sprintf("the man page documents %s, but %s is version %s", source, bin.Path, binVersion)
+}
+
+// majorMinor returns the major and minor parts of a dotted version
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, ".")
+}
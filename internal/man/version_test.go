@@ -0,0 +1,140 @@
+package man
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPageSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "man footer",
+			content: "LS(1)\n\nNAME\n       ls - list directory contents\n\nGNU coreutils 9.1               September 2022                           LS(1)\n",
+			want:    "GNU coreutils 9.1",
+		},
+		{
+			name:    "mdoc footer",
+			content: "SED(1)\n\nNAME\n     sed - stream editor\n\nmacOS 14.5                       June 10, 2020                      macOS 14.5\n",
+			want:    "macOS 14.5",
+		},
+		{
+			name:    "footer without source",
+			content: "GZIP(1)\n\nNAME\n       gzip\n\n                                    local                              GZIP(1)\n",
+			want:    "",
+		},
+		{
+			name:    "no footer",
+			content: "NAME\n       cd - Change the shell working directory.\n",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PageSource(&ManPage{Content: tt.content}); got != tt.want {
+				t.Errorf("PageSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	page := &ManPage{Content: "SED(1)\n\nNAME\n       sed\n\nGNU sed 4.8                      January 2020                           SED(1)\n"}
+
+	tests := []struct {
+		name string
+		bin  *Binary
+		want string
+	}{
+		{
+			name: "same minor version",
+			bin:  &Binary{Path: "/usr/bin/sed", Version: "sed (GNU sed) 4.8.1", Flavor: FlavorGNU},
+			want: "",
+		},
+		{
+			name: "newer binary",
+			bin:  &Binary{Path: "/usr/bin/sed", Version: "sed (GNU sed) 4.9", Flavor: FlavorGNU},
+			want: "is version 4.9",
+		},
+		{
+			name: "version from package",
+			bin:  &Binary{Path: "/usr/bin/sed", Package: "sed 4.9-1"},
+			want: "is version 4.9",
+		},
+		{
+			name: "BSD binary",
+			bin:  &Binary{Path: "/usr/bin/sed", Flavor: FlavorBSD},
+			want: "is the BSD version",
+		},
+		{
+			name: "unknown version",
+			bin:  &Binary{Path: "/usr/bin/sed"},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VersionMismatch(page, tt.bin)
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("VersionMismatch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackageOfPath(t *testing.T) {
+	tests := []struct {
+		resolved string
+		want     string
+	}{
+		{"/opt/homebrew/Cellar/gnu-sed/4.9/bin/gsed", "gnu-sed 4.9"},
+		{"/nix/store/0c8s2hpn9rclgl5ls7ha6vhbx4xmx2h8-gnused-4.9/bin/sed", "gnused-4.9"},
+	}
+
+	for _, tt := range tests {
+		if got := packageOf(tt.resolved, tt.resolved); got != tt.want {
+			t.Errorf("packageOf(%q) = %q, want %q", tt.resolved, got, tt.want)
+		}
+	}
+}
+
+func TestBinaryString(t *testing.T) {
+	tests := []struct {
+		bin  Binary
+		want string
+	}{
+		{
+			bin:  Binary{Command: "sed", Path: "/usr/bin/sed", Version: "sed (GNU sed) 4.9", Package: "sed 4.9-1", Flavor: FlavorGNU},
+			want: "sed (GNU sed) 4.9 at /usr/bin/sed, package sed 4.9-1",
+		},
+		{
+			bin:  Binary{Command: "sed", Path: "/usr/bin/sed", Flavor: FlavorBSD},
+			want: "BSD sed at /usr/bin/sed",
+		},
+		{
+			bin:  Binary{Command: "ls", Path: "/bin/busybox", Version: "BusyBox v1.36.1 (2024-01-01) multi-call binary.", Flavor: FlavorBusyBox},
+			want: "BusyBox v1.36.1 (2024-01-01) multi-call binary. at /bin/busybox",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.bin.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestShortVersion(t *testing.T) {
+	long := "curl 7.88.1 (x86_64-pc-linux-gnu) libcurl/7.88.1 OpenSSL/3.0.17 zlib/1.2.13 brotli/1.0.9 zstd/1.5.4"
+	if got := shortVersion(long); got != "curl 7.88.1" {
+		t.Errorf("shortVersion() = %q, want %q", got, "curl 7.88.1")
+	}
+	if got := shortVersion("sed (GNU sed) 4.9"); got != "sed (GNU sed) 4.9" {
+		t.Errorf("shortVersion() = %q, want it unchanged", got)
+	}
+}
@@ -0,0 +1,193 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Output formats supported by the review
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// severityIcons prefixes text findings
+var severityIcons = map[string]string{
+	SeverityError:   "❌",
+	SeverityWarning: "⚠️ ",
+	SeverityInfo:    "💡",
+}
+
+// Format renders findings for a script in the given format
+func Format(format, path string, findings []Finding, toolVersion string) (string, error) {
+	switch format {
+	case FormatText, "":
+		return formatText(path, findings), nil
+	case FormatJSON:
+		return formatJSON(path, findings)
+	case FormatSARIF:
+		return formatSARIF(path, findings, toolVersion)
+	default:
+		return "", fmt.Errorf("unsupported format: %s (use text, json or sarif)", format)
+	}
+}
+
+// formatText renders findings as compiler-style lines
+func formatText(path string, findings []Finding) string {
+	if len(findings) == 0 {
+		return fmt.Sprintf("✅ No issues found in %s\n", path)
+	}
+
+	var sb strings.Builder
+	for _, f := range findings {
+		location := fmt.Sprintf("%s:%d", path, f.Line)
+		if f.EndLine > f.Line {
+			location += fmt.Sprintf("-%d", f.EndLine)
+		}
+		sb.WriteString(fmt.Sprintf("%s %s [%s] %s\n", severityIcons[f.Severity], location, f.Rule, f.Message))
+		if f.Suggestion != "" {
+			sb.WriteString(fmt.Sprintf("   → %s\n", f.Suggestion))
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	sb.WriteString(fmt.Sprintf("\n%d findings: %d errors, %d warnings, %d info\n",
+		len(findings), counts[SeverityError], counts[SeverityWarning], counts[SeverityInfo]))
+
+	return sb.String()
+}
+
+// formatJSON renders findings as a JSON document
+func formatJSON(path string, findings []Finding) (string, error) {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	data, err := json.MarshalIndent(struct {
+		File     string    `json:"file"`
+		Findings []Finding `json:"findings"`
+	}{path, findings}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode findings: %w", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// sarifLevels maps severities to SARIF result levels
+var sarifLevels = map[string]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "note",
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// formatSARIF renders findings as a SARIF 2.1.0 log for code scanning tools
+func formatSARIF(path string, findings []Finding, toolVersion string) (string, error) {
+	ruleIDs := make([]string, 0, len(RuleDescriptions))
+	for id := range RuleDescriptions {
+		ruleIDs = append(ruleIDs, id)
+	}
+	sort.Strings(ruleIDs)
+
+	rules := make([]sarifRule, 0, len(ruleIDs))
+	for _, id := range ruleIDs {
+		rules = append(rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: RuleDescriptions[id]}})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		message := f.Message
+		if f.Suggestion != "" {
+			message += "\nSuggestion: " + f.Suggestion
+		}
+		results = append(results, sarifResult{
+			RuleID:  f.Rule,
+			Level:   sarifLevels[f.Severity],
+			Message: sarifMessage{Text: message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: path},
+					Region:           sarifRegion{StartLine: f.Line, EndLine: f.EndLine},
+				},
+			}},
+		})
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "ohman",
+				Version:        toolVersion,
+				InformationURI: "https://github.com/liliang-cn/ohman",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SARIF: %w", err)
+	}
+	return string(data) + "\n", nil
+}
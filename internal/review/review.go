@@ -0,0 +1,340 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/liliang-cn/ohman/internal/cmdline"
+)
+
+// Severity levels of a finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Rules reported by the review, matching what the LLM is asked to look for
+const (
+	RuleStrictMode   = "missing-strict-mode"
+	RuleUnquotedVar  = "unquoted-variable"
+	RuleNonPortable  = "non-portable"
+	RuleDangerous    = "dangerous-operation"
+	RuleBug          = "bug"
+	RuleBestPractice = "best-practice"
+)
+
+// RuleDescriptions describes every rule, used for SARIF rule metadata
+var RuleDescriptions = map[string]string{
+	RuleStrictMode:   "Script does not enable strict mode (set -euo pipefail)",
+	RuleUnquotedVar:  "Variable expansion is not quoted and may be split or globbed",
+	RuleNonPortable:  "Flag or construct is not portable across platforms or shells",
+	RuleDangerous:    "Operation may destroy data or compromise the system",
+	RuleBug:          "Logic error or incorrect command usage",
+	RuleBestPractice: "Style or robustness improvement",
+}
+
+// Finding is a single issue anchored to lines of the script
+type Finding struct {
+	Line       int    `json:"line"`
+	EndLine    int    `json:"end_line,omitempty"`
+	Severity   string `json:"severity"`
+	Rule       string `json:"rule"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Chunk is a contiguous range of script lines reviewed in one LLM call
+type Chunk struct {
+	StartLine int
+	EndLine   int
+	Lines     []string
+}
+
+// Numbered returns the chunk text with each line prefixed by its line number,
+// so findings can be anchored to the original script
+func (c Chunk) Numbered() string {
+	var sb strings.Builder
+	for i, line := range c.Lines {
+		sb.WriteString(fmt.Sprintf("%5d| %s\n", c.StartLine+i, line))
+	}
+	return sb.String()
+}
+
+// Text returns the raw chunk text
+func (c Chunk) Text() string {
+	return strings.Join(c.Lines, "\n")
+}
+
+// EstimateTokens roughly estimates the token count of text, assuming about
+// four characters per token
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// SplitScript splits a script into chunks of at most maxTokens each. Chunks
+// end at blank lines or closing braces where possible, so functions and
+// paragraphs stay together.
+func SplitScript(content string, maxTokens int) []Chunk {
+	content = strings.TrimRight(content, "\n")
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+
+	var chunks []Chunk
+	start := 0
+	for start < len(lines) {
+		end := start
+		tokens := 0
+		lastBreak := -1
+		for end < len(lines) {
+			lineTokens := EstimateTokens(lines[end]) + 1
+			if tokens+lineTokens > maxTokens && end > start {
+				break
+			}
+			tokens += lineTokens
+			if isBreakLine(lines[end]) {
+				lastBreak = end
+			}
+			end++
+		}
+
+		// Prefer a natural boundary in the second half of the chunk
+		if end < len(lines) && lastBreak >= start+(end-start)/2 {
+			end = lastBreak + 1
+		}
+
+		chunks = append(chunks, Chunk{
+			StartLine: start + 1,
+			EndLine:   end,
+			Lines:     lines[start:end],
+		})
+		start = end
+	}
+
+	return chunks
+}
+
+// isBreakLine reports whether a chunk can end after line
+func isBreakLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || (trimmed == "}" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t"))
+}
+
+var (
+	// setErrexitRe and setNounsetRe find the option anywhere in the set
+	// arguments, as in set -o errexit -o nounset or set -eo pipefail -u
+	setErrexitRe   = regexp.MustCompile(`^\s*set\s+(?:.*\s)?(-[a-zA-Z]*e[a-zA-Z]*|-o\s+errexit)\b`)
+	setPipeRe      = regexp.MustCompile(`^\s*set\s+.*(-o\s+pipefail|-[a-zA-Z]*o\s+pipefail)`)
+	setNounsetRe   = regexp.MustCompile(`^\s*set\s+(?:.*\s)?(-[a-zA-Z]*u[a-zA-Z]*|-o\s+nounset)\b`)
+	shebangShellRe = regexp.MustCompile(`^#!\s*\S*?(?:/|env\s+)(bash|zsh|ksh|dash|sh)\b`)
+	functionRe     = regexp.MustCompile(`^\s*(?:function\s+)?([A-Za-z_][A-Za-z0-9_:-]*)\s*\(\s*\)`)
+)
+
+// CheckStrictMode reports a finding when the script doesn't enable errexit,
+// nounset and pipefail. It is checked locally rather than by the LLM since
+// it needs the whole script, not a single chunk.
+func CheckStrictMode(content string) []Finding {
+	var errexit, nounset, pipefail bool
+	lines := strings.Split(content, "\n")
+	for _, line := range lines {
+		if setErrexitRe.MatchString(line) {
+			errexit = true
+		}
+		if setNounsetRe.MatchString(line) {
+			nounset = true
+		}
+		if setPipeRe.MatchString(line) {
+			pipefail = true
+		}
+	}
+
+	var missing []string
+	if !errexit {
+		missing = append(missing, "-e")
+	}
+	if !nounset {
+		missing = append(missing, "-u")
+	}
+	// POSIX sh has no pipefail
+	posix := ScriptShell(content) == "sh" || ScriptShell(content) == "dash"
+	if !pipefail && !posix {
+		missing = append(missing, "-o pipefail")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Anchor the finding on the line after the shebang, where it should go
+	line := 1
+	if strings.HasPrefix(lines[0], "#!") {
+		line = 2
+	}
+
+	suggestion := "set -euo pipefail"
+	if posix {
+		suggestion = "set -eu"
+	}
+
+	return []Finding{{
+		Line:       line,
+		Severity:   SeverityWarning,
+		Rule:       RuleStrictMode,
+		Message:    fmt.Sprintf("Strict mode is not enabled (missing %s), so failures can go unnoticed", strings.Join(missing, ", ")),
+		Suggestion: suggestion,
+	}}
+}
+
+// ScriptCommands returns the external commands a piece of script runs,
+// skipping functions defined in the script itself
+func ScriptCommands(script string) []cmdline.Command {
+	functions := make(map[string]bool)
+	for _, line := range strings.Split(script, "\n") {
+		if m := functionRe.FindStringSubmatch(line); m != nil {
+			functions[m[1]] = true
+		}
+	}
+
+	parsed, err := cmdline.Parse(script)
+	if err != nil {
+		// Constructs like heredocs and case patterns may not parse as a
+		// whole, fall back to line by line and skip what still fails
+		parsed = nil
+		for _, line := range strings.Split(script, "\n") {
+			if commands, err := cmdline.Parse(line); err == nil {
+				parsed = append(parsed, commands...)
+			}
+		}
+	}
+
+	var commands []cmdline.Command
+	for _, cmd := range parsed {
+		if functions[cmd.Name] || !isCommandName(cmd.Name) {
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+// ScriptShell returns the shell a script is written for based on its
+// shebang, defaulting to bash
+func ScriptShell(content string) string {
+	first, _, _ := strings.Cut(content, "\n")
+	if m := shebangShellRe.FindStringSubmatch(first); m != nil {
+		return m[1]
+	}
+	return "bash"
+}
+
+// isCommandName filters out words that can't be commands, like "{" or
+// variable expansions
+func isCommandName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._+-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseFindings parses the JSON findings returned by the LLM for a chunk.
+// The JSON may be wrapped in a code block. Findings outside the chunk are
+// clamped to its range, and unknown severities and rules are normalized.
+func ParseFindings(response string, chunk Chunk) ([]Finding, error) {
+	raw := extractJSON(response)
+	if raw == "" {
+		return nil, fmt.Errorf("no JSON findings in response")
+	}
+
+	var findings []Finding
+	if err := json.Unmarshal([]byte(raw), &findings); err != nil {
+		// Accept {"findings": [...]} as well
+		var wrapped struct {
+			Findings []Finding `json:"findings"`
+		}
+		if err2 := json.Unmarshal([]byte(raw), &wrapped); err2 != nil {
+			return nil, fmt.Errorf("failed to parse findings: %w", err)
+		}
+		findings = wrapped.Findings
+	}
+
+	for i := range findings {
+		f := &findings[i]
+		f.Line = clamp(f.Line, chunk.StartLine, chunk.EndLine)
+		if f.EndLine != 0 {
+			f.EndLine = clamp(f.EndLine, f.Line, chunk.EndLine)
+			if f.EndLine == f.Line {
+				f.EndLine = 0
+			}
+		}
+		switch f.Severity {
+		case SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			f.Severity = SeverityWarning
+		}
+		if _, ok := RuleDescriptions[f.Rule]; !ok {
+			f.Rule = RuleBestPractice
+		}
+	}
+
+	return findings, nil
+}
+
+// extractJSON returns the JSON array or object in a response, looking inside
+// a markdown code block first
+func extractJSON(response string) string {
+	response = strings.TrimSpace(response)
+	if start := strings.Index(response, "```"); start != -1 {
+		body := response[start+3:]
+		if nl := strings.Index(body, "\n"); nl != -1 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end != -1 {
+			response = strings.TrimSpace(body[:end])
+		}
+	}
+
+	start := strings.IndexAny(response, "[{")
+	if start == -1 {
+		return ""
+	}
+	closer := "]"
+	if response[start] == '{' {
+		closer = "}"
+	}
+	end := strings.LastIndex(response, closer)
+	if end < start {
+		return ""
+	}
+	return response[start : end+1]
+}
+
+// clamp limits n to [lo, hi]
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// Sort orders findings by line, then by severity
+func Sort(findings []Finding) {
+	rank := map[string]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return rank[findings[i].Severity] < rank[findings[j].Severity]
+	})
+}
@@ -0,0 +1,199 @@
+package review
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/liliang-cn/ohman/internal/cmdline"
+)
+
+func TestSplitScript(t *testing.T) {
+	var lines []string
+	for i := 0; i < 40; i++ {
+		lines = append(lines, "echo 'line of a long script'")
+		if i%10 == 9 {
+			lines = append(lines, "")
+		}
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	chunks := SplitScript(content, 100)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	next := 1
+	for _, c := range chunks {
+		if c.StartLine != next {
+			t.Errorf("chunk starts at %d, want %d", c.StartLine, next)
+		}
+		if c.EndLine-c.StartLine+1 != len(c.Lines) {
+			t.Errorf("chunk %d-%d has %d lines", c.StartLine, c.EndLine, len(c.Lines))
+		}
+		if EstimateTokens(c.Text()) > 100 {
+			t.Errorf("chunk %d-%d exceeds the budget", c.StartLine, c.EndLine)
+		}
+		next = c.EndLine + 1
+	}
+	// The trailing blank line is dropped
+	if next-1 != len(lines)-1 {
+		t.Errorf("chunks cover %d lines, want %d", next-1, len(lines)-1)
+	}
+
+	// Chunks should end on the blank paragraph separators
+	if last := chunks[0].Lines[len(chunks[0].Lines)-1]; last != "" {
+		t.Errorf("first chunk should end at a blank line, ends with %q", last)
+	}
+
+	if got := chunks[0].Numbered(); !strings.HasPrefix(got, "    1| echo") {
+		t.Errorf("Numbered() = %q", got)
+	}
+}
+
+func TestCheckStrictMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"strict", "#!/bin/bash\nset -euo pipefail\nls\n", ""},
+		{"separate options", "#!/bin/bash\nset -e\nset -o nounset\nset -o pipefail\n", ""},
+		{"long options on one line", "#!/bin/bash\nset -o errexit -o nounset -o pipefail\n", ""},
+		{"short flag after pipefail", "#!/bin/bash\nset -eo pipefail -u\n", ""},
+		{"missing", "#!/usr/bin/env bash\nls\n", "-e, -u, -o pipefail"},
+		{"partial", "#!/bin/bash\nset -e\n", "-u, -o pipefail"},
+		{"posix sh", "#!/bin/sh\nset -eu\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := CheckStrictMode(tt.content)
+			if tt.want == "" {
+				if len(findings) != 0 {
+					t.Errorf("expected no findings, got %+v", findings)
+				}
+				return
+			}
+			if len(findings) != 1 || !strings.Contains(findings[0].Message, tt.want) || findings[0].Line != 2 {
+				t.Errorf("findings = %+v, want missing %s on line 2", findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptCommands(t *testing.T) {
+	script := `#!/bin/bash
+cleanup() {
+  rm -rf "$TMP"
+}
+trap cleanup EXIT
+cat <<EOF
+not a command
+EOF
+find . -name '*.sh' | xargs shellcheck
+cleanup`
+
+	got := strings.Join(cmdline.CommandNames(ScriptCommands(script)), ",")
+
+	for _, want := range []string{"rm", "trap", "cat", "find", "xargs", "shellcheck"} {
+		if !strings.Contains(","+got+",", ","+want+",") {
+			t.Errorf("ScriptCommands() = %s, missing %s", got, want)
+		}
+	}
+	if strings.Contains(","+got+",", ",cleanup,") {
+		t.Errorf("ScriptCommands() = %s, should skip script functions", got)
+	}
+}
+
+func TestScriptShell(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/bash\n":          "bash",
+		"#!/usr/bin/env zsh\n":   "zsh",
+		"#!/bin/sh -e\n":         "sh",
+		"echo no shebang\n":      "bash",
+		"#!/usr/bin/env python3": "bash",
+	}
+
+	for content, want := range tests {
+		if got := ScriptShell(content); got != want {
+			t.Errorf("ScriptShell(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestParseFindings(t *testing.T) {
+	chunk := Chunk{StartLine: 10, EndLine: 20}
+
+	response := "Here you go:\n```json\n" + `[
+  {"line": 12, "severity": "error", "rule": "dangerous-operation", "message": "rm -rf on unchecked variable"},
+  {"line": 99, "end_line": 120, "severity": "critical", "rule": "made-up", "message": "out of range"}
+]` + "\n```"
+
+	findings, err := ParseFindings(response, chunk)
+	if err != nil {
+		t.Fatalf("ParseFindings() error = %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d", len(findings))
+	}
+	if findings[0].Line != 12 || findings[0].Rule != RuleDangerous {
+		t.Errorf("first finding = %+v", findings[0])
+	}
+	if f := findings[1]; f.Line != 20 || f.EndLine != 0 || f.Severity != SeverityWarning || f.Rule != RuleBestPractice {
+		t.Errorf("second finding should be clamped and normalized, got %+v", f)
+	}
+
+	if findings, err := ParseFindings(`{"findings": []}`, chunk); err != nil || len(findings) != 0 {
+		t.Errorf("wrapped empty findings = %v, %v", findings, err)
+	}
+
+	if _, err := ParseFindings("Looks good to me!", chunk); err == nil {
+		t.Error("expected error for response without JSON")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	findings := []Finding{
+		{Line: 2, Severity: SeverityWarning, Rule: RuleStrictMode, Message: "no strict mode", Suggestion: "set -euo pipefail"},
+		{Line: 7, EndLine: 8, Severity: SeverityError, Rule: RuleUnquotedVar, Message: "unquoted $dir"},
+	}
+
+	text, err := Format(FormatText, "x.sh", findings, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"x.sh:2 [missing-strict-mode] no strict mode", "→ set -euo pipefail", "x.sh:7-8", "2 findings: 1 errors, 1 warnings, 0 info"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text output missing %q:\n%s", want, text)
+		}
+	}
+
+	out, err := Format(FormatJSON, "x.sh", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"findings": []`) {
+		t.Errorf("JSON output should have an empty findings array:\n%s", out)
+	}
+
+	out, err = Format(FormatSARIF, "x.sh", findings, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sarif sarifLog
+	if err := json.Unmarshal([]byte(out), &sarif); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	results := sarif.Runs[0].Results
+	if sarif.Version != "2.1.0" || len(results) != 2 {
+		t.Fatalf("unexpected SARIF log: %s", out)
+	}
+	if results[1].Level != "error" || results[1].Locations[0].PhysicalLocation.Region.EndLine != 8 {
+		t.Errorf("unexpected SARIF result: %+v", results[1])
+	}
+
+	if _, err := Format("xml", "x.sh", findings, ""); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
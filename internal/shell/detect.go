@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// knownShells lists the shell types ohman can install hooks for and read history from
+var knownShells = []string{"zsh", "bash", "fish", "nu", "elvish", "xonsh"}
+
+// DetectShell detects the current shell.
+// The parent process is checked first since it is the shell ohman was
+// actually launched from, falling back to $SHELL (the login shell).
+func DetectShell() string {
+	if shellType := detectParentShell(); shellType != "" {
+		return shellType
+	}
+
+	if shellType := shellFromName(os.Getenv("SHELL")); shellType != "" {
+		return shellType
+	}
+
+	return "unknown"
+}
+
+// detectParentShell inspects the parent process to find the running shell
+func detectParentShell() string {
+	ppid := os.Getppid()
+
+	// Linux: read the process name from procfs
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", ppid)); err == nil {
+		if shellType := shellFromName(strings.TrimSpace(string(comm))); shellType != "" {
+			return shellType
+		}
+
+		// Python-based shells like xonsh show up as the interpreter
+		if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", ppid)); err == nil {
+			for _, arg := range bytes.Split(cmdline, []byte{0}) {
+				if shellType := shellFromName(string(arg)); shellType == "xonsh" {
+					return shellType
+				}
+			}
+		}
+		return ""
+	}
+
+	// Other Unix systems: ask ps
+	output, err := exec.Command("ps", "-p", fmt.Sprintf("%d", ppid), "-o", "comm=").Output()
+	if err != nil {
+		return ""
+	}
+	return shellFromName(strings.TrimSpace(string(output)))
+}
+
+// shellFromName maps a shell path or process name such as "/bin/zsh",
+// "-bash" or "zsh-5.9" to a known shell type, or "" if unrecognized
+func shellFromName(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	base := filepath.Base(strings.TrimPrefix(name, "-"))
+	base = strings.TrimRight(base, "0123456789.-")
+
+	for _, shellType := range knownShells {
+		if base == shellType {
+			return shellType
+		}
+	}
+	if base == "nushell" {
+		return "nu"
+	}
+
+	return ""
+}
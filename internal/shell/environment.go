@@ -0,0 +1,196 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds every external command run while collecting context
+const probeTimeout = 2 * time.Second
+
+// maxEnvironmentChars bounds the size of the rendered environment block
+const maxEnvironmentChars = 2000
+
+// Environment describes the context a command was run in
+type Environment struct {
+	Dir          string
+	GitBranch    string
+	GitStatus    string
+	ProjectTypes []string
+	// Tools are the toolchains of the project types, whose versions are
+	// worth reporting
+	Tools    []string
+	Versions map[string]string // tool name -> installed version, filled in by the caller
+}
+
+// projectMarker maps a file found in the working directory to a project type
+// and the toolchain whose version is worth reporting for it
+type projectMarker struct {
+	file        string
+	projectType string
+	tool        string
+}
+
+var projectMarkers = []projectMarker{
+	{"go.mod", "Go", "go"},
+	{"package.json", "Node.js", "node"},
+	{"Cargo.toml", "Rust", "cargo"},
+	{"pyproject.toml", "Python", "python3"},
+	{"requirements.txt", "Python", "python3"},
+	{"pom.xml", "Maven", "mvn"},
+	{"build.gradle", "Gradle", "gradle"},
+	{"Gemfile", "Ruby", "ruby"},
+	{"CMakeLists.txt", "CMake", "cmake"},
+	{"Makefile", "Make", "make"},
+	{"Dockerfile", "Docker", "docker"},
+	{"docker-compose.yml", "Docker Compose", "docker"},
+	{"compose.yaml", "Docker Compose", "docker"},
+}
+
+// CollectEnvironment gathers the working directory, git state, project type
+// and relevant toolchains for diagnosing a failed command. Tools are not run
+// here; their versions are left to the caller's probe policy.
+func CollectEnvironment(dir string) *Environment {
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	env := &Environment{
+		Dir:      dir,
+		Versions: make(map[string]string),
+	}
+
+	if dir != "" {
+		env.GitBranch = runProbe(dir, "git", "rev-parse", "--abbrev-ref", "HEAD")
+		if env.GitBranch != "" {
+			env.GitStatus = summarizeGitStatus(runProbe(dir, "git", "status", "--porcelain"))
+		}
+	}
+
+	seen, seenTool := make(map[string]bool), make(map[string]bool)
+	for _, marker := range projectMarkers {
+		if _, err := os.Stat(filepath.Join(dir, marker.file)); err != nil {
+			continue
+		}
+		if !seen[marker.projectType] {
+			env.ProjectTypes = append(env.ProjectTypes, marker.projectType)
+			seen[marker.projectType] = true
+		}
+		if !seenTool[marker.tool] {
+			env.Tools = append(env.Tools, marker.tool)
+			seenTool[marker.tool] = true
+		}
+	}
+
+	return env
+}
+
+// String renders the environment as a bounded text block for prompts
+func (e *Environment) String() string {
+	if e == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if e.Dir != "" {
+		sb.WriteString(fmt.Sprintf("Working directory: %s\n", e.Dir))
+	}
+	if e.GitBranch != "" {
+		sb.WriteString(fmt.Sprintf("Git branch: %s", e.GitBranch))
+		if e.GitStatus != "" {
+			sb.WriteString(fmt.Sprintf(" (%s)", e.GitStatus))
+		}
+		sb.WriteString("\n")
+	}
+	if len(e.ProjectTypes) > 0 {
+		sb.WriteString(fmt.Sprintf("Project type: %s\n", strings.Join(e.ProjectTypes, ", ")))
+	}
+	if len(e.Versions) > 0 {
+		sb.WriteString("Tool versions:\n")
+		for _, name := range sortedKeys(e.Versions) {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", name, e.Versions[name]))
+		}
+	}
+
+	out := strings.TrimSpace(sb.String())
+	if len(out) > maxEnvironmentChars {
+		out = out[:maxEnvironmentChars] + "\n... (truncated)"
+	}
+	return out
+}
+
+// summarizeGitStatus turns "git status --porcelain" output into a short summary
+func summarizeGitStatus(porcelain string) string {
+	if porcelain == "" {
+		return "clean"
+	}
+
+	var modified, added, deleted, untracked, conflicted int
+	for _, line := range strings.Split(porcelain, "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		code := line[:2]
+		switch {
+		case code == "??":
+			untracked++
+		case strings.Contains(code, "U") || code == "AA" || code == "DD":
+			conflicted++
+		case strings.Contains(code, "D"):
+			deleted++
+		case strings.Contains(code, "A"):
+			added++
+		default:
+			modified++
+		}
+	}
+
+	var parts []string
+	for _, c := range []struct {
+		n    int
+		name string
+	}{
+		{modified, "modified"},
+		{added, "added"},
+		{deleted, "deleted"},
+		{untracked, "untracked"},
+		{conflicted, "conflicted"},
+	} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.name))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// runProbe runs a short-lived command with a timeout and closed stdin,
+// returning its output without trailing newlines, or "" on any failure
+func runProbe(dir, name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir // Stdin is left nil, so the command reads from the null device
+
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(output), "\r\n")
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeGitStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		porcelain string
+		want      string
+	}{
+		{"clean", "", "clean"},
+		{"mixed", " M main.go\nM  go.mod\n?? notes.txt\nA  new.go\n D old.go", "2 modified, 1 added, 1 deleted, 1 untracked"},
+		{"conflict", "UU app.go", "1 conflicted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeGitStatus(tt.porcelain); got != tt.want {
+				t.Errorf("summarizeGitStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectEnvironmentProjectTypes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"go.mod", "Dockerfile", "compose.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := CollectEnvironment(dir)
+	if env.Dir != dir {
+		t.Errorf("Dir = %q, want %q", env.Dir, dir)
+	}
+
+	want := []string{"Go", "Docker", "Docker Compose"}
+	if strings.Join(env.ProjectTypes, ",") != strings.Join(want, ",") {
+		t.Errorf("ProjectTypes = %v, want %v", env.ProjectTypes, want)
+	}
+	if tools := strings.Join(env.Tools, ","); tools != "go,docker" {
+		t.Errorf("Tools = %v, want [go docker]", env.Tools)
+	}
+
+	block := env.String()
+	if !strings.Contains(block, "Working directory: "+dir) {
+		t.Errorf("environment block missing working directory:\n%s", block)
+	}
+	if !strings.Contains(block, "Project type: Go, Docker, Docker Compose") {
+		t.Errorf("environment block missing project type:\n%s", block)
+	}
+}
+
+func TestEnvironmentStringIsBounded(t *testing.T) {
+	env := &Environment{
+		Dir:      strings.Repeat("d", 5000),
+		Versions: map[string]string{},
+	}
+
+	if got := env.String(); len(got) > maxEnvironmentChars+len("\n... (truncated)") {
+		t.Errorf("environment block length = %d, exceeds bound", len(got))
+	}
+}
@@ -1,13 +1,15 @@
 package shell
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/liliang-cn/ohman/internal/config"
 )
 
 // FailedCommand represents a failed command
@@ -16,10 +18,11 @@ type FailedCommand struct {
 	ExitCode int
 	Error    string
 	Time     time.Time
+	Dir      string // Working directory, if the history source recorded it (nushell's SQLite history does)
 }
 
 // GetLastFailed gets the last failed command
-func GetLastFailed() (*FailedCommand, error) {
+func GetLastFailed(cfg config.ShellConfig) (*FailedCommand, error) {
 	// Method 1: Try reading the hook-recorded file (most accurate)
 	if cmd, err := readFailedFromHook(); err == nil {
 		return cmd, nil
@@ -27,7 +30,7 @@ func GetLastFailed() (*FailedCommand, error) {
 
 	// Method 2: Fallback - read last command from shell history
 	// Assume the user just ran a failed command and immediately runs ohman
-	if cmd, err := getLastCommandFromHistory(); err == nil {
+	if cmd, err := getLastCommandFromHistory(cfg); err == nil {
 		return cmd, nil
 	}
 
@@ -35,62 +38,36 @@ func GetLastFailed() (*FailedCommand, error) {
 }
 
 // getLastCommandFromHistory reads the last command from shell history file
-func getLastCommandFromHistory() (*FailedCommand, error) {
-	history, err := GetHistory(5) // Get a few more in case some are ohman itself
-	if err != nil || len(history) == 0 {
+func getLastCommandFromHistory(cfg config.ShellConfig) (*FailedCommand, error) {
+	entries, err := GetHistoryEntries(cfg, 5) // Get a few more in case some are ohman itself
+	if err != nil || len(entries) == 0 {
 		return nil, fmt.Errorf("cannot read history")
 	}
 
-	shellType := DetectShell()
-
 	// Find the last non-ohman command
-	for i := len(history) - 1; i >= 0; i-- {
-		cmd := parseHistoryLine(history[i], shellType)
-		if cmd == "" {
-			continue
-		}
+	for i := len(entries) - 1; i >= 0; i-- {
+		cmd := entries[i].Command
 		// Skip if command is ohman itself
 		if strings.HasPrefix(cmd, "ohman") || strings.HasPrefix(cmd, "./bin/ohman") {
 			continue
 		}
+
+		timestamp := entries[i].Time
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+
 		return &FailedCommand{
 			Command:  cmd,
 			ExitCode: 1, // Unknown exit code
-			Time:     time.Now(),
+			Time:     timestamp,
+			Dir:      entries[i].Dir,
 		}, nil
 	}
 
 	return nil, fmt.Errorf("no previous command found")
 }
 
-// parseHistoryLine parses a history line based on shell type
-func parseHistoryLine(line, shellType string) string {
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return ""
-	}
-
-	switch shellType {
-	case "zsh":
-		// Zsh extended history format: ": timestamp:0;command"
-		if strings.HasPrefix(line, ": ") {
-			if idx := strings.Index(line, ";"); idx != -1 {
-				return strings.TrimSpace(line[idx+1:])
-			}
-		}
-		return line
-	case "fish":
-		// Fish format: "- cmd: command" or just the command
-		if strings.HasPrefix(line, "- cmd: ") {
-			return strings.TrimSpace(line[7:])
-		}
-		return line
-	default:
-		// Bash and others: plain command
-		return line
-	}
-}
-
 // readFailedFromHook reads failed command from hook file
 func readFailedFromHook() (*FailedCommand, error) {
 	// Hook file format: exitcode|command|timestamp
@@ -134,21 +111,75 @@ func readFailedFromHook() (*FailedCommand, error) {
 	}, nil
 }
 
-// GetHistory gets shell history records
-func GetHistory(limit int) ([]string, error) {
-	historyFile := getHistoryFile()
+// GetHistory gets the most recent shell history commands
+func GetHistory(cfg config.ShellConfig, limit int) ([]string, error) {
+	entries, err := GetHistoryEntries(cfg, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make([]string, len(entries))
+	for i, entry := range entries {
+		commands[i] = entry.Command
+	}
+	return commands, nil
+}
+
+// GetHistoryEntries gets the most recent structured shell history entries
+func GetHistoryEntries(cfg config.ShellConfig, limit int) ([]HistoryEntry, error) {
+	shellType := DetectShell()
+
+	// Elvish keeps history in its own database, only reachable through elvish
+	if shellType == "elvish" && cfg.HistoryFile == "" {
+		return readElvishHistory(limit)
+	}
+
+	historyFile := getHistoryFile(cfg, shellType)
 	if historyFile == "" {
 		return nil, fmt.Errorf("unable to determine history file location")
 	}
 
-	return readLastLines(historyFile, limit)
+	return readHistoryFile(historyFile, shellType, limit)
+}
+
+// linesPerEntry is how many raw lines are read per requested entry, since
+// zsh multiline commands and fish records span several lines
+const linesPerEntry = 8
+
+// readHistoryFile reads the last limit entries from a history file
+func readHistoryFile(historyFile, shellType string, limit int) ([]HistoryEntry, error) {
+	switch {
+	case shellType == "xonsh":
+		return readXonshHistory(historyFile, limit)
+	case shellType == "nu" && strings.HasSuffix(historyFile, ".sqlite3"):
+		return readNuSQLiteHistory(historyFile, limit)
+	}
+
+	lines, complete, err := tailLines(historyFile, limit*linesPerEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := parseHistory(lines, shellType)
+
+	// The first entry may have been cut in half when we didn't read
+	// from the start of the file
+	if !complete && len(entries) > 0 {
+		entries = entries[1:]
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	return entries, nil
 }
 
-// getHistoryFile gets the history file path
-func getHistoryFile() string {
-	// Check environment variable
-	if histFile := os.Getenv("HISTFILE"); histFile != "" {
-		return histFile
+// getHistoryFile gets the history file path for a shell type
+func getHistoryFile(cfg config.ShellConfig, shellType string) string {
+	// Explicit configuration always wins
+	if cfg.HistoryFile != "" {
+		return expandHome(cfg.HistoryFile)
 	}
 
 	home, err := os.UserHomeDir()
@@ -156,52 +187,140 @@ func getHistoryFile() string {
 		return ""
 	}
 
-	// Detect shell type
-	shell := os.Getenv("SHELL")
-	if strings.Contains(shell, "zsh") {
+	switch shellType {
+	case "zsh":
+		if histFile := os.Getenv("HISTFILE"); histFile != "" {
+			return histFile
+		}
 		return filepath.Join(home, ".zsh_history")
-	}
-	if strings.Contains(shell, "bash") {
+	case "fish":
+		// fish names the file after the session, "fish" unless $fish_history is set
+		session := os.Getenv("fish_history")
+		if session == "" {
+			session = "fish"
+		}
+		return filepath.Join(xdgDir("XDG_DATA_HOME", home, ".local", "share"), "fish", session+"_history")
+	case "nu":
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return ""
+		}
+		// Nushell writes history.sqlite3 when file_format is "sqlite"
+		sqliteFile := filepath.Join(configDir, "nushell", "history.sqlite3")
+		if _, err := os.Stat(sqliteFile); err == nil {
+			return sqliteFile
+		}
+		return filepath.Join(configDir, "nushell", "history.txt")
+	case "xonsh":
+		dataDir := os.Getenv("XONSH_DATA_DIR")
+		if dataDir == "" {
+			dataDir = filepath.Join(xdgDir("XDG_DATA_HOME", home, ".local", "share"), "xonsh")
+		}
+		return latestFile(filepath.Join(dataDir, "history_json"), "xonsh-*.json")
+	default:
+		// Bash and others
+		if histFile := os.Getenv("HISTFILE"); histFile != "" {
+			return histFile
+		}
 		return filepath.Join(home, ".bash_history")
 	}
+}
 
-	// Default to bash history
-	return filepath.Join(home, ".bash_history")
+// xdgDir returns the directory named by an XDG environment variable, or the
+// default location under home when it is unset
+func xdgDir(env, home string, defaults ...string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	return filepath.Join(append([]string{home}, defaults...)...)
 }
 
-// readLastLines reads the last n lines of a file
-func readLastLines(filePath string, n int) ([]string, error) {
+// expandHome expands a leading "~/" to the user's home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// latestFile returns the most recently modified file in dir matching pattern
+func latestFile(dir, pattern string) string {
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return ""
+	}
+
+	var latest string
+	var latestMod time.Time
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latestMod) {
+			latest = match
+			latestMod = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// tailChunkSize is the block size used when scanning a file backwards
+const tailChunkSize = 8192
+
+// tailLines reads the last n lines of a file by scanning backwards from the
+// end, so large history files never have to be read in full. The returned
+// bool reports whether the lines cover the whole file.
+func tailLines(filePath string, n int) ([]string, bool, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer func() { _ = file.Close() }()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-		if len(lines) > n {
-			lines = lines[1:]
-		}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, err
 	}
 
-	return lines, scanner.Err()
-}
+	offset := info.Size()
+	var data []byte
+	newlines := 0
 
-// DetectShell detects the current shell
-func DetectShell() string {
-	shell := os.Getenv("SHELL")
-	if strings.Contains(shell, "zsh") {
-		return "zsh"
+	// A trailing newline terminates the last line rather than starting a
+	// new one, so n lines need n+1 newlines to be sure the first is whole
+	for offset > 0 && newlines <= n {
+		size := int64(tailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return nil, false, err
+		}
+		newlines += bytes.Count(chunk, []byte{'\n'})
+		data = append(chunk, data...)
 	}
-	if strings.Contains(shell, "bash") {
-		return "bash"
+
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil, offset == 0, nil
 	}
-	if strings.Contains(shell, "fish") {
-		return "fish"
+
+	lines := strings.Split(text, "\n")
+	complete := offset == 0
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+		complete = false
 	}
-	return "unknown"
+
+	return lines, complete, nil
 }
 
 // GetShellHookScript gets the shell hook script
@@ -236,11 +355,52 @@ function ohman_postexec --on-event fish_postexec
     end
 end`
 
+	case "nu":
+		return `# Oh Man! Failed command recording hook
+def ohman_pre_prompt [] {
+    if $env.LAST_EXIT_CODE != 0 {
+        let cmd = (history | last | get command)
+        $"($env.LAST_EXIT_CODE)|($cmd)|(date now | format date '%s')" | save -f $"/tmp/.ohman_last_failed_($nu.pid)"
+    }
+}
+$env.config.hooks.pre_prompt = ($env.config.hooks.pre_prompt? | default [] | append {|| ohman_pre_prompt })`
+
+	case "elvish":
+		return `# Oh Man! Failed command recording hook
+fn ohman-after-command {|m|
+    if (not-eq $m[error] $nil) {
+        var code = 1
+        try { set code = $m[error][reason][exit-status] } catch { }
+        echo $code'|'$m[src][code]'|'(date +%s) > /tmp/.ohman_last_failed_$pid
+    }
+}
+set edit:after-command = [$@edit:after-command $ohman-after-command~]`
+
+	case "xonsh":
+		return `# Oh Man! Failed command recording hook
+@events.on_postcommand
+def ohman_postcommand(cmd, rtn, out, ts):
+    if rtn != 0:
+        import os, time
+        with open(f"/tmp/.ohman_last_failed_{os.getpid()}", "w") as f:
+            f.write(f"{rtn}|{cmd.strip()}|{int(time.time())}")`
+
 	default:
 		return ""
 	}
 }
 
+// hookMarkers maps each shell type to the function name its hook defines,
+// used to detect an existing installation
+var hookMarkers = map[string]string{
+	"zsh":    "ohman_precmd",
+	"bash":   "ohman_prompt_command",
+	"fish":   "ohman_postexec",
+	"nu":     "ohman_pre_prompt",
+	"elvish": "ohman-after-command",
+	"xonsh":  "ohman_postcommand",
+}
+
 // getShellConfigFile returns the shell config file path
 func getShellConfigFile(shellType string) string {
 	home, err := os.UserHomeDir()
@@ -259,7 +419,22 @@ func getShellConfigFile(shellType string) string {
 		}
 		return filepath.Join(home, ".bash_profile")
 	case "fish":
-		return filepath.Join(home, ".config", "fish", "config.fish")
+		return filepath.Join(xdgDir("XDG_CONFIG_HOME", home, ".config"), "fish", "config.fish")
+	case "nu":
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return ""
+		}
+		return filepath.Join(configDir, "nushell", "config.nu")
+	case "elvish":
+		// Prefer the legacy location if it is already in use
+		legacy := filepath.Join(home, ".elvish", "rc.elv")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+		return filepath.Join(xdgDir("XDG_CONFIG_HOME", home, ".config"), "elvish", "rc.elv")
+	case "xonsh":
+		return filepath.Join(home, ".xonshrc")
 	default:
 		return ""
 	}
@@ -268,26 +443,11 @@ func getShellConfigFile(shellType string) string {
 // IsHookInstalled checks if the shell hook is already installed
 func IsHookInstalled() bool {
 	shellType := DetectShell()
-	configFile := getShellConfigFile(shellType)
-	if configFile == "" {
+	marker, ok := hookMarkers[shellType]
+	if !ok {
 		return false
 	}
-
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		return false
-	}
-
-	content := string(data)
-	switch shellType {
-	case "zsh":
-		return strings.Contains(content, "ohman_precmd")
-	case "bash":
-		return strings.Contains(content, "ohman_prompt_command")
-	case "fish":
-		return strings.Contains(content, "ohman_postexec")
-	}
-	return false
+	return configContains(shellType, marker)
 }
 
 // EnsureHookInstalled checks and installs the shell hook if needed
@@ -302,34 +462,54 @@ func EnsureHookInstalled() (installed bool, needsReload bool, err error) {
 		return false, false, fmt.Errorf("unsupported shell")
 	}
 
-	configFile := getShellConfigFile(shellType)
-	if configFile == "" {
-		return false, false, fmt.Errorf("cannot determine shell config file")
-	}
-
 	hookScript := GetShellHookScript(shellType)
 	if hookScript == "" {
 		return false, false, fmt.Errorf("no hook script for shell: %s", shellType)
 	}
 
-	// Ensure directory exists for fish
-	if shellType == "fish" {
-		dir := filepath.Dir(configFile)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return false, false, fmt.Errorf("failed to create config directory: %w", err)
-		}
+	if err := appendToShellConfig(shellType, hookScript); err != nil {
+		return false, false, err
 	}
 
-	// Append hook to config file
+	return true, true, nil
+}
+
+// installSnippet appends an optional integration script to the shell config
+// unless marker shows it is already there.
+// Returns: (installed, needsReload, error)
+func installSnippet(shellType, marker, script string) (installed bool, needsReload bool, err error) {
+	if configContains(shellType, marker) {
+		return true, false, nil
+	}
+
+	if err := appendToShellConfig(shellType, script); err != nil {
+		return false, false, err
+	}
+	return true, true, nil
+}
+
+// appendToShellConfig appends a script snippet to the shell's config file
+func appendToShellConfig(shellType, script string) error {
+	configFile := getShellConfigFile(shellType)
+	if configFile == "" {
+		return fmt.Errorf("cannot determine shell config file")
+	}
+
+	// Ensure directory exists for shells configured under ~/.config
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// Append script to config file
 	f, err := os.OpenFile(configFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return false, false, fmt.Errorf("failed to open config file: %w", err)
+		return fmt.Errorf("failed to open config file: %w", err)
 	}
 	defer f.Close()
 
-	if _, err := f.WriteString("\n" + hookScript + "\n"); err != nil {
-		return false, false, fmt.Errorf("failed to write hook: %w", err)
+	if _, err := f.WriteString("\n" + script + "\n"); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
 	}
 
-	return true, true, nil
+	return nil
 }
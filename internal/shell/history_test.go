@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/liliang-cn/ohman/internal/config"
 )
 
 func TestDetectShell(t *testing.T) {
@@ -17,6 +19,9 @@ func TestDetectShell(t *testing.T) {
 		{"zsh", "/bin/zsh", "zsh"},
 		{"bash", "/bin/bash", "bash"},
 		{"fish", "/usr/bin/fish", "fish"},
+		{"nushell", "/usr/local/bin/nu", "nu"},
+		{"elvish", "/usr/bin/elvish", "elvish"},
+		{"xonsh", "/usr/bin/xonsh", "xonsh"},
 		{"unknown", "/bin/sh", "unknown"},
 	}
 
@@ -41,6 +46,9 @@ func TestGetShellHookScript(t *testing.T) {
 		{"zsh", false},
 		{"bash", false},
 		{"fish", false}, // Now supported
+		{"nu", false},
+		{"elvish", false},
+		{"xonsh", false},
 		{"unknown", true},
 	}
 
@@ -57,10 +65,8 @@ func TestGetShellHookScript(t *testing.T) {
 	}
 }
 
-func TestReadLastLines(t *testing.T) {
-	// Create temporary file
-	tmpDir := t.TempDir()
-	tmpFile := filepath.Join(tmpDir, "test_history")
+func TestTailLines(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test_history")
 
 	content := "line1\nline2\nline3\nline4\nline5\n"
 	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
@@ -68,23 +74,24 @@ func TestReadLastLines(t *testing.T) {
 	}
 
 	tests := []struct {
-		name  string
-		limit int
-		want  int
+		name     string
+		limit    int
+		want     int
+		complete bool
 	}{
-		{"all lines", 10, 5},
-		{"limited", 3, 3},
-		{"exact", 5, 5},
+		{"all lines", 10, 5, true},
+		{"limited", 3, 3, false},
+		{"exact", 5, 5, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			lines, err := readLastLines(tmpFile, tt.limit)
+			lines, complete, err := tailLines(tmpFile, tt.limit)
 			if err != nil {
-				t.Fatalf("readLastLines() error = %v", err)
+				t.Fatalf("tailLines() error = %v", err)
 			}
-			if len(lines) != tt.want {
-				t.Errorf("readLastLines() got %d lines, want %d", len(lines), tt.want)
+			if len(lines) != tt.want || complete != tt.complete {
+				t.Errorf("tailLines() got %d lines, complete %v, want %d, %v", len(lines), complete, tt.want, tt.complete)
 			}
 		})
 	}
@@ -92,7 +99,7 @@ func TestReadLastLines(t *testing.T) {
 
 func TestGetHistory(t *testing.T) {
 	// This test depends on the system environment
-	lines, err := GetHistory(5)
+	lines, err := GetHistory(config.ShellConfig{}, 5)
 	if err != nil {
 		t.Logf("GetHistory() returned error (may be expected): %v", err)
 		return
@@ -125,3 +132,81 @@ func TestReadFailedFromHookFile(t *testing.T) {
 		t.Errorf("command = %s, want 'ls -la nonexistent'", cmd.Command)
 	}
 }
+
+func TestShellFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"-zsh", "zsh"},
+		{"zsh-5.9", "zsh"},
+		{"/opt/homebrew/bin/bash", "bash"},
+		{"nu", "nu"},
+		{"xonsh", "xonsh"},
+		{"python3", ""},
+		{"gnu-tool", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shellFromName(tt.name); got != tt.want {
+				t.Errorf("shellFromName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHistoryFile(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	t.Setenv("HISTFILE", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("fish_history", "")
+
+	tests := []struct {
+		name      string
+		cfg       config.ShellConfig
+		shellType string
+		want      string
+	}{
+		{"configured file wins", config.ShellConfig{HistoryFile: "/tmp/my_history"}, "zsh", "/tmp/my_history"},
+		{"configured file expands home", config.ShellConfig{HistoryFile: "~/hist"}, "bash", filepath.Join(home, "hist")},
+		{"zsh default", config.ShellConfig{}, "zsh", filepath.Join(home, ".zsh_history")},
+		{"bash default", config.ShellConfig{}, "bash", filepath.Join(home, ".bash_history")},
+		{"fish default", config.ShellConfig{}, "fish", filepath.Join(home, ".local", "share", "fish", "fish_history")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getHistoryFile(tt.cfg, tt.shellType); got != tt.want {
+				t.Errorf("getHistoryFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXonshHistory(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "xonsh-test.json")
+	content := `{"data": {"cmds": [
+		{"inp": "ls\n", "rtn": 0, "ts": [1700000000.5, 1700000001.0]},
+		{"inp": "make build\n", "rtn": 2, "ts": [1700000010.0, 1700000020.0]}
+	]}}`
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readHistoryFile(tmpFile, "xonsh", 5)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[1].Command != "make build" || entries[1].Time.Unix() != 1700000010 {
+		t.Errorf("unexpected entry: %+v", entries[1])
+	}
+}
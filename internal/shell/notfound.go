@@ -0,0 +1,215 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GetCommandNotFoundScript gets the opt-in command-not-found handler script
+func GetCommandNotFoundScript(shellType string) string {
+	switch shellType {
+	case "zsh":
+		return `# Oh Man! command-not-found handler
+command_not_found_handler() {
+    if (( $+commands[ohman] )); then
+        ohman not-found -- "$@"
+    else
+        print -u2 "zsh: command not found: $1"
+    fi
+    return 127
+}`
+
+	case "bash":
+		return `# Oh Man! command-not-found handler
+command_not_found_handle() {
+    if command -v ohman >/dev/null 2>&1; then
+        ohman not-found -- "$@"
+    else
+        printf 'bash: %s: command not found\n' "$1" >&2
+    fi
+    return 127
+}`
+
+	case "fish":
+		return `# Oh Man! command-not-found handler
+function fish_command_not_found
+    if command -q ohman
+        ohman not-found -- $argv
+    else
+        __fish_default_command_not_found_handler $argv
+    end
+end`
+
+	default:
+		return ""
+	}
+}
+
+// commandNotFoundMarkers maps each shell type to the handler its script defines
+var commandNotFoundMarkers = map[string]string{
+	"zsh":  "command_not_found_handler",
+	"bash": "command_not_found_handle",
+	"fish": "fish_command_not_found",
+}
+
+// commandNotFoundInstallMarker identifies ohman's own handler in a shell config
+const commandNotFoundInstallMarker = "ohman not-found"
+
+// IsCommandNotFoundInstalled checks if the command-not-found handler is installed
+func IsCommandNotFoundInstalled() bool {
+	return configContains(DetectShell(), commandNotFoundInstallMarker)
+}
+
+// InstallCommandNotFoundHandler installs the command-not-found handler.
+// Returns: (installed, needsReload, error)
+func InstallCommandNotFoundHandler() (installed bool, needsReload bool, err error) {
+	if IsCommandNotFoundInstalled() {
+		return true, false, nil
+	}
+
+	shellType := DetectShell()
+	script := GetCommandNotFoundScript(shellType)
+	if script == "" {
+		return false, false, fmt.Errorf("command-not-found handler not supported for shell: %s", shellType)
+	}
+
+	// Another handler (e.g. from the distro or a plugin) would be shadowed
+	// silently, so refuse and let the user decide
+	if marker := commandNotFoundMarkers[shellType]; configContains(shellType, marker) {
+		return false, false, fmt.Errorf("shell config already defines %s, remove it first to use ohman's handler", marker)
+	}
+
+	return installSnippet(shellType, commandNotFoundInstallMarker, script)
+}
+
+// SimilarCommands finds executables on PATH whose names are close to name,
+// ordered by edit distance, returning at most limit results
+func SimilarCommands(name string, limit int) []string {
+	if name == "" || limit <= 0 {
+		return nil
+	}
+
+	// Allow roughly one typo per three characters
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	var candidates []candidate
+	seen := make(map[string]bool)
+	for _, exe := range pathExecutables() {
+		if seen[exe] || exe == name {
+			continue
+		}
+		seen[exe] = true
+
+		if d := editDistance(name, exe); d <= maxDistance {
+			candidates = append(candidates, candidate{exe, d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var result []string
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		result = append(result, candidates[i].name)
+	}
+	return result
+}
+
+// pathExecutables lists the names of executable files in $PATH directories
+func pathExecutables() []string {
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			// Follow symlinks so linked binaries are included
+			if info.Mode()&os.ModeSymlink != 0 {
+				if info, err = os.Stat(filepath.Join(dir, entry.Name())); err != nil {
+					continue
+				}
+			}
+			if info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	return names
+}
+
+// editDistance computes the Damerau-Levenshtein (optimal string alignment)
+// distance, so swapped letters like "gti" -> "git" count as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows, cols := len(ra)+1, len(rb)+1
+
+	d := make([][]int, rows)
+	for i := range d {
+		d[i] = make([]int, cols)
+		d[i][0] = i
+	}
+	for j := 0; j < cols; j++ {
+		d[0][j] = j
+	}
+
+	for i := 1; i < rows; i++ {
+		for j := 1; j < cols; j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[rows-1][cols-1]
+}
+
+// DetectPackageManager returns the system package manager available on PATH
+func DetectPackageManager() string {
+	for _, pm := range []string{"apt", "dnf", "yum", "pacman", "zypper", "apk", "brew", "nix", "port"} {
+		if _, err := exec.LookPath(pm); err == nil {
+			return pm
+		}
+	}
+	return ""
+}
+
+// configContains reports whether the shell's config file contains s
+func configContains(shellType, s string) bool {
+	configFile := getShellConfigFile(shellType)
+	if configFile == "" {
+		return false
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), s)
+}
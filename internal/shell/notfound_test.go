@@ -0,0 +1,74 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"git", "git", 0},
+		{"gti", "git", 1},
+		{"gerp", "grep", 1},
+		{"dokcer", "docker", 1},
+		{"pyhton3", "python3", 1},
+		{"ls", "cat", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"-"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarCommands(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"git", "gitk", "grep", "docker"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Non-executable files must be ignored
+	if err := os.WriteFile(filepath.Join(dir, "gti.txt"), []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	got := SimilarCommands("gti", 3)
+	if len(got) == 0 || got[0] != "git" {
+		t.Errorf("SimilarCommands(gti) = %v, want git first", got)
+	}
+
+	if got := SimilarCommands("dokcer", 3); strings.Join(got, ",") != "docker" {
+		t.Errorf("SimilarCommands(dokcer) = %v, want [docker]", got)
+	}
+
+	if got := SimilarCommands("kubectl", 3); len(got) != 0 {
+		t.Errorf("SimilarCommands(kubectl) = %v, want none", got)
+	}
+}
+
+func TestGetCommandNotFoundScript(t *testing.T) {
+	for _, shellType := range []string{"zsh", "bash", "fish"} {
+		script := GetCommandNotFoundScript(shellType)
+		if !strings.Contains(script, "ohman not-found") {
+			t.Errorf("%s handler should call ohman not-found", shellType)
+		}
+		if !strings.Contains(script, commandNotFoundMarkers[shellType]) {
+			t.Errorf("%s handler should define %s", shellType, commandNotFoundMarkers[shellType])
+		}
+	}
+
+	if script := GetCommandNotFoundScript("unknown"); script != "" {
+		t.Error("expected empty script for unknown shell")
+	}
+}
@@ -0,0 +1,217 @@
+package shell
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryEntry represents a single command recorded in a shell history file
+type HistoryEntry struct {
+	Command string
+	Time    time.Time // Zero if the shell did not record a timestamp
+	Dir     string    // Working directory, only recorded by nushell's SQLite history
+	Paths   []string  // Paths referenced by the command (fish only)
+}
+
+// bashTimestampRe matches the "#<epoch>" lines bash writes when HISTTIMEFORMAT is set
+var bashTimestampRe = regexp.MustCompile(`^#([0-9]+)$`)
+
+// zshExtendedRe matches the ": <epoch>:<duration>;" prefix of zsh EXTENDED_HISTORY
+var zshExtendedRe = regexp.MustCompile(`^: *([0-9]+):[0-9]+;`)
+
+// zshMeta is the byte zsh uses to escape metafied characters in its history
+const zshMeta = 0x83
+
+// parseHistory parses raw history file lines into entries based on shell type
+func parseHistory(lines []string, shellType string) []HistoryEntry {
+	switch shellType {
+	case "zsh":
+		return parseZshHistory(lines)
+	case "fish":
+		return parseFishHistory(lines)
+	case "nu":
+		return parseNuHistory(lines)
+	default:
+		return parseBashHistory(lines)
+	}
+}
+
+// parseBashHistory parses bash history, attaching HISTTIMEFORMAT timestamps
+// to the command that follows them
+func parseBashHistory(lines []string) []HistoryEntry {
+	var entries []HistoryEntry
+	var pending time.Time
+
+	for _, line := range lines {
+		if m := bashTimestampRe.FindStringSubmatch(line); m != nil {
+			if ts, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+				pending = time.Unix(ts, 0)
+			}
+			continue
+		}
+
+		cmd := strings.TrimSpace(line)
+		if cmd == "" {
+			continue
+		}
+
+		entries = append(entries, HistoryEntry{Command: cmd, Time: pending})
+		pending = time.Time{}
+	}
+
+	return entries
+}
+
+// parseZshHistory parses zsh history in both plain and EXTENDED_HISTORY
+// formats, joining multiline entries and decoding metafied bytes
+func parseZshHistory(lines []string) []HistoryEntry {
+	var entries []HistoryEntry
+	var current *HistoryEntry
+	var buf strings.Builder
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Command = strings.TrimSpace(buf.String())
+		if current.Command != "" {
+			entries = append(entries, *current)
+		}
+		current = nil
+		buf.Reset()
+	}
+
+	for _, raw := range lines {
+		line := unmetafy(raw)
+
+		if current == nil {
+			current = &HistoryEntry{}
+			if m := zshExtendedRe.FindStringSubmatch(line); m != nil {
+				if ts, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+					current.Time = time.Unix(ts, 0)
+				}
+				line = line[len(m[0]):]
+			}
+		}
+
+		// A trailing backslash means the command continues on the next line
+		if strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") {
+			buf.WriteString(line[:len(line)-1])
+			buf.WriteString("\n")
+			continue
+		}
+
+		buf.WriteString(line)
+		flush()
+	}
+	flush()
+
+	return entries
+}
+
+// unmetafy decodes zsh's metafied encoding, where bytes that clash with
+// zsh's internal tokens are stored as Meta followed by the byte XOR 32
+func unmetafy(s string) string {
+	if strings.IndexByte(s, zshMeta) == -1 {
+		return s
+	}
+
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == zshMeta && i+1 < len(s) {
+			i++
+			out = append(out, s[i]^32)
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+// parseFishHistory parses fish's YAML-like history format, where each record
+// starts with "- cmd:" and may carry "when:" and "paths:" fields
+func parseFishHistory(lines []string) []HistoryEntry {
+	var entries []HistoryEntry
+	var current *HistoryEntry
+	inPaths := false
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "- cmd:"):
+			if current != nil {
+				entries = append(entries, *current)
+			}
+			current = &HistoryEntry{
+				Command: unescapeFish(strings.TrimSpace(strings.TrimPrefix(line, "- cmd:"))),
+			}
+			inPaths = false
+
+		case current == nil:
+			// Skip anything before the first record (e.g. a truncated tail)
+
+		case strings.HasPrefix(line, "  when:"):
+			value := strings.TrimSpace(strings.TrimPrefix(line, "  when:"))
+			if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.Time = time.Unix(ts, 0)
+			}
+			inPaths = false
+
+		case strings.HasPrefix(line, "  paths:"):
+			inPaths = true
+
+		case inPaths && strings.HasPrefix(line, "    - "):
+			current.Paths = append(current.Paths, unescapeFish(strings.TrimPrefix(line, "    - ")))
+
+		default:
+			inPaths = false
+		}
+	}
+
+	if current != nil {
+		entries = append(entries, *current)
+	}
+
+	return entries
+}
+
+// unescapeFish reverses the escaping fish applies to history values
+func unescapeFish(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// nuNewlineEscape is how nushell's plaintext history stores embedded newlines
+const nuNewlineEscape = "<\\n>"
+
+// parseNuHistory parses nushell's plaintext history, one command per line
+func parseNuHistory(lines []string) []HistoryEntry {
+	var entries []HistoryEntry
+	for _, line := range lines {
+		cmd := strings.TrimSpace(strings.ReplaceAll(line, nuNewlineEscape, "\n"))
+		if cmd != "" {
+			entries = append(entries, HistoryEntry{Command: cmd})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,146 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBashHistory(t *testing.T) {
+	lines := []string{
+		"#1700000000",
+		"make build",
+		"ls -la",
+		"#1700000100",
+		"go test ./...",
+	}
+
+	entries := parseHistory(lines, "bash")
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].Command != "make build" || !entries[0].Time.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if !entries[1].Time.IsZero() {
+		t.Errorf("entry without timestamp should have zero time, got %v", entries[1].Time)
+	}
+	if entries[2].Command != "go test ./..." || entries[2].Time.Unix() != 1700000100 {
+		t.Errorf("unexpected last entry: %+v", entries[2])
+	}
+}
+
+func TestParseZshHistory(t *testing.T) {
+	lines := []string{
+		": 1700000000:0;git status",
+		": 1700000010:2;for f in *.go; do\\",
+		"  echo $f\\",
+		"done",
+		"plain command",
+		": 1700000020:0;echo caf" + string([]byte{zshMeta, 0xc3 ^ 32, zshMeta, 0xa9 ^ 32}),
+	}
+
+	entries := parseHistory(lines, "zsh")
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d: %+v", len(entries), entries)
+	}
+
+	if entries[0].Command != "git status" || entries[0].Time.Unix() != 1700000000 {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+
+	wantMulti := "for f in *.go; do\n  echo $f\ndone"
+	if entries[1].Command != wantMulti {
+		t.Errorf("multiline command = %q, want %q", entries[1].Command, wantMulti)
+	}
+
+	if entries[2].Command != "plain command" || !entries[2].Time.IsZero() {
+		t.Errorf("unexpected plain entry: %+v", entries[2])
+	}
+
+	if entries[3].Command != "echo café" {
+		t.Errorf("metafied command = %q, want %q", entries[3].Command, "echo café")
+	}
+}
+
+func TestParseFishHistory(t *testing.T) {
+	lines := []string{
+		"  when: 1699999999", // tail of a record cut off by the reader
+		"- cmd: cat ./notes.txt",
+		"  when: 1700000000",
+		"  paths:",
+		"    - ./notes.txt",
+		"- cmd: echo one\\ntwo",
+		"  when: 1700000050",
+	}
+
+	entries := parseHistory(lines, "fish")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Command != "cat ./notes.txt" || entries[0].Time.Unix() != 1700000000 {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if len(entries[0].Paths) != 1 || entries[0].Paths[0] != "./notes.txt" {
+		t.Errorf("paths = %v, want [./notes.txt]", entries[0].Paths)
+	}
+	if entries[1].Command != "echo one\ntwo" {
+		t.Errorf("escaped command = %q, want %q", entries[1].Command, "echo one\ntwo")
+	}
+}
+
+func TestTailLinesLargeFile(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "big_history")
+
+	var sb strings.Builder
+	for i := 0; i < 5000; i++ {
+		sb.WriteString(fmt.Sprintf("command number %d\n", i))
+	}
+	if err := os.WriteFile(tmpFile, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, complete, err := tailLines(tmpFile, 3)
+	if err != nil {
+		t.Fatalf("tailLines() error = %v", err)
+	}
+	if complete {
+		t.Error("tailLines() should not report reading the whole file")
+	}
+
+	want := []string{"command number 4997", "command number 4998", "command number 4999"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("tailLines() = %v, want %v", lines, want)
+	}
+}
+
+func TestReadHistoryFileDropsPartialEntry(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "zsh_history")
+
+	var sb strings.Builder
+	for i := 0; i < 50; i++ {
+		sb.WriteString(fmt.Sprintf(": %d:0;first line %d\\\nsecond line\n", 1700000000+i, i))
+	}
+	if err := os.WriteFile(tmpFile, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readHistoryFile(tmpFile, "zsh", 2)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[1].Command != "first line 49\nsecond line" {
+		t.Errorf("last command = %q", entries[1].Command)
+	}
+	if entries[1].Time.Unix() != 1700000049 {
+		t.Errorf("last time = %d, want 1700000049", entries[1].Time.Unix())
+	}
+}
@@ -0,0 +1,131 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// xonshHistory is the subset of a xonsh JSON history session file we read
+type xonshHistory struct {
+	Data struct {
+		Cmds []struct {
+			Inp string    `json:"inp"`
+			Ts  []float64 `json:"ts"`
+		} `json:"cmds"`
+	} `json:"data"`
+}
+
+// readXonshHistory reads the last limit commands from a xonsh JSON history session
+func readXonshHistory(historyFile string, limit int) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(historyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var history xonshHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse xonsh history: %w", err)
+	}
+
+	var entries []HistoryEntry
+	for _, cmd := range history.Data.Cmds {
+		command := strings.TrimSpace(cmd.Inp)
+		if command == "" {
+			continue
+		}
+
+		entry := HistoryEntry{Command: command}
+		if len(cmd.Ts) > 0 {
+			entry.Time = time.Unix(0, int64(cmd.Ts[0]*float64(time.Second)))
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// nuHistoryRow is a row of nushell's sqlite history as emitted by "sqlite3 -json"
+type nuHistoryRow struct {
+	CommandLine    string `json:"command_line"`
+	StartTimestamp *int64 `json:"start_timestamp"`
+	Cwd            string `json:"cwd"`
+}
+
+// readNuSQLiteHistory reads nushell's sqlite history through the sqlite3 CLI,
+// which also gives us the working directory of each command
+func readNuSQLiteHistory(historyFile string, limit int) ([]HistoryEntry, error) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("sqlite3 is required to read nushell sqlite history: %w", err)
+	}
+
+	query := fmt.Sprintf("SELECT command_line, start_timestamp, cwd FROM history ORDER BY id DESC LIMIT %d", limit)
+	output, err := exec.Command("sqlite3", "-readonly", "-json", historyFile, query).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nushell history: %w", err)
+	}
+
+	var rows []nuHistoryRow
+	if len(bytes.TrimSpace(output)) > 0 {
+		if err := json.Unmarshal(output, &rows); err != nil {
+			return nil, fmt.Errorf("failed to parse nushell history: %w", err)
+		}
+	}
+
+	// Rows come newest first; history entries are returned oldest first
+	entries := make([]HistoryEntry, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		entry := HistoryEntry{
+			Command: strings.TrimSpace(rows[i].CommandLine),
+			Dir:     rows[i].Cwd,
+		}
+		if rows[i].StartTimestamp != nil {
+			entry.Time = time.UnixMilli(*rows[i].StartTimestamp)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// elvishHistoryScript prints every command in elvish's store as JSON lines
+const elvishHistoryScript = `use store; store:cmds 0 (store:next-cmd-seq) | each {|c| put [&text=$c[text]] } | to-json`
+
+// readElvishHistory reads the last limit commands from elvish's history store
+func readElvishHistory(limit int) ([]HistoryEntry, error) {
+	if _, err := exec.LookPath("elvish"); err != nil {
+		return nil, fmt.Errorf("elvish not found: %w", err)
+	}
+
+	output, err := exec.Command("elvish", "-norc", "-c", elvishHistoryScript).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read elvish history: %w", err)
+	}
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var cmd struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+			continue
+		}
+		if command := strings.TrimSpace(cmd.Text); command != "" {
+			entries = append(entries, HistoryEntry{Command: command})
+		}
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, scanner.Err()
+}
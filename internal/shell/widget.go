@@ -0,0 +1,71 @@
+package shell
+
+import "fmt"
+
+// widgetInstallMarker identifies ohman's prompt widget in a shell config
+const widgetInstallMarker = "ohman widget"
+
+// GetWidgetScript gets the keybinding widget script, bound to Ctrl-X Ctrl-O.
+// The widget sends the current prompt buffer to ohman and replaces it with
+// the generated or fixed command, leaving it for review before Enter.
+func GetWidgetScript(shellType string) string {
+	switch shellType {
+	case "zsh":
+		return `# Oh Man! prompt widget (Ctrl-X Ctrl-O)
+ohman-widget() {
+    local result
+    zle -I
+    result=$(ohman widget -- "$BUFFER")
+    if [[ -n $result ]]; then
+        BUFFER=$result
+        CURSOR=${#BUFFER}
+    fi
+    zle reset-prompt
+}
+zle -N ohman-widget
+bindkey '^X^O' ohman-widget`
+
+	case "bash":
+		return `# Oh Man! prompt widget (Ctrl-X Ctrl-O)
+ohman_widget() {
+    local result
+    result=$(ohman widget -- "$READLINE_LINE")
+    if [[ -n $result ]]; then
+        READLINE_LINE=$result
+        READLINE_POINT=${#READLINE_LINE}
+    fi
+}
+bind -x '"\C-x\C-o": ohman_widget'`
+
+	case "fish":
+		return `# Oh Man! prompt widget (Ctrl-X Ctrl-O)
+function ohman_widget
+    set -l result (ohman widget -- (commandline) | string collect)
+    if test -n "$result"
+        commandline -r -- $result
+    end
+    commandline -f repaint
+end
+bind \cx\co ohman_widget`
+
+	default:
+		return ""
+	}
+}
+
+// IsWidgetInstalled checks if the prompt widget is installed
+func IsWidgetInstalled() bool {
+	return configContains(DetectShell(), widgetInstallMarker)
+}
+
+// InstallWidget installs the prompt keybinding widget.
+// Returns: (installed, needsReload, error)
+func InstallWidget() (installed bool, needsReload bool, err error) {
+	shellType := DetectShell()
+	script := GetWidgetScript(shellType)
+	if script == "" {
+		return false, false, fmt.Errorf("prompt widget not supported for shell: %s", shellType)
+	}
+
+	return installSnippet(shellType, widgetInstallMarker, script)
+}
@@ -0,0 +1,30 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetWidgetScript(t *testing.T) {
+	tests := []struct {
+		shellType string
+		buffer    string // variable holding the prompt buffer
+	}{
+		{"zsh", `"$BUFFER"`},
+		{"bash", `"$READLINE_LINE"`},
+		{"fish", "(commandline)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shellType, func(t *testing.T) {
+			script := GetWidgetScript(tt.shellType)
+			if !strings.Contains(script, widgetInstallMarker+" -- "+tt.buffer) {
+				t.Errorf("%s widget should pass %s to ohman widget", tt.shellType, tt.buffer)
+			}
+		})
+	}
+
+	if script := GetWidgetScript("unknown"); script != "" {
+		t.Error("expected empty script for unknown shell")
+	}
+}